@@ -0,0 +1,64 @@
+package main
+
+import (
+	"SnapLink/internal/model"
+	"fmt"
+
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// addColumns 为已存在的分表补充新增的字段,generateTableFunc 只创建不存在的分表
+func addColumns(db *gorm.DB, table interface{}, prefix string, shardingNum int, fields ...string) {
+	for i := 0; i < shardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", prefix, i)
+		migrator := db.Table(tableName).Migrator()
+		for _, field := range fields {
+			if migrator.HasColumn(table, field) {
+				continue
+			}
+			if err := migrator.AddColumn(table, field); err != nil {
+				logger.Panic(err.Error())
+			}
+		}
+	}
+}
+
+// migrateColumns 补充各分表在后续版本中新增的字段
+func migrateColumns(db *gorm.DB) {
+	addColumns(db, &model.TUser{}, model.TUserPrefix, model.TUserShardingNum, "Role", "MailVerified",
+		"TOTPEnabled", "TOTPSecret", "RecoveryCodes")
+	addColumns(db, &model.ShortLink{}, model.ShortLinkPrefix, model.ShortLinkShardingNum, "RedirectCode", "UtmTemplate", "QueryMode")
+	addColumns(db, &model.Redirect{}, model.RedirectPrefix, model.RedirectShardingNum, "RedirectCode", "UtmTemplate", "QueryMode")
+	addColumns(db, &model.ShortLinkGroup{}, model.SLGroupPrefix, model.SLGroupShardingNum, "Settings", "IsDefault", "ParentGid")
+	addColumns(db, &model.LinkTag{}, model.LinkTagPrefix, model.LinkTagShardingNum, "Recycled")
+	addColumns(db, &model.RecycleBin{}, model.RecycleBinPrefix, model.RecycleBinShardingNum, "CUsername")
+	addColumns(db, &model.Redirect{}, model.RedirectPrefix, model.RedirectShardingNum, "Disabled", "ExpireAt")
+	migrateRedirectStatus(db)
+	migrateDefaultGroup(db)
+}
+
+// migrateRedirectStatus 将已有短链接的启用状态与有效期同步到 redirect,可以重复执行
+func migrateRedirectStatus(db *gorm.DB) {
+	for i := 0; i < model.ShortLinkShardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", model.ShortLinkPrefix, i)
+		links := make([]*model.ShortLink, 0)
+		err := db.Table(tableName).
+			Select("id", "uri", "gid", "enable", "valid_date_type", "valid_time").
+			Where("enable = 0 OR valid_date_type > 0").
+			FindInBatches(&links, 500, func(tx *gorm.DB, batch int) error {
+				for _, sl := range links {
+					redirect := model.NewRedirect(sl)
+					err := db.Table(redirect.TName()).Where("uri = ?", sl.Uri).
+						Select("disabled", "expire_at").Updates(redirect).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+		if err != nil {
+			logger.Panic(err.Error())
+		}
+	}
+}
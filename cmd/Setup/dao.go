@@ -15,6 +15,16 @@ var fns = []generateTables{
 	generateTableFunc(model.TUser{}, model.TUserPrefix, model.TUserShardingNum),
 	generateTableFunc(model.ShortLink{}, model.ShortLinkPrefix, model.ShortLinkShardingNum),
 	generateTableFunc(model.Redirect{}, model.RedirectPrefix, model.RedirectShardingNum),
+	generateTableFunc(model.Tag{}, model.TagPrefix, model.TagShardingNum),
+	generateTableFunc(model.LinkTag{}, model.LinkTagPrefix, model.LinkTagShardingNum),
+	generateTableFunc(model.RecycleBin{}, model.RecycleBinPrefix, model.RecycleBinShardingNum),
+	generateTableFunc(model.ShortLinkHistory{}, model.ShortLinkHistoryPrefix, model.ShortLinkHistoryShardingNum),
+	generateTableFunc(model.GroupDeleteTask{}, model.GroupDeleteTaskPrefix, model.GroupDeleteTaskShardingNum),
+	generateTableFunc(model.GroupMember{}, model.GroupMemberPrefix, model.GroupMemberShardingNum),
+	generateTableFunc(model.AccessToken{}, model.AccessTokenPrefix, model.AccessTokenShardingNum),
+	generateTableFunc(model.UserIdentity{}, model.UserIdentityPrefix, model.UserIdentityShardingNum),
+	generateTableFunc(model.UserContact{}, model.UserContactPrefix, model.UserContactShardingNum),
+	generateTableFunc(model.LinkAccessStatisticBasic{}, model.LinkAccessStatisticBasicPrefix, model.LinkAccessStatisticBasicShardingNum),
 	//generateTableFunc(model.LinkAccessRecord{}, model.LinkAccessRecordPrefix, model.LinkAccessRecordShardingNum),
 	//generateTableFunc(model.LinkAccessStatistic{}, model.LinkAccessStatisticPrefix, model.LinkAccessStatisticShardingNum),
 }
@@ -34,6 +44,10 @@ func daoInit() {
 		}(fn, DB)
 	}
 	wg.Wait()
+
+	migrateColumns(DB)
+	migrateUserContact(DB)
+	migrateShortLinkStats(DB)
 }
 
 func generateTableFunc(table interface{}, prefix string, shardingNum int) generateTables {
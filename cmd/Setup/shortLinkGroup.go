@@ -0,0 +1,46 @@
+package main
+
+import (
+	"SnapLink/internal/model"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// migrateDefaultGroup 为没有默认分组的已有用户创建默认分组,可以重复执行
+func migrateDefaultGroup(db *gorm.DB) {
+	for i := 0; i < model.TUserShardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", model.TUserPrefix, i)
+		users := make([]*model.TUser, 0)
+		err := db.Table(tableName).Select("id", "username").FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+			for _, u := range users {
+				if err := createDefaultGroup(db, u.Username); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			logger.Panic(err.Error())
+		}
+	}
+}
+
+func createDefaultGroup(db *gorm.DB, username string) error {
+	group := &model.ShortLinkGroup{
+		Gid:       uuid.NewString(),
+		Name:      model.DefaultGroupName,
+		CUsername: username,
+		IsDefault: true,
+	}
+	var count int64
+	err := db.Table(group.TName()).Model(&model.ShortLinkGroup{}).
+		Where("c_username = ? AND is_default = ?", username, true).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return db.Table(group.TName()).Create(group).Error
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/elasticsearch"
+	"SnapLink/internal/model"
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// statsBefore 回填累计访问统计的截止日期,应为开始在 redis 中记录访问统计的日期,为空时不回填
+// 该日期之前的访问日志从 ES 中计入累计统计,pv 只会计入一次
+var statsBefore = flag.String("stats-before", "", "backfill lifetime short link stats from access logs before this date, e.g. 2024-01-02")
+
+// migrateShortLinkStats 回填短链接的累计访问统计,并将全部短链接写入分组排行榜,可以重复执行
+// 按访问统计分页时不再需要在请求中补全累计排行榜
+func migrateShortLinkStats(db *gorm.DB) {
+	ctx := context.Background()
+	for i := 0; i < model.ShortLinkShardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", model.ShortLinkPrefix, i)
+		links := make([]*model.ShortLink, 0)
+		err := db.Table(tableName).Select("id", "uri", "gid").FindInBatches(&links, 500, func(tx *gorm.DB, batch int) error {
+			groups := make(map[string][]string)
+			for _, sl := range links {
+				groups[sl.Gid] = append(groups[sl.Gid], sl.Uri)
+			}
+			for gid, uris := range groups {
+				if *statsBefore != "" {
+					if err := backfillShortLinkStats(ctx, uris, *statsBefore); err != nil {
+						return err
+					}
+				}
+				if err := cache.ShortLinkStats().RebuildRank(ctx, gid, uris); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			logger.Panic(err.Error())
+		}
+	}
+}
+
+// backfillShortLinkStats 将 uris 在 before 之前的访问日志计入累计统计
+func backfillShortLinkStats(ctx context.Context, uris []string, before string) error {
+	pvs, err := elasticsearch.AccessLogPV(ctx, uris, before)
+	if err != nil {
+		return err
+	}
+	if len(pvs) == 0 {
+		return nil
+	}
+	uids, err := elasticsearch.AccessLogDistinct(ctx, uris, "uid.keyword", before)
+	if err != nil {
+		return err
+	}
+	ips, err := elasticsearch.AccessLogDistinct(ctx, uris, "ip.keyword", before)
+	if err != nil {
+		return err
+	}
+	return cache.ShortLinkStats().Backfill(ctx, pvs, uids, ips)
+}
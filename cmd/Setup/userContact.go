@@ -0,0 +1,68 @@
+package main
+
+import (
+	"SnapLink/internal/model"
+	"SnapLink/internal/utils"
+	"fmt"
+
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateUserContact 为已有用户写入手机号与邮箱的全局唯一索引,并删除用户分表中手机号与邮箱的唯一索引
+// 可以重复执行;已被其他用户占用的联系方式不会写入,记录日志后需要人工处理
+func migrateUserContact(db *gorm.DB) {
+	for i := 0; i < model.TUserShardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", model.TUserPrefix, i)
+		dropShardUniqueIndex(db, tableName)
+
+		users := make([]*model.TUser, 0)
+		err := db.Table(tableName).Select("username", "phone", "mail").FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+			for _, u := range users {
+				backfillContact(db, model.ContactPhone, utils.NormalizePhone(u.Phone), u.Username)
+				backfillContact(db, model.ContactMail, utils.NormalizeMail(u.Mail), u.Username)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			logger.Panic(err.Error())
+		}
+	}
+}
+
+func backfillContact(db *gorm.DB, kind, value, username string) {
+	if value == "" {
+		return
+	}
+	contact := &model.UserContact{Kind: kind, Value: value, Username: username}
+	if err := db.Table(contact.TName()).Clauses(clause.OnConflict{DoNothing: true}).Create(contact).Error; err != nil {
+		logger.Panic(err.Error())
+	}
+	if contact.ID != 0 {
+		return
+	}
+	owner := new(model.UserContact)
+	if err := db.Table(contact.TName()).Where("kind = ? AND value = ?", kind, value).Take(owner).Error; err != nil {
+		logger.Panic(err.Error())
+	}
+	if owner.Username != username {
+		logger.Warn("联系方式已被其他用户占用", logger.String("kind", kind), logger.String("value", value),
+			logger.String("username", username), logger.String("owner", owner.Username))
+	}
+}
+
+// dropShardUniqueIndex 删除旧版本在用户分表中为手机号与邮箱创建的唯一索引,空值的用户需要共存
+func dropShardUniqueIndex(db *gorm.DB, tableName string) {
+	query := `SELECT DISTINCT index_name FROM information_schema.statistics
+		WHERE table_schema = ? AND table_name = ? AND non_unique = 0 AND column_name IN ('phone', 'mail')`
+	indexes := make([]string, 0)
+	if err := db.Raw(query, db.Migrator().CurrentDatabase(), tableName).Scan(&indexes).Error; err != nil {
+		logger.Panic(err.Error())
+	}
+	for _, index := range indexes {
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` DROP INDEX `%s`", tableName, index)).Error; err != nil {
+			logger.Panic(err.Error())
+		}
+	}
+}
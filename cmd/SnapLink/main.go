@@ -17,6 +17,7 @@ import (
 // @description Type "Bearer your-jwt-token" to Value
 func main() {
 	initial.Config()
+	initial.BootstrapAdmin()
 	servers := initial.RegisterServers()
 	closes := initial.RegisterClose(servers)
 	a := app.New(servers, closes)
@@ -0,0 +1,124 @@
+// Package authz 短链接与分组的访问控制
+// 短链接与分组本身不记录所属用户,需要通过 uri → gid → 分组创建人/分组成员 解析出用户在资源上的角色
+package authz
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/model"
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrForbidden 资源存在,但当前用户没有所需的角色
+var ErrForbidden = errors.New("access forbidden")
+
+// Authorizer 校验用户对资源的访问权限,role 为操作所需的最低角色
+// 资源不存在时返回 custom_err.ErrRecordNotFound,无权访问时返回 ErrForbidden
+type Authorizer interface {
+	// AuthorizeGroup 校验用户在分组中的角色
+	AuthorizeGroup(ctx context.Context, username, gid, role string) (*model.ShortLinkGroup, error)
+	// AuthorizeGroups 批量校验用户在分组中的角色
+	AuthorizeGroups(ctx context.Context, username string, gids []string, role string) error
+	// AuthorizeShortLink 校验用户在短链接所在分组中的角色
+	AuthorizeShortLink(ctx context.Context, username, uri, role string) (*model.ShortLink, error)
+	// AuthorizeRecycled 校验用户在回收站中的短链接删除前所在分组中的角色
+	AuthorizeRecycled(ctx context.Context, username, uri, role string) (*model.RecycleBin, error)
+	// AuthorizeRecycleGroup 校验用户能否访问分组的回收站,分组被删除后只有其创建人可以访问
+	AuthorizeRecycleGroup(ctx context.Context, username, gid, role string) error
+	// GroupRole 获取用户在分组中的角色,不是分组成员时返回 ErrForbidden
+	GroupRole(ctx context.Context, username string, group *model.ShortLinkGroup) (string, error)
+}
+
+type authorizer struct {
+	linkDao  dao.IShortLinkDao
+	groupDao dao.ShortLinkGroupDao
+}
+
+// NewAuthorizer creating the authorizer interface
+func NewAuthorizer(linkDao dao.IShortLinkDao, groupDao dao.ShortLinkGroupDao) Authorizer {
+	return &authorizer{
+		linkDao:  linkDao,
+		groupDao: groupDao,
+	}
+}
+
+func (a *authorizer) AuthorizeGroup(ctx context.Context, username, gid, role string) (*model.ShortLinkGroup, error) {
+	group, err := a.groupDao.GetByGid(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := a.GroupRole(ctx, username, group)
+	if err != nil {
+		return nil, err
+	}
+	if !model.GroupRoleCovers(actual, role) {
+		return nil, ErrForbidden
+	}
+	return group, nil
+}
+
+func (a *authorizer) AuthorizeGroups(ctx context.Context, username string, gids []string, role string) error {
+	checked := make(map[string]struct{}, len(gids))
+	for _, gid := range gids {
+		if _, ok := checked[gid]; ok {
+			continue
+		}
+		if _, err := a.AuthorizeGroup(ctx, username, gid, role); err != nil {
+			return err
+		}
+		checked[gid] = struct{}{}
+	}
+	return nil
+}
+
+func (a *authorizer) AuthorizeShortLink(ctx context.Context, username, uri, role string) (*model.ShortLink, error) {
+	sl, err := a.linkDao.GetByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = a.AuthorizeGroup(ctx, username, sl.Gid, role); err != nil {
+		return nil, err
+	}
+	return sl, nil
+}
+
+func (a *authorizer) AuthorizeRecycled(ctx context.Context, username, uri, role string) (*model.RecycleBin, error) {
+	recycled, err := a.linkDao.GetRecycled(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.AuthorizeRecycleGroup(ctx, username, recycled.Gid, role); err != nil {
+		return nil, err
+	}
+	return recycled, nil
+}
+
+func (a *authorizer) AuthorizeRecycleGroup(ctx context.Context, username, gid, role string) error {
+	_, err := a.AuthorizeGroup(ctx, username, gid, role)
+	if !errors.Is(err, custom_err.ErrRecordNotFound) {
+		return err
+	}
+	// 分组被删除后成员关系不再有效,通过删除任务确认用户是分组的创建人
+	_, err = a.groupDao.GetDeleteTask(ctx, gid, username)
+	return err
+}
+
+func (a *authorizer) GroupRole(ctx context.Context, username string, group *model.ShortLinkGroup) (string, error) {
+	if group.CUsername == username {
+		return model.GroupRoleOwner, nil
+	}
+	// 只有接受了邀请的成员才拥有角色
+	member, err := a.groupDao.GetMember(ctx, group.Gid, username)
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			return "", ErrForbidden
+		}
+		return "", err
+	}
+	if member.Status != model.GroupMemberAccepted {
+		return "", ErrForbidden
+	}
+	return member.Role, nil
+}
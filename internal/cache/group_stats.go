@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 分组访问统计
+// 每次访问同时计入 短链接/整个分组 × 小时/整天 四个统计桶,每个桶维护 pv、uv、uip 与地区、设备的分布
+// 被访问过的桶记录在待落库集合中,由分组统计服务定期写入按 gid 分表的 LinkAccessStatisticBasic
+const (
+	GroupStatsPrefix = "group_stats"
+	// GroupStatsExpireTime 统计桶的过期时间,需要大于一天以保证整天的统计完整
+	GroupStatsExpireTime = 48 * time.Hour
+	// UnknownStatsDimension 无法识别的地区与设备
+	UnknownStatsDimension = "unknown"
+)
+
+// groupStatsRecordScript 记录一次访问
+// KEYS[1]: 待落库集合, KEYS[2..]: 每个统计桶依次为 pv, uv, uip, 地区, 设备 共 5 个 key
+// ARGV: uid, ip, 地区, 设备, 过期时间(秒), 统计桶标识...
+var groupStatsRecordScript = redis.NewScript(`
+	for i = 6, #ARGV do
+		local base = 1 + (i - 6) * 5
+		redis.call('HINCRBY', KEYS[base + 1], 'pv', 1)
+		redis.call('PFADD', KEYS[base + 2], ARGV[1])
+		redis.call('PFADD', KEYS[base + 3], ARGV[2])
+		redis.call('HINCRBY', KEYS[base + 4], ARGV[3], 1)
+		redis.call('HINCRBY', KEYS[base + 5], ARGV[4], 1)
+		for j = base + 1, base + 5 do
+			redis.call('EXPIRE', KEYS[j], ARGV[5])
+		end
+		redis.call('SADD', KEYS[1], ARGV[i])
+	end
+	return 1
+`)
+
+var groupStatsInstance = new(groupStatsCache)
+
+func GroupStats() *groupStatsCache {
+	groupStatsInstance.once.Do(func() {
+		groupStatsInstance.client = model.GetRedisCli()
+	})
+	return groupStatsInstance
+}
+
+type groupStatsCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// Record 记录一次访问,region 与 device 为空时记为 UnknownStatsDimension
+func (c *groupStatsCache) Record(ctx context.Context, gid, uri, uid, ip, region, device string, at time.Time) error {
+	if region == "" {
+		region = UnknownStatsDimension
+	}
+	if device == "" {
+		device = UnknownStatsDimension
+	}
+	date := at.Format("2006-01-02")
+	buckets := []string{
+		groupStatsBucket(date, at.Hour(), gid, uri),
+		groupStatsBucket(date, model.StatisticWholeDay, gid, uri),
+		groupStatsBucket(date, at.Hour(), gid, ""),
+		groupStatsBucket(date, model.StatisticWholeDay, gid, ""),
+	}
+	keys := make([]string, 0, len(buckets)*5+1)
+	keys = append(keys, groupStatsDirtyKey())
+	args := []interface{}{uid, ip, region, device, int(GroupStatsExpireTime.Seconds())}
+	for _, bucket := range buckets {
+		key := groupStatsKey(bucket)
+		keys = append(keys, key, key+":uv", key+":uip", key+":regions", key+":devices")
+		args = append(args, bucket)
+	}
+	if err := groupStatsRecordScript.Run(ctx, c.client, keys, args...).Err(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("record group stats failed, gid: %s, uri: %s", gid, uri))
+	}
+	return nil
+}
+
+// PopDirty 取出最多 count 个待落库的统计桶
+func (c *groupStatsCache) PopDirty(ctx context.Context, count int64) ([]string, error) {
+	buckets, err := c.client.SPopN(ctx, groupStatsDirtyKey(), count).Result()
+	if err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, "pop dirty group stats failed")
+	}
+	return buckets, nil
+}
+
+// Requeue 将落库失败的统计桶放回待落库集合
+func (c *groupStatsCache) Requeue(ctx context.Context, buckets []string) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(buckets))
+	for _, bucket := range buckets {
+		members = append(members, bucket)
+	}
+	return c.client.SAdd(ctx, groupStatsDirtyKey(), members...).Err()
+}
+
+// Load 批量读取统计桶的当前数据,已过期或格式错误的统计桶被忽略
+func (c *groupStatsCache) Load(ctx context.Context, buckets []string) ([]*model.LinkAccessStatisticBasic, error) {
+	type cmds struct {
+		stat             *model.LinkAccessStatisticBasic
+		pv               *redis.StringCmd
+		uv, uip          *redis.IntCmd
+		regions, devices *redis.StringStringMapCmd
+	}
+	pending := make([]*cmds, 0, len(buckets))
+	pipe := c.client.Pipeline()
+	for _, bucket := range buckets {
+		stat, ok := parseGroupStatsBucket(bucket)
+		if !ok {
+			continue
+		}
+		key := groupStatsKey(bucket)
+		pending = append(pending, &cmds{
+			stat:    stat,
+			pv:      pipe.HGet(ctx, key, "pv"),
+			uv:      pipe.PFCount(ctx, key+":uv"),
+			uip:     pipe.PFCount(ctx, key+":uip"),
+			regions: pipe.HGetAll(ctx, key+":regions"),
+			devices: pipe.HGetAll(ctx, key+":devices"),
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, "load group stats failed")
+	}
+	result := make([]*model.LinkAccessStatisticBasic, 0, len(pending))
+	for _, p := range pending {
+		pv, err := p.pv.Int64()
+		if err != nil {
+			// 统计桶已经过期
+			continue
+		}
+		p.stat.Pv = pv
+		p.stat.Uv = p.uv.Val()
+		p.stat.Uip = p.uip.Val()
+		if p.stat.Regions, err = marshalCounter(p.regions.Val()); err != nil {
+			return nil, err
+		}
+		if p.stat.Devices, err = marshalCounter(p.devices.Val()); err != nil {
+			return nil, err
+		}
+		result = append(result, p.stat)
+	}
+	return result, nil
+}
+
+// marshalCounter 将 redis hash 中的计数转换为 json 对象
+func marshalCounter(values map[string]string) ([]byte, error) {
+	counter := make(map[string]int64, len(values))
+	for k, v := range values {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		counter[k] = n
+	}
+	return json.Marshal(counter)
+}
+
+func groupStatsDirtyKey() string {
+	return fmt.Sprintf("%s:dirty", GroupStatsPrefix)
+}
+
+func groupStatsKey(bucket string) string {
+	return fmt.Sprintf("%s:%s", GroupStatsPrefix, bucket)
+}
+
+// groupStatsBucket 统计桶标识,格式为 date|hour|gid|uri,uri 为空时为整个分组
+func groupStatsBucket(date string, hour int, gid, uri string) string {
+	return fmt.Sprintf("%s|%d|%s|%s", date, hour, gid, uri)
+}
+
+func parseGroupStatsBucket(bucket string) (*model.LinkAccessStatisticBasic, bool) {
+	parts := strings.SplitN(bucket, "|", 4)
+	if len(parts) != 4 {
+		return nil, false
+	}
+	hour, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, false
+	}
+	return &model.LinkAccessStatisticBasic{
+		Date: parts[0],
+		Hour: hour,
+		Gid:  parts[2],
+		URI:  parts[3],
+	}, true
+}
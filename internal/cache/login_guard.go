@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 登录失败计数与锁定
+// 账号与 ip 分别计数,账号连续失败超过免等待次数后需要等待一段时间才能重试,等待时间逐次翻倍;
+// 失败次数达到上限后锁定,锁定到期自动解除,账号与 ip 的锁定也可以由管理员解除
+// 计数与是否存在该账号无关,不存在的账号同样会被锁定,避免通过锁定行为判断账号是否存在
+const LoginGuardPrefix = "login"
+
+// loginFailScript 记录一次登录失败
+// KEYS[1]: 账号失败次数, KEYS[2]: ip 失败次数, KEYS[3]: 账号锁定, KEYS[4]: ip 锁定
+// ARGV: 统计窗口(秒), 账号失败上限, ip 失败上限, 锁定时长(秒), 免等待次数, 最长等待时间(秒)
+// 返回 {账号失败次数, ip 失败次数, 账号是否被锁定, ip 是否被锁定}
+var loginFailScript = redis.NewScript(`
+	local account = redis.call('INCR', KEYS[1])
+	if account == 1 then
+		redis.call('EXPIRE', KEYS[1], ARGV[1])
+	end
+	local ip = redis.call('INCR', KEYS[2])
+	if ip == 1 then
+		redis.call('EXPIRE', KEYS[2], ARGV[1])
+	end
+	local accountLocked, ipLocked = 0, 0
+	if account >= tonumber(ARGV[2]) then
+		redis.call('SET', KEYS[3], 'locked', 'EX', ARGV[4])
+		redis.call('DEL', KEYS[1])
+		accountLocked = 1
+	elseif account > tonumber(ARGV[5]) then
+		local delay = math.min(math.floor(2 ^ (account - tonumber(ARGV[5]) - 1)), tonumber(ARGV[6]))
+		redis.call('SET', KEYS[3], 'delay', 'EX', delay)
+	end
+	if ip >= tonumber(ARGV[3]) then
+		redis.call('SET', KEYS[4], 'locked', 'EX', ARGV[4])
+		redis.call('DEL', KEYS[2])
+		ipLocked = 1
+	end
+	return {account, ip, accountLocked, ipLocked}
+`)
+
+// LoginPolicy 登录失败的限制策略
+type LoginPolicy struct {
+	Window             time.Duration // 失败次数的统计窗口
+	Lockout            time.Duration // 锁定时长
+	MaxDelay           time.Duration // 单次等待的最长时间
+	MaxAccountFailures int           // 账号失败次数上限
+	MaxIPFailures      int           // ip 失败次数上限
+	FreeFailures       int           // 免等待的失败次数
+}
+
+// LoginFailure 登录失败后的计数与锁定情况
+type LoginFailure struct {
+	AccountFailures int64
+	IPFailures      int64
+	AccountLocked   bool
+	IPLocked        bool
+}
+
+var loginGuardInstance = new(loginGuardCache)
+
+func LoginGuard() *loginGuardCache {
+	loginGuardInstance.once.Do(func() {
+		loginGuardInstance.client = model.GetRedisCli()
+	})
+	return loginGuardInstance
+}
+
+type loginGuardCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// Check 返回账号或 ip 需要等待的时间,为 0 时可以尝试登录
+func (c *loginGuardCache) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	pipe := c.client.Pipeline()
+	accountTTL := pipe.PTTL(ctx, loginLockKey("user", username))
+	ipTTL := pipe.PTTL(ctx, loginLockKey("ip", ip))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("check login lock failed, username: %s", username))
+	}
+	wait := accountTTL.Val()
+	if ipTTL.Val() > wait {
+		wait = ipTTL.Val()
+	}
+	// 不存在的 key 返回负数
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, nil
+}
+
+// Fail 记录一次登录失败
+func (c *loginGuardCache) Fail(ctx context.Context, username, ip string, policy LoginPolicy) (*LoginFailure, error) {
+	keys := []string{
+		loginFailKey("user", username),
+		loginFailKey("ip", ip),
+		loginLockKey("user", username),
+		loginLockKey("ip", ip),
+	}
+	args := []interface{}{
+		int(policy.Window.Seconds()),
+		policy.MaxAccountFailures,
+		policy.MaxIPFailures,
+		int(policy.Lockout.Seconds()),
+		policy.FreeFailures,
+		int(policy.MaxDelay.Seconds()),
+	}
+	result, err := loginFailScript.Run(ctx, c.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("record login failure failed, username: %s", username))
+	}
+	return &LoginFailure{
+		AccountFailures: result[0],
+		IPFailures:      result[1],
+		AccountLocked:   result[2] == 1,
+		IPLocked:        result[3] == 1,
+	}, nil
+}
+
+// Succeed 登录成功后清除账号的失败次数,ip 的失败次数保留到统计窗口结束
+func (c *loginGuardCache) Succeed(ctx context.Context, username string) error {
+	return c.Unlock(ctx, username)
+}
+
+// Unlock 解除账号的锁定并清除失败次数
+func (c *loginGuardCache) Unlock(ctx context.Context, username string) error {
+	err := c.client.Del(ctx, loginFailKey("user", username), loginLockKey("user", username)).Err()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unlock login failed, username: %s", username))
+	}
+	return nil
+}
+
+// UnlockIP 解除 ip 的锁定并清除失败次数
+func (c *loginGuardCache) UnlockIP(ctx context.Context, ip string) error {
+	err := c.client.Del(ctx, loginFailKey("ip", ip), loginLockKey("ip", ip)).Err()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unlock login failed, ip: %s", ip))
+	}
+	return nil
+}
+
+func loginFailKey(kind, id string) string {
+	return fmt.Sprintf("%s:fail:%s:%s", LoginGuardPrefix, kind, id)
+}
+
+func loginLockKey(kind, id string) string {
+	return fmt.Sprintf("%s:lock:%s:%s", LoginGuardPrefix, kind, id)
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 邮箱验证、重置密码等一次性令牌
+// 同一用户同一用途只保留最新签发的令牌,令牌使用后立即删除
+const OneTimeTokenPrefix = "onetime"
+
+// consumeOneTimeScript 使用一次性令牌
+// KEYS[1]: 令牌, 返回令牌保存的内容,令牌不存在时返回 false
+var consumeOneTimeScript = redis.NewScript(`
+	local value = redis.call('GET', KEYS[1])
+	if not value then
+		return false
+	end
+	redis.call('DEL', KEYS[1])
+	return value
+`)
+
+var oneTimeTokenInstance = new(oneTimeTokenCache)
+
+func OneTimeToken() *oneTimeTokenCache {
+	oneTimeTokenInstance.once.Do(func() {
+		oneTimeTokenInstance.client = model.GetRedisCli()
+	})
+	return oneTimeTokenInstance
+}
+
+type oneTimeTokenCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// Save 保存令牌,并使该用户同一用途的旧令牌失效
+func (c *oneTimeTokenCache) Save(ctx context.Context, purpose, username, id, value string, ttl time.Duration) error {
+	userKey := oneTimeUserKey(purpose, username)
+	old, err := c.client.GetSet(ctx, userKey, id).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return errors.Wrap(err, fmt.Sprintf("save one-time token failed, username: %s", username))
+	}
+	pipe := c.client.TxPipeline()
+	if old != "" {
+		pipe.Del(ctx, oneTimeTokenKey(purpose, old))
+	}
+	pipe.Expire(ctx, userKey, ttl)
+	pipe.Set(ctx, oneTimeTokenKey(purpose, id), value, ttl)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("save one-time token failed, username: %s", username))
+	}
+	return nil
+}
+
+// Consume 使用令牌并返回保存的内容,令牌不存在或已被使用时返回 false
+func (c *oneTimeTokenCache) Consume(ctx context.Context, purpose, id string) (string, bool, error) {
+	value, err := consumeOneTimeScript.Run(ctx, c.client, []string{oneTimeTokenKey(purpose, id)}).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrap(err, fmt.Sprintf("consume one-time token failed, purpose: %s", purpose))
+	}
+	return value, true, nil
+}
+
+// Cooldown 限制发送频率,间隔内已经发送过时返回 false
+func (c *oneTimeTokenCache) Cooldown(ctx context.Context, purpose, username string, interval time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, fmt.Sprintf("%s:cooldown:%s:%s", OneTimeTokenPrefix, purpose, username), 1, interval).Result()
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("check one-time token cooldown failed, username: %s", username))
+	}
+	return ok, nil
+}
+
+func oneTimeTokenKey(purpose, id string) string {
+	return fmt.Sprintf("%s:%s:%s", OneTimeTokenPrefix, purpose, id)
+}
+
+func oneTimeUserKey(purpose, username string) string {
+	return fmt.Sprintf("%s:%s:user:%s", OneTimeTokenPrefix, purpose, username)
+}
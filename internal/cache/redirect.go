@@ -74,6 +74,14 @@ func (c *redirectsCache) Del(ctx context.Context, uri string) error {
 	return nil
 }
 
+// MDel 批量删除缓存
+func (c *redirectsCache) MDel(ctx context.Context, uris ...string) error {
+	if err := c.kvCache.MDel(ctx, uris...); err != nil {
+		return errors.Wrap(custom_err.ErrCacheDelFailed, err.Error())
+	}
+	return nil
+}
+
 // SetCacheWithNotFound 设置不存在的缓存，以防止缓存穿透，默认过期时间 10 分钟
 func (c *redirectsCache) SetCacheWithNotFound(ctx context.Context, uri string) error {
 	if err := c.kvCache.SetCacheWithNotFound(ctx, uri, RedirectsExpireTime); err != nil {
@@ -0,0 +1,399 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 短链接访问统计
+// 每个短链接维护累计与当日两套计数: pv 使用 hash 计数, uv 与 uip 使用 hyperloglog 去重计数
+// 同时以分组为单位维护排行榜(zset),用于按访问量对分组内的短链接进行排序分页
+const (
+	ShortLinkStatsPrefix = "sl_stats"
+	ShortLinkRankPrefix  = "sl_rank"
+	// ShortLinkDayStatsExpireTime 当日统计数据的过期时间,保留到第二天以便跨天查询
+	ShortLinkDayStatsExpireTime = 48 * time.Hour
+	// rankRebuildBatchSize 重建排行榜时每次提交给 lua 脚本的 uri 数量
+	rankRebuildBatchSize = 500
+)
+
+// 排序方式,与短链接分页查询的 orderTag 参数对应
+const (
+	OrderTagTodayPV  = "todayPv"
+	OrderTagTodayUV  = "todayUv"
+	OrderTagTodayUIP = "todayUip"
+	OrderTagTotalPV  = "totalPv"
+	OrderTagTotalUV  = "totalUv"
+	OrderTagTotalUIP = "totalUip"
+)
+
+// rankKeyIndex orderTag 对应 rankKeys 返回值中的下标
+var rankKeyIndex = map[string]int{
+	OrderTagTotalPV:  0,
+	OrderTagTotalUV:  1,
+	OrderTagTotalUIP: 2,
+	OrderTagTodayPV:  3,
+	OrderTagTodayUV:  4,
+	OrderTagTodayUIP: 5,
+}
+
+// IsRankOrderTag 是否为基于访问统计的排序方式
+func IsRankOrderTag(orderTag string) bool {
+	_, ok := rankKeyIndex[orderTag]
+	return ok
+}
+
+// IsDayRankOrderTag 是否为基于当日访问统计的排序方式
+func IsDayRankOrderTag(orderTag string) bool {
+	index, ok := rankKeyIndex[orderTag]
+	return ok && index >= 3
+}
+
+var (
+	// recordScript 记录一次访问,同时更新累计、当日计数与分组排行榜
+	// KEYS[1..6]: 累计 pv/uv/uip, 当日 pv/uv/uip
+	// KEYS[7..12]: 分组排行榜 累计 pv/uv/uip, 当日 pv/uv/uip
+	// ARGV: uri, uid, ip, 当日数据的过期时间(秒)
+	recordScript = redis.NewScript(`
+		local uri = ARGV[1]
+		local ttl = ARGV[4]
+		for i = 0, 3, 3 do
+			local pv = redis.call('HINCRBY', KEYS[i + 1], 'pv', 1)
+			redis.call('PFADD', KEYS[i + 2], ARGV[2])
+			redis.call('PFADD', KEYS[i + 3], ARGV[3])
+			redis.call('ZADD', KEYS[i + 7], pv, uri)
+			redis.call('ZADD', KEYS[i + 8], redis.call('PFCOUNT', KEYS[i + 2]), uri)
+			redis.call('ZADD', KEYS[i + 9], redis.call('PFCOUNT', KEYS[i + 3]), uri)
+		end
+		for _, i in ipairs({4, 5, 6, 10, 11, 12}) do
+			redis.call('EXPIRE', KEYS[i], ttl)
+		end
+		return 1
+	`)
+	// rebuildRankScript 根据短链接自身的计数重建分组排行榜,未被访问过的短链接分数为 0
+	// KEYS[1..6]: 分组排行榜 累计 pv/uv/uip, 当日 pv/uv/uip
+	// KEYS[7..]: 每个 uri 依次为 累计 pv/uv/uip, 当日 pv/uv/uip 共 6 个计数 key
+	// ARGV: 当日数据的过期时间(秒), uri...
+	rebuildRankScript = redis.NewScript(`
+		for i = 2, #ARGV do
+			local uri = ARGV[i]
+			local base = 6 + (i - 2) * 6
+			for offset = 0, 3, 3 do
+				redis.call('ZADD', KEYS[offset + 1], tonumber(redis.call('HGET', KEYS[base + offset + 1], 'pv') or 0), uri)
+				redis.call('ZADD', KEYS[offset + 2], redis.call('PFCOUNT', KEYS[base + offset + 2]), uri)
+				redis.call('ZADD', KEYS[offset + 3], redis.call('PFCOUNT', KEYS[base + offset + 3]), uri)
+			end
+		end
+		for i = 4, 6 do
+			redis.call('EXPIRE', KEYS[i], ARGV[1])
+		end
+		return 1
+	`)
+	// backfillScript 将历史访问次数计入累计 pv,以 backfilled 字段标记,重复执行时不会重复累加
+	// KEYS[1]: 累计 pv, ARGV[1]: 历史访问次数
+	backfillScript = redis.NewScript(`
+		if redis.call('HSETNX', KEYS[1], 'backfilled', 1) == 1 then
+			redis.call('HINCRBY', KEYS[1], 'pv', ARGV[1])
+		end
+		return 1
+	`)
+)
+
+var shortLinkStatsInstance = new(shortLinkStatsCache)
+
+func ShortLinkStats() *shortLinkStatsCache {
+	shortLinkStatsInstance.once.Do(func() {
+		shortLinkStatsInstance.client = model.GetRedisCli()
+	})
+	return shortLinkStatsInstance
+}
+
+type shortLinkStatsCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// LinkStats 短链接的访问统计
+type LinkStats struct {
+	TodayPV  int64
+	TodayUV  int64
+	TodayUIP int64
+	TotalPV  int64
+	TotalUV  int64
+	TotalUIP int64
+}
+
+// Metric orderTag 对应的统计值
+func (s *LinkStats) Metric(orderTag string) int64 {
+	switch orderTag {
+	case OrderTagTodayPV:
+		return s.TodayPV
+	case OrderTagTodayUV:
+		return s.TodayUV
+	case OrderTagTodayUIP:
+		return s.TodayUIP
+	case OrderTagTotalPV:
+		return s.TotalPV
+	case OrderTagTotalUV:
+		return s.TotalUV
+	case OrderTagTotalUIP:
+		return s.TotalUIP
+	default:
+		return 0
+	}
+}
+
+// Record 记录一次访问
+func (c *shortLinkStatsCache) Record(ctx context.Context, gid, uri, uid, ip string) error {
+	date := today()
+	total, day := totalStatsKey(uri), dayStatsKey(date, uri)
+	keys := []string{
+		total, total + ":uv", total + ":uip",
+		day, day + ":uv", day + ":uip",
+	}
+	keys = append(keys, rankKeys(gid, date)...)
+	err := recordScript.Run(ctx, c.client, keys, uri, uid, ip, int(ShortLinkDayStatsExpireTime.Seconds())).Err()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("record short link stats failed, uri: %s", uri))
+	}
+	return nil
+}
+
+// Get 批量获取短链接的访问统计,没有访问记录的短链接各项均为 0
+func (c *shortLinkStatsCache) Get(ctx context.Context, uris []string) (map[string]*LinkStats, error) {
+	date := today()
+	type cmds struct {
+		todayPV, totalPV                     *redis.StringCmd
+		todayUV, todayUIP, totalUV, totalUIP *redis.IntCmd
+	}
+	results := make([]cmds, len(uris))
+	pipe := c.client.Pipeline()
+	for i, uri := range uris {
+		total, day := totalStatsKey(uri), dayStatsKey(date, uri)
+		results[i] = cmds{
+			totalPV:  pipe.HGet(ctx, total, "pv"),
+			totalUV:  pipe.PFCount(ctx, total+":uv"),
+			totalUIP: pipe.PFCount(ctx, total+":uip"),
+			todayPV:  pipe.HGet(ctx, day, "pv"),
+			todayUV:  pipe.PFCount(ctx, day+":uv"),
+			todayUIP: pipe.PFCount(ctx, day+":uip"),
+		}
+	}
+	// 没有访问记录时 HGET 返回 redis.Nil,视为 0
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, errors.Wrap(err, "get short link stats failed")
+	}
+	stats := make(map[string]*LinkStats, len(uris))
+	for i, uri := range uris {
+		totalPV, _ := results[i].totalPV.Int64()
+		todayPV, _ := results[i].todayPV.Int64()
+		stats[uri] = &LinkStats{
+			TodayPV:  todayPV,
+			TodayUV:  results[i].todayUV.Val(),
+			TodayUIP: results[i].todayUIP.Val(),
+			TotalPV:  totalPV,
+			TotalUV:  results[i].totalUV.Val(),
+			TotalUIP: results[i].totalUIP.Val(),
+		}
+	}
+	return stats, nil
+}
+
+// Sum 汇总多个短链接的访问统计,pv 为各短链接之和,uv 与 uip 为合并去重后的数量
+func (c *shortLinkStatsCache) Sum(ctx context.Context, uris []string) (*LinkStats, error) {
+	sum := new(LinkStats)
+	if len(uris) == 0 {
+		return sum, nil
+	}
+	stats, err := c.Get(ctx, uris)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stats {
+		sum.TodayPV += s.TodayPV
+		sum.TotalPV += s.TotalPV
+	}
+	date := today()
+	var totalUV, totalUIP, todayUV, todayUIP []string
+	for _, uri := range uris {
+		total, day := totalStatsKey(uri), dayStatsKey(date, uri)
+		totalUV = append(totalUV, total+":uv")
+		totalUIP = append(totalUIP, total+":uip")
+		todayUV = append(todayUV, day+":uv")
+		todayUIP = append(todayUIP, day+":uip")
+	}
+	// 多个 key 的 PFCOUNT 返回并集的基数
+	pipe := c.client.Pipeline()
+	totalUVCmd := pipe.PFCount(ctx, totalUV...)
+	totalUIPCmd := pipe.PFCount(ctx, totalUIP...)
+	todayUVCmd := pipe.PFCount(ctx, todayUV...)
+	todayUIPCmd := pipe.PFCount(ctx, todayUIP...)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrap(err, "sum short link stats failed")
+	}
+	sum.TotalUV, sum.TotalUIP = totalUVCmd.Val(), totalUIPCmd.Val()
+	sum.TodayUV, sum.TodayUIP = todayUVCmd.Val(), todayUIPCmd.Val()
+	return sum, nil
+}
+
+// RankCard 分组排行榜中的短链接数量
+func (c *shortLinkStatsCache) RankCard(ctx context.Context, gid, orderTag string) (int64, error) {
+	index, ok := rankKeyIndex[orderTag]
+	if !ok {
+		return 0, errors.Errorf("unsupported order tag: %s", orderTag)
+	}
+	return c.client.ZCard(ctx, rankKeys(gid, today())[index]).Result()
+}
+
+// RankRange 按访问量从高到低获取分组排行榜中的 uri,访问量相同的按 uri 排序,保证分页稳定
+func (c *shortLinkStatsCache) RankRange(ctx context.Context, gid, orderTag string, offset, limit int) ([]string, error) {
+	index, ok := rankKeyIndex[orderTag]
+	if !ok {
+		return nil, errors.Errorf("unsupported order tag: %s", orderTag)
+	}
+	return c.client.ZRevRange(ctx, rankKeys(gid, today())[index], int64(offset), int64(offset+limit-1)).Result()
+}
+
+// RebuildRank 将 uris 按其自身的计数写入分组排行榜
+// 排行榜只在短链接被访问时写入,分页前需要把未被访问过的短链接补充进来
+func (c *shortLinkStatsCache) RebuildRank(ctx context.Context, gid string, uris []string) error {
+	date := today()
+	keys := rankKeys(gid, date)
+	for start := 0; start < len(uris); start += rankRebuildBatchSize {
+		end := start + rankRebuildBatchSize
+		if end > len(uris) {
+			end = len(uris)
+		}
+		batchKeys := make([]string, 0, len(keys)+(end-start)*6)
+		batchKeys = append(batchKeys, keys...)
+		args := make([]any, 0, end-start+1)
+		args = append(args, int(ShortLinkDayStatsExpireTime.Seconds()))
+		for _, uri := range uris[start:end] {
+			total, day := totalStatsKey(uri), dayStatsKey(date, uri)
+			batchKeys = append(batchKeys, total, total+":uv", total+":uip", day, day+":uv", day+":uip")
+			args = append(args, uri)
+		}
+		if err := rebuildRankScript.Run(ctx, c.client, batchKeys, args...).Err(); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("rebuild short link rank failed, gid: %s", gid))
+		}
+	}
+	return nil
+}
+
+// FillDayRank 将分组累计排行榜中的短链接以 0 分补充到当日排行榜,当日已有的分数不变
+// 当日排行榜每天重新开始,只包含当天被访问过的短链接;累计排行榜包含分组内全部短链接
+func (c *shortLinkStatsCache) FillDayRank(ctx context.Context, gid string) error {
+	keys := rankKeys(gid, today())
+	pipe := c.client.TxPipeline()
+	for i := 0; i < 3; i++ {
+		pipe.ZUnionStore(ctx, keys[i+3], &redis.ZStore{Keys: []string{keys[i+3], keys[i]}, Weights: []float64{1, 0}})
+		pipe.Expire(ctx, keys[i+3], ShortLinkDayStatsExpireTime)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("fill short link day rank failed, gid: %s", gid))
+	}
+	return nil
+}
+
+// Backfill 将历史访问日志计入短链接的累计统计,uv 与 uip 为去重计数,pv 只会计入一次
+// 只更新短链接自身的计数,排行榜需要随后通过 RebuildRank 更新
+func (c *shortLinkStatsCache) Backfill(ctx context.Context, pvs map[string]int64, uids, ips map[string][]string) error {
+	pipe := c.client.Pipeline()
+	for uri, values := range uids {
+		for _, members := range batchMembers(values) {
+			pipe.PFAdd(ctx, totalStatsKey(uri)+":uv", members...)
+		}
+	}
+	for uri, values := range ips {
+		for _, members := range batchMembers(values) {
+			pipe.PFAdd(ctx, totalStatsKey(uri)+":uip", members...)
+		}
+	}
+	// uv 与 uip 重复添加不影响结果,先写入;pv 最后写入,保证中断后重新执行时数据完整
+	for uri, pv := range pvs {
+		backfillScript.Eval(ctx, pipe, []string{totalStatsKey(uri)}, pv)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, "backfill short link stats failed")
+	}
+	return nil
+}
+
+// batchMembers 将 values 按 rankRebuildBatchSize 分批,避免单条命令过大
+func batchMembers(values []string) [][]any {
+	batches := make([][]any, 0, len(values)/rankRebuildBatchSize+1)
+	for start := 0; start < len(values); start += rankRebuildBatchSize {
+		end := start + rankRebuildBatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		members := make([]any, 0, end-start)
+		for _, v := range values[start:end] {
+			members = append(members, v)
+		}
+		batches = append(batches, members)
+	}
+	return batches
+}
+
+// RemoveRank 将 uris 从分组排行榜中移除,用于短链接删除或移动到其他分组
+func (c *shortLinkStatsCache) RemoveRank(ctx context.Context, gid string, uris ...string) error {
+	if len(uris) == 0 {
+		return nil
+	}
+	members := make([]any, len(uris))
+	for i, uri := range uris {
+		members[i] = uri
+	}
+	pipe := c.client.Pipeline()
+	for _, key := range rankKeys(gid, today()) {
+		pipe.ZRem(ctx, key, members...)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("remove short link rank failed, gid: %s", gid))
+	}
+	return nil
+}
+
+// Clear 删除短链接的全部计数,用于短链接被彻底删除、uri 可以被重新使用时
+func (c *shortLinkStatsCache) Clear(ctx context.Context, uri string) error {
+	total, day := totalStatsKey(uri), dayStatsKey(today(), uri)
+	err := c.client.Del(ctx, total, total+":uv", total+":uip", day, day+":uv", day+":uip").Err()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("clear short link stats failed, uri: %s", uri))
+	}
+	return nil
+}
+
+func today() string {
+	return time.Now().Format("20060102")
+}
+
+// totalStatsKey 累计计数的 key,uv 与 uip 分别追加 :uv 与 :uip 后缀
+func totalStatsKey(uri string) string {
+	return fmt.Sprintf("%s:total:%s", ShortLinkStatsPrefix, uri)
+}
+
+// dayStatsKey 当日计数的 key,uv 与 uip 分别追加 :uv 与 :uip 后缀
+func dayStatsKey(date, uri string) string {
+	return fmt.Sprintf("%s:day:%s:%s", ShortLinkStatsPrefix, date, uri)
+}
+
+// rankKeys 分组排行榜的 key,顺序为 累计 pv/uv/uip, 当日 pv/uv/uip
+func rankKeys(gid, date string) []string {
+	keys := make([]string, 0, 6)
+	for _, metric := range []string{"pv", "uv", "uip"} {
+		keys = append(keys, fmt.Sprintf("%s:total:%s:%s", ShortLinkRankPrefix, gid, metric))
+	}
+	for _, metric := range []string{"pv", "uv", "uip"} {
+		keys = append(keys, fmt.Sprintf("%s:day:%s:%s:%s", ShortLinkRankPrefix, date, gid, metric))
+	}
+	return keys
+}
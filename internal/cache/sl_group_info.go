@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/model"
+	cache2 "SnapLink/pkg/cache"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	GroupInfoExpireTime     = 1 * time.Hour
+	GroupInfoCachePrefixKey = "group_info"
+)
+
+var groupInfoInstance = new(groupInfoCache)
+
+// GroupInfo 以 gid 为 key 的分组信息缓存
+// 分组表按创建人分表,只知道 gid 时需要遍历全部分表,因此对查询结果进行缓存
+func GroupInfo() *groupInfoCache {
+	groupInfoInstance.once.Do(func() {
+		var err error
+		if groupInfoInstance.kvCache, err = cache2.NewKVCache(model.GetRedisCli(), cache2.NewKeyGenerator(GroupInfoCachePrefixKey), LocalCache()); err != nil {
+			logger.Panic(errors.Wrap(custom_err.ErrCacheInitFailed, "GroupInfoCache").Error())
+		}
+	})
+	return groupInfoInstance
+}
+
+var emptyGroupInfo = new(model.ShortLinkGroup)
+
+// groupInfoCache define a cache struct
+type groupInfoCache struct {
+	kvCache cache2.IKVCache
+	once    sync.Once
+}
+
+// Set write to cache
+func (c *groupInfoCache) Set(ctx context.Context, gid string, group *model.ShortLinkGroup) error {
+	jsonBytes, err := json.Marshal(group)
+	if err != nil {
+		return errors.Wrap(custom_err.ErrCacheSetFailed, err.Error())
+	}
+	if err = c.kvCache.Set(ctx, gid, string(jsonBytes), GroupInfoExpireTime); err != nil {
+		return errors.Wrap(custom_err.ErrCacheSetFailed, err.Error())
+	}
+	return nil
+}
+
+// Get 获取缓存,缓存的空值返回一个 Gid 为空的分组
+func (c *groupInfoCache) Get(ctx context.Context, gid string) (*model.ShortLinkGroup, error) {
+	value, err := c.kvCache.Get(ctx, gid)
+	if errors.Is(err, cache2.ErrKVCacheNotFound) {
+		return nil, custom_err.ErrCacheNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(custom_err.ErrCacheGetFailed, err.Error())
+	}
+	if value == cache2.EmptyValue {
+		return emptyGroupInfo, nil
+	}
+	group := new(model.ShortLinkGroup)
+	if err = json.Unmarshal([]byte(value), group); err != nil {
+		return nil, errors.Wrap(custom_err.ErrCacheGetFailed, err.Error())
+	}
+	return group, nil
+}
+
+// Del 删除缓存
+func (c *groupInfoCache) Del(ctx context.Context, gid string) error {
+	if err := c.kvCache.Del(ctx, gid); err != nil {
+		return errors.Wrap(custom_err.ErrCacheDelFailed, err.Error())
+	}
+	return nil
+}
+
+// SetCacheWithNotFound 设置不存在的缓存，以防止缓存穿透
+func (c *groupInfoCache) SetCacheWithNotFound(ctx context.Context, gid string) error {
+	if err := c.kvCache.SetCacheWithNotFound(ctx, gid, GroupInfoExpireTime); err != nil {
+		return errors.Wrap(custom_err.ErrCacheSetFailed, err.Error())
+	}
+	return nil
+}
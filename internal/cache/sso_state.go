@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 单点登录的授权请求,以 state 为键保存,回调时取出并删除,每个 state 只能使用一次
+const SSOStatePrefix = "sso:state"
+
+// SSOState 发起授权时生成的参数
+type SSOState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`           // PKCE 的 code_verifier
+	Username string `json:"username,omitempty"` // 关联身份时为发起关联的用户,登录时为空
+}
+
+var ssoStateInstance = new(ssoStateCache)
+
+func SSOStates() *ssoStateCache {
+	ssoStateInstance.once.Do(func() {
+		ssoStateInstance.client = model.GetRedisCli()
+	})
+	return ssoStateInstance
+}
+
+type ssoStateCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// Save 保存授权请求
+func (c *ssoStateCache) Save(ctx context.Context, state string, s *SSOState, ttl time.Duration) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "marshal sso state failed")
+	}
+	if err = c.client.Set(ctx, ssoStateKey(state), data, ttl).Err(); err != nil {
+		return errors.Wrap(err, "save sso state failed")
+	}
+	return nil
+}
+
+// Consume 取出并删除授权请求,state 不存在或已被使用时返回 false
+func (c *ssoStateCache) Consume(ctx context.Context, state string) (*SSOState, bool, error) {
+	data, err := consumeOneTimeScript.Run(ctx, c.client, []string{ssoStateKey(state)}).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(err, "consume sso state failed")
+	}
+	s := new(SSOState)
+	if err = json.Unmarshal([]byte(data), s); err != nil {
+		return nil, false, errors.Wrap(err, "unmarshal sso state failed")
+	}
+	return s, true, nil
+}
+
+func ssoStateKey(state string) string {
+	return fmt.Sprintf("%s:%s", SSOStatePrefix, state)
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 后台任务锁,防止多个实例同时处理同一个任务
+// 锁的值为持有者的唯一标识,续期与释放时先比较持有者,避免误操作其他实例在锁过期后重新获取的锁
+const TaskLockPrefix = "task_lock"
+
+// releaseTaskLockScript 释放锁
+// KEYS[1]: 锁, ARGV[1]: 持有者, 返回是否释放
+var releaseTaskLockScript = redis.NewScript(`
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`)
+
+// refreshTaskLockScript 续期锁
+// KEYS[1]: 锁, ARGV[1]: 持有者, ARGV[2]: 过期时间(毫秒), 返回是否续期
+var refreshTaskLockScript = redis.NewScript(`
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+var taskLockInstance = new(taskLockCache)
+
+func TaskLock() *taskLockCache {
+	taskLockInstance.once.Do(func() {
+		taskLockInstance.client = model.GetRedisCli()
+	})
+	return taskLockInstance
+}
+
+type taskLockCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+func taskLockKey(name string) string {
+	return fmt.Sprintf("%s:%s", TaskLockPrefix, name)
+}
+
+// Acquire 获取锁,锁已被占用时返回 false
+func (c *taskLockCache) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, taskLockKey(name), owner, ttl).Result()
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("acquire task lock failed, name: %s", name))
+	}
+	return ok, nil
+}
+
+// Refresh 续期锁,锁已不属于 owner 时返回 false
+func (c *taskLockCache) Refresh(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	n, err := refreshTaskLockScript.Run(ctx, c.client, []string{taskLockKey(name)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("refresh task lock failed, name: %s", name))
+	}
+	return n == 1, nil
+}
+
+// Release 释放锁,锁已不属于 owner 时不做任何操作
+func (c *taskLockCache) Release(ctx context.Context, name, owner string) error {
+	if err := releaseTaskLockScript.Run(ctx, c.client, []string{taskLockKey(name)}, owner).Err(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("release task lock failed, name: %s", name))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 令牌的吊销与刷新令牌的轮换
+// 同一次登录签发的令牌属于同一个令牌族(family),族的 key 中保存当前有效的刷新令牌 id,族被删除后其中的全部令牌失效
+// 被吊销的令牌 id 记录在吊销列表中,保留到令牌过期为止
+const TokenPrefix = "token"
+
+// rotateRefreshScript 轮换刷新令牌
+// KEYS[1]: 令牌族, KEYS[2]: 用户的令牌族集合 ARGV: 旧刷新令牌 id, 新刷新令牌 id, 过期时间(秒)
+// 返回 1 轮换成功, 0 令牌族不存在, -1 旧刷新令牌已被使用(令牌族被删除)
+var rotateRefreshScript = redis.NewScript(`
+	local current = redis.call('GET', KEYS[1])
+	if not current then
+		return 0
+	end
+	if current ~= ARGV[1] then
+		redis.call('DEL', KEYS[1])
+		return -1
+	end
+	redis.call('SET', KEYS[1], ARGV[2], 'EX', ARGV[3])
+	redis.call('EXPIRE', KEYS[2], ARGV[3])
+	return 1
+`)
+
+// 刷新令牌轮换的结果
+const (
+	RefreshRotated  = 1
+	RefreshNotFound = 0
+	RefreshReused   = -1
+)
+
+var tokenInstance = new(tokenCache)
+
+func Token() *tokenCache {
+	tokenInstance.once.Do(func() {
+		tokenInstance.client = model.GetRedisCli()
+	})
+	return tokenInstance
+}
+
+type tokenCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// CreateFamily 新建令牌族并记录到用户的令牌族集合中
+func (c *tokenCache) CreateFamily(ctx context.Context, uid, family, refreshID string, ttl time.Duration) error {
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, tokenFamilyKey(family), refreshID, ttl)
+	pipe.SAdd(ctx, tokenUserFamiliesKey(uid), family)
+	pipe.Expire(ctx, tokenUserFamiliesKey(uid), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("create token family failed, uid: %s", uid))
+	}
+	return nil
+}
+
+// RotateRefresh 将令牌族当前的刷新令牌从 oldID 替换为 newID
+func (c *tokenCache) RotateRefresh(ctx context.Context, uid, family, oldID, newID string, ttl time.Duration) (int, error) {
+	keys := []string{tokenFamilyKey(family), tokenUserFamiliesKey(uid)}
+	result, err := rotateRefreshScript.Run(ctx, c.client, keys, oldID, newID, int(ttl.Seconds())).Int()
+	if err != nil {
+		return RefreshNotFound, errors.Wrap(err, fmt.Sprintf("rotate refresh token failed, family: %s", family))
+	}
+	return result, nil
+}
+
+// RevokeFamily 删除令牌族
+func (c *tokenCache) RevokeFamily(ctx context.Context, uid, family string) error {
+	pipe := c.client.TxPipeline()
+	pipe.Del(ctx, tokenFamilyKey(family))
+	pipe.SRem(ctx, tokenUserFamiliesKey(uid), family)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("revoke token family failed, family: %s", family))
+	}
+	return nil
+}
+
+// RevokeAllFamilies 删除用户的全部令牌族
+func (c *tokenCache) RevokeAllFamilies(ctx context.Context, uid string) error {
+	families, err := c.client.SMembers(ctx, tokenUserFamiliesKey(uid)).Result()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("list token families failed, uid: %s", uid))
+	}
+	keys := make([]string, 0, len(families)+1)
+	for _, family := range families {
+		keys = append(keys, tokenFamilyKey(family))
+	}
+	keys = append(keys, tokenUserFamiliesKey(uid))
+	if err = c.client.Del(ctx, keys...).Err(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("revoke token families failed, uid: %s", uid))
+	}
+	return nil
+}
+
+// Revoke 将令牌 id 加入吊销列表,ttl 为令牌剩余的有效期
+func (c *tokenCache) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := c.client.Set(ctx, tokenRevokedKey(id), 1, ttl).Err(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("revoke token failed, id: %s", id))
+	}
+	return nil
+}
+
+// IsActive 令牌未被吊销且所属的令牌族仍然有效
+func (c *tokenCache) IsActive(ctx context.Context, id, family string) (bool, error) {
+	values, err := c.client.MGet(ctx, tokenRevokedKey(id), tokenFamilyKey(family)).Result()
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("check token failed, id: %s", id))
+	}
+	return values[0] == nil && values[1] != nil, nil
+}
+
+func tokenFamilyKey(family string) string {
+	return fmt.Sprintf("%s:family:%s", TokenPrefix, family)
+}
+
+func tokenUserFamiliesKey(uid string) string {
+	return fmt.Sprintf("%s:user:%s", TokenPrefix, uid)
+}
+
+func tokenRevokedKey(id string) string {
+	return fmt.Sprintf("%s:revoked:%s", TokenPrefix, id)
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// 两步验证
+// 登录时密码校验通过后签发短期有效的挑战令牌,用户提交验证码时凭挑战令牌找到对应的用户;
+// 挑战令牌的尝试次数有限,超过后失效,需要重新输入密码
+const TwoFactorPrefix = "2fa"
+
+// attemptChallengeScript 记录一次挑战令牌的尝试
+// KEYS[1]: 挑战令牌 ARGV[1]: 最多尝试次数
+// 返回用户名,令牌不存在或尝试次数超过上限时返回 false 并删除令牌
+var attemptChallengeScript = redis.NewScript(`
+	local username = redis.call('HGET', KEYS[1], 'username')
+	if not username then
+		return false
+	end
+	local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+	if attempts > tonumber(ARGV[1]) then
+		redis.call('DEL', KEYS[1])
+		return false
+	end
+	return username
+`)
+
+var twoFactorInstance = new(twoFactorCache)
+
+func TwoFactor() *twoFactorCache {
+	twoFactorInstance.once.Do(func() {
+		twoFactorInstance.client = model.GetRedisCli()
+	})
+	return twoFactorInstance
+}
+
+type twoFactorCache struct {
+	client *redis.Client
+	once   sync.Once
+}
+
+// CreateChallenge 保存挑战令牌
+func (c *twoFactorCache) CreateChallenge(ctx context.Context, id, username string, ttl time.Duration) error {
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, twoFactorChallengeKey(id), "username", username, "attempts", 0)
+	pipe.Expire(ctx, twoFactorChallengeKey(id), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("create 2fa challenge failed, username: %s", username))
+	}
+	return nil
+}
+
+// AttemptChallenge 使用挑战令牌尝试一次验证,返回令牌对应的用户名,令牌无效时返回 false
+func (c *twoFactorCache) AttemptChallenge(ctx context.Context, id string, maxAttempts int) (string, bool, error) {
+	username, err := attemptChallengeScript.Run(ctx, c.client, []string{twoFactorChallengeKey(id)}, maxAttempts).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrap(err, "attempt 2fa challenge failed")
+	}
+	return username, true, nil
+}
+
+// DeleteChallenge 验证成功后删除挑战令牌
+func (c *twoFactorCache) DeleteChallenge(ctx context.Context, id string) error {
+	return c.client.Del(ctx, twoFactorChallengeKey(id)).Err()
+}
+
+// SavePendingSecret 保存尚未确认的两步验证密钥,用户使用验证码确认后才启用
+func (c *twoFactorCache) SavePendingSecret(ctx context.Context, username, secret string, ttl time.Duration) error {
+	if err := c.client.Set(ctx, twoFactorPendingKey(username), secret, ttl).Err(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("save pending 2fa secret failed, username: %s", username))
+	}
+	return nil
+}
+
+// PendingSecret 获取尚未确认的两步验证密钥,不存在时返回空字符串
+func (c *twoFactorCache) PendingSecret(ctx context.Context, username string) (string, error) {
+	secret, err := c.client.Get(ctx, twoFactorPendingKey(username)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", errors.Wrap(err, fmt.Sprintf("get pending 2fa secret failed, username: %s", username))
+	}
+	return secret, nil
+}
+
+// DeletePendingSecret 删除尚未确认的两步验证密钥
+func (c *twoFactorCache) DeletePendingSecret(ctx context.Context, username string) error {
+	return c.client.Del(ctx, twoFactorPendingKey(username)).Err()
+}
+
+// UseStep 记录已使用的验证码步数,同一步的验证码只能使用一次,已使用时返回 false
+func (c *twoFactorCache) UseStep(ctx context.Context, username string, step int64, ttl time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, fmt.Sprintf("%s:used:%s:%d", TwoFactorPrefix, username, step), 1, ttl).Result()
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("record 2fa step failed, username: %s", username))
+	}
+	return ok, nil
+}
+
+func twoFactorChallengeKey(id string) string {
+	return fmt.Sprintf("%s:challenge:%s", TwoFactorPrefix, id)
+}
+
+func twoFactorPendingKey(username string) string {
+	return fmt.Sprintf("%s:pending:%s", TwoFactorPrefix, username)
+}
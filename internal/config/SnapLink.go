@@ -52,6 +52,12 @@ type Config struct {
 	RabbitMQ      RabbitMQ      `yaml:"rocketmq" json:"rocketmq"`
 	Sentinel      Sentinel      `yaml:"sentinel" json:"sentinel"`
 	Elasticsearch Elasticsearch `yaml:"elasticsearch" json:"elasticsearch"`
+	RecycleBin    RecycleBin    `yaml:"recycleBin" json:"recycleBin"`
+	Jwt           Jwt           `yaml:"jwt" json:"jwt"`
+	Admin         Admin         `yaml:"admin" json:"admin"`
+	Login         Login         `yaml:"login" json:"login"`
+	Mail          Mail          `yaml:"mail" json:"mail"`
+	Oidc          Oidc          `yaml:"oidc" json:"oidc"`
 }
 
 type Consul struct {
@@ -216,6 +222,196 @@ type Sentinel struct {
 	Nacos      Nacos  `yaml:"nacos" json:"nacos"`
 }
 
+// RecycleBin 回收站配置
+type RecycleBin struct {
+	RetentionDays int `yaml:"retentionDays" json:"retentionDays"` // 短链接在回收站中保留的天数,过期后自动彻底删除
+	PurgeInterval int `yaml:"purgeInterval" json:"purgeInterval"` // 清理过期短链接的间隔,单位(分钟)
+}
+
+// Retention 短链接在回收站中的保留时长,未配置时为 30 天
+func (r RecycleBin) Retention() time.Duration {
+	if r.RetentionDays <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(r.RetentionDays) * 24 * time.Hour
+}
+
+// Interval 清理过期短链接的间隔,未配置时为 1 小时
+func (r RecycleBin) Interval() time.Duration {
+	if r.PurgeInterval <= 0 {
+		return time.Hour
+	}
+	return time.Duration(r.PurgeInterval) * time.Minute
+}
+
+// Jwt 登录令牌配置
+// 轮换密钥时先在全部实例上加入新密钥(此时只用于校验),再将 activeKid 切换为新密钥,
+// 旧密钥在刷新令牌的有效期过后移除,期间已签发的令牌仍然可以通过校验
+type Jwt struct {
+	AccessExpire  int      `yaml:"accessExpire" json:"accessExpire"`   // 访问令牌的有效期,单位(分钟)
+	RefreshExpire int      `yaml:"refreshExpire" json:"refreshExpire"` // 刷新令牌的有效期,单位(小时)
+	ActiveKid     string   `yaml:"activeKid" json:"activeKid"`         // 签发令牌使用的密钥 id
+	Keys          []JwtKey `yaml:"keys" json:"keys"`                   // 可用于校验令牌的全部密钥
+}
+
+// JwtKey 令牌签名密钥
+type JwtKey struct {
+	Kid            string `yaml:"kid" json:"kid"`
+	Alg            string `yaml:"alg" json:"alg"`                       // HS256, RS256, EdDSA
+	Secret         string `yaml:"secret" json:"-"`                      // HS256 密钥,secretEnv 对应的环境变量存在时以环境变量为准
+	SecretEnv      string `yaml:"secretEnv" json:"secretEnv"`           // 保存 HS256 密钥的环境变量
+	PrivateKeyFile string `yaml:"privateKeyFile" json:"privateKeyFile"` // RS256, EdDSA 私钥文件(PEM),未配置时只用于校验
+	PublicKeyFile  string `yaml:"publicKeyFile" json:"publicKeyFile"`   // RS256, EdDSA 公钥文件(PEM),未配置时由私钥导出
+}
+
+// AccessExpireDuration 访问令牌的有效期,未配置时为 15 分钟
+func (j Jwt) AccessExpireDuration() time.Duration {
+	if j.AccessExpire <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(j.AccessExpire) * time.Minute
+}
+
+// RefreshExpireDuration 刷新令牌的有效期,未配置时为 7 天
+func (j Jwt) RefreshExpireDuration() time.Duration {
+	if j.RefreshExpire <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(j.RefreshExpire) * time.Hour
+}
+
+// Admin 初始管理员账号,启动时不存在则创建,已存在则设为管理员
+type Admin struct {
+	Username    string `yaml:"username" json:"username"`       // 为空时不创建
+	Password    string `yaml:"password" json:"-"`              // passwordEnv 对应的环境变量存在时以环境变量为准
+	PasswordEnv string `yaml:"passwordEnv" json:"passwordEnv"` // 保存初始密码的环境变量
+	Mail        string `yaml:"mail" json:"mail"`
+	Phone       string `yaml:"phone" json:"phone"`
+}
+
+// Login 登录失败限制配置,未配置的项使用默认值
+type Login struct {
+	MaxAccountFailures int `yaml:"maxAccountFailures" json:"maxAccountFailures"` // 账号在统计窗口内的失败次数上限,达到后锁定账号
+	MaxIPFailures      int `yaml:"maxIPFailures" json:"maxIPFailures"`           // 同一 ip 在统计窗口内的失败次数上限,达到后锁定 ip
+	FreeFailures       int `yaml:"freeFailures" json:"freeFailures"`             // 免等待的失败次数,之后每次失败需要等待的时间翻倍
+	MaxDelay           int `yaml:"maxDelay" json:"maxDelay"`                     // 单次等待的最长时间,单位(秒)
+	FailureWindow      int `yaml:"failureWindow" json:"failureWindow"`           // 失败次数的统计窗口,单位(分钟)
+	LockoutMinutes     int `yaml:"lockoutMinutes" json:"lockoutMinutes"`         // 锁定时长,单位(分钟)
+	// 必须启用两步验证的角色,未启用时不能使用角色的权限,未配置时为 admin
+	TwoFactorRoles []string `yaml:"twoFactorRoles" json:"twoFactorRoles"`
+}
+
+// AccountLimit 账号的失败次数上限,未配置时为 5 次
+func (l Login) AccountLimit() int {
+	if l.MaxAccountFailures <= 0 {
+		return 5
+	}
+	return l.MaxAccountFailures
+}
+
+// IPLimit ip 的失败次数上限,未配置时为 20 次
+func (l Login) IPLimit() int {
+	if l.MaxIPFailures <= 0 {
+		return 20
+	}
+	return l.MaxIPFailures
+}
+
+// FreeAttempts 免等待的失败次数,未配置时为 2 次
+func (l Login) FreeAttempts() int {
+	if l.FreeFailures <= 0 {
+		return 2
+	}
+	return l.FreeFailures
+}
+
+// Delay 单次等待的最长时间,未配置时为 30 秒
+func (l Login) Delay() time.Duration {
+	if l.MaxDelay <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(l.MaxDelay) * time.Second
+}
+
+// Window 失败次数的统计窗口,未配置时为 15 分钟
+func (l Login) Window() time.Duration {
+	if l.FailureWindow <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(l.FailureWindow) * time.Minute
+}
+
+// TwoFactorRequired 角色是否必须启用两步验证
+func (l Login) TwoFactorRequired(role string) bool {
+	roles := l.TwoFactorRoles
+	if roles == nil {
+		roles = []string{"admin"}
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Lockout 锁定时长,未配置时为 15 分钟
+func (l Login) Lockout() time.Duration {
+	if l.LockoutMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(l.LockoutMinutes) * time.Minute
+}
+
+// Mail 邮件配置
+type Mail struct {
+	Driver         string `yaml:"driver" json:"driver"` // smtp, file, log, 默认为 log
+	From           string `yaml:"from" json:"from"`
+	Host           string `yaml:"host" json:"host"`
+	Port           int    `yaml:"port" json:"port"`
+	Username       string `yaml:"username" json:"username"`
+	Password       string `yaml:"password" json:"-"`
+	PasswordEnv    string `yaml:"passwordEnv" json:"passwordEnv"`       // 保存 SMTP 密码的环境变量,存在时以环境变量为准
+	Dir            string `yaml:"dir" json:"dir"`                       // file 方式保存邮件的目录
+	LinkBaseURL    string `yaml:"linkBaseURL" json:"linkBaseURL"`       // 邮件中链接的前缀,通常为前端地址
+	TokenSecret    string `yaml:"tokenSecret" json:"-"`                 // 邮件中一次性令牌的签名密钥
+	TokenSecretEnv string `yaml:"tokenSecretEnv" json:"tokenSecretEnv"` // 保存签名密钥的环境变量,存在时以环境变量为准
+}
+
+// Oidc OIDC 单点登录配置,使用授权码模式与 PKCE
+type Oidc struct {
+	Enable          bool     `yaml:"enable" json:"enable"`
+	Issuer          string   `yaml:"issuer" json:"issuer"` // 身份提供方地址,启动后首次使用时获取 .well-known/openid-configuration
+	ClientID        string   `yaml:"clientID" json:"clientID"`
+	ClientSecret    string   `yaml:"clientSecret" json:"-"`
+	ClientSecretEnv string   `yaml:"clientSecretEnv" json:"clientSecretEnv"` // 保存客户端密钥的环境变量,存在时以环境变量为准
+	RedirectURL     string   `yaml:"redirectURL" json:"redirectURL"`         // 身份提供方回调的地址,通常为前端页面,由前端将 code 与 state 提交给回调接口
+	Scopes          []string `yaml:"scopes" json:"scopes"`                   // 为空时使用 openid, profile, email
+	UsernameClaim   string   `yaml:"usernameClaim" json:"usernameClaim"`     // 自动创建账号时作为用户名的声明,为空时使用 preferred_username
+	LinkByUsername  bool     `yaml:"linkByUsername" json:"linkByUsername"`   // 首次登录时关联用户名相同的已有账号,要求身份提供方验证过的邮箱与账号已验证的邮箱一致
+}
+
+// GetScopes 申请的权限范围,总是包含 openid
+func (o Oidc) GetScopes() []string {
+	if len(o.Scopes) == 0 {
+		return []string{"openid", "profile", "email"}
+	}
+	for _, scope := range o.Scopes {
+		if scope == "openid" {
+			return o.Scopes
+		}
+	}
+	return append([]string{"openid"}, o.Scopes...)
+}
+
+// GetUsernameClaim 作为用户名的声明
+func (o Oidc) GetUsernameClaim() string {
+	if o.UsernameClaim == "" {
+		return "preferred_username"
+	}
+	return o.UsernameClaim
+}
+
 // Elasticsearch 配置
 type Elasticsearch struct {
 	Addresses                []string      `json:"addresses"`                   // Elasticsearch节点的地址列表。
@@ -0,0 +1,92 @@
+package dao
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/model"
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var instanceAccessToken struct {
+	IAccessTokenDao
+	sync.Once
+}
+
+func AccessTokenDao() IAccessTokenDao {
+	instanceAccessToken.Once.Do(func() {
+		instanceAccessToken.IAccessTokenDao = NewAccessTokenDao(model.GetDB())
+	})
+	return instanceAccessToken.IAccessTokenDao
+}
+
+// IAccessTokenDao 个人访问令牌
+type IAccessTokenDao interface {
+	Create(ctx context.Context, t *model.AccessToken) error
+	// ListByUsername 查询用户的全部令牌
+	ListByUsername(ctx context.Context, username string) ([]*model.AccessToken, error)
+	// Delete 吊销令牌,令牌不存在时返回 custom_err.ErrRecordNotFound
+	Delete(ctx context.Context, username string, id uint) error
+	// GetByHash 根据令牌的 sha256 查询令牌,shard 为令牌明文中记录的分表序号
+	GetByHash(ctx context.Context, shard int, tokenHash string) (*model.AccessToken, error)
+	// Touch 记录令牌的最近使用时间与 ip
+	Touch(ctx context.Context, t *model.AccessToken, at time.Time, ip string) error
+}
+
+type accessTokenDao struct {
+	db *gorm.DB
+}
+
+// NewAccessTokenDao creating the dao interface
+func NewAccessTokenDao(db *gorm.DB) IAccessTokenDao {
+	return &accessTokenDao{db: db}
+}
+
+func (d *accessTokenDao) Create(ctx context.Context, t *model.AccessToken) error {
+	return d.db.WithContext(ctx).Table(t.TName()).Create(t).Error
+}
+
+func (d *accessTokenDao) ListByUsername(ctx context.Context, username string) ([]*model.AccessToken, error) {
+	tokens := make([]*model.AccessToken, 0)
+	err := d.db.WithContext(ctx).
+		Table(model.AccessToken{CUsername: username}.TName()).
+		Where("c_username = ?", username).
+		Order("id DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (d *accessTokenDao) Delete(ctx context.Context, username string, id uint) error {
+	result := d.db.WithContext(ctx).
+		Table(model.AccessToken{CUsername: username}.TName()).
+		Where("id = ? AND c_username = ?", id, username).
+		Delete(&model.AccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return custom_err.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (d *accessTokenDao) GetByHash(ctx context.Context, shard int, tokenHash string) (*model.AccessToken, error) {
+	t := new(model.AccessToken)
+	err := d.db.WithContext(ctx).
+		Table(model.AccessTokenTName(shard)).
+		Where("token_hash = ?", tokenHash).
+		First(t).Error
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (d *accessTokenDao) Touch(ctx context.Context, t *model.AccessToken, at time.Time, ip string) error {
+	return d.db.WithContext(ctx).
+		Table(t.TName()).
+		Where("id = ?", t.ID).
+		UpdateColumns(map[string]any{"last_used_at": at, "last_used_ip": ip}).Error
+}
@@ -9,6 +9,9 @@ var (
 	ErrInitDaoFailed  = errors.New("init dao failed")
 	ErrMarshalType    = errors.New("ErrMarshalType")
 	ErrUnmarshalType  = errors.New("ErrUnmarshalType")
+	ErrPhoneExist     = errors.New("phone already exists")
+	ErrMailExist      = errors.New("mail already exists")
+	ErrInvalidSort    = errors.New("unsupported sort field")
 	ErrDuplicateEntry = mysql.MySQLError{
 		Number: 1062,
 	}
@@ -2,6 +2,8 @@ package dao
 
 import (
 	"SnapLink/internal/cache"
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/elasticsearch"
 	"SnapLink/internal/model"
 	"context"
 	"fmt"
@@ -133,12 +135,18 @@ func makeUriBF(db *gorm.DB) (bool, []error) {
 	//todo 此处改为远程配置
 	err := cache.BFCache().BFCreate(context.Background(), uriBF, 0.01, 1e9)
 	if err == nil {
+		// 回收站中的 uri 仍被占用且可以恢复,同样需要加入布隆过滤器
+		tables := make([]string, 0, model.RedirectShardingNum+model.RecycleBinShardingNum)
+		for i := 0; i < model.RedirectShardingNum; i++ {
+			tables = append(tables, fmt.Sprintf("%s-%d", model.RedirectPrefix, i))
+		}
+		for i := 0; i < model.RecycleBinShardingNum; i++ {
+			tables = append(tables, fmt.Sprintf("%s-%d", model.RecycleBinPrefix, i))
+		}
 		//记录日志
-		var errs = []error{}
+		var errs = make([]error, len(tables))
 		wg := sync.WaitGroup{}
-		for i := 0; i < model.RedirectShardingNum; i++ {
-			errs = append(errs, nil)
-			tableName := fmt.Sprintf("%s-%d", model.RedirectPrefix, i)
+		for i, tableName := range tables {
 			wg.Add(1)
 			go func(id int, tName string) {
 				defer func() {
@@ -184,3 +192,96 @@ func makeUriBF(db *gorm.DB) (bool, []error) {
 	}
 	return true, nil
 }
+
+// RebuildSearchIndex 重建短链接的全文检索索引
+// 用于 ES 数据丢失或 binlog 同步中断后的全量修复
+func (d *FixDao) RebuildSearchIndex() (errs []error) {
+	val, err, _ := d.sfg.Do("RebuildSearchIndex", func() (interface{}, error) {
+		ctx := context.Background()
+		if err := elasticsearch.CreateShortLinkIndex(ctx); err != nil {
+			return []error{err}, err
+		}
+		groupDao := NewShortLinkGroupDao(d.db)
+		tagDao := NewTagDao(d.db)
+		errs := make([]error, model.ShortLinkShardingNum)
+		wg := sync.WaitGroup{}
+		for i := 0; i < model.ShortLinkShardingNum; i++ {
+			tableName := fmt.Sprintf("%s-%d", model.ShortLinkPrefix, i)
+			wg.Add(1)
+			go func(id int, tName string) {
+				defer wg.Done()
+				// 全量查询,使用游标法避免深分页
+				var cursor uint
+				for {
+					records := make([]*model.ShortLink, 0, 500)
+					err := d.db.WithContext(ctx).Table(tName).Where("id > ?", cursor).Order("id").Limit(500).Find(&records).Error
+					if err != nil {
+						errs[id] = err
+						return
+					}
+					l := len(records)
+					if l == 0 {
+						return
+					}
+					cursor = records[l-1].ID
+					// 标签按用户分表,按创建人分批查询本批短链接的标签
+					owners := make(map[string]string, l)
+					ownerURIs := make(map[string][]string)
+					for i := 0; i < l; i++ {
+						group, err := groupDao.GetByGid(ctx, records[i].Gid)
+						if err != nil {
+							// 分组已经被删除的短链接不再建立索引
+							if errors.Is(err, custom_err.ErrRecordNotFound) {
+								continue
+							}
+							errs[id] = err
+							return
+						}
+						owners[records[i].Uri] = group.CUsername
+						ownerURIs[group.CUsername] = append(ownerURIs[group.CUsername], records[i].Uri)
+					}
+					tags := make(map[string][]string, l)
+					for owner, uris := range ownerURIs {
+						linkTags, err := tagDao.GetLinkTags(ctx, owner, uris)
+						if err != nil {
+							errs[id] = err
+							return
+						}
+						for uri, list := range linkTags {
+							for _, tag := range list {
+								tags[uri] = append(tags[uri], tag.Name)
+							}
+						}
+					}
+					docs := make([]*elasticsearch.ShortLinkDocument, 0, l)
+					for i := 0; i < l; i++ {
+						owner, ok := owners[records[i].Uri]
+						if !ok {
+							continue
+						}
+						docs = append(docs, elasticsearch.NewShortLinkDocument(records[i], owner, tags[records[i].Uri]))
+					}
+					if err = elasticsearch.BulkIndexShortLink(ctx, docs); err != nil {
+						errs[id] = err
+						return
+					}
+				}
+			}(i, tableName)
+		}
+		wg.Wait()
+		failed := make([]error, 0)
+		for _, err := range errs {
+			if err != nil {
+				failed = append(failed, err)
+			}
+		}
+		if len(failed) > 0 {
+			return failed, errors.New("rebuild search index failed")
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return val.([]error)
+	}
+	return nil
+}
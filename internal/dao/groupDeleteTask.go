@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// GetDeleteTask 查询分组的删除任务
+func (d *shortLinkGroupsDao) GetDeleteTask(ctx context.Context, gid, username string) (*model.GroupDeleteTask, error) {
+	task := &model.GroupDeleteTask{CUsername: username}
+	err := d.db.WithContext(ctx).Table(task.TName()).
+		Where("gid = ? AND c_username = ?", gid, username).
+		First(task).Error
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ListRunningDeleteTasks 查询全部分表中尚未完成的删除任务
+func (d *shortLinkGroupsDao) ListRunningDeleteTasks(ctx context.Context) ([]*model.GroupDeleteTask, error) {
+	q := &ScatterQuery[*model.GroupDeleteTask]{
+		Prefix:      model.GroupDeleteTaskPrefix,
+		ShardingNum: model.GroupDeleteTaskShardingNum,
+		Scope: func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", model.GroupDeleteTaskRunning)
+		},
+		Order: "id",
+	}
+	tasks, _, err := q.Find(ctx, d.db)
+	return tasks, err
+}
+
+// SaveDeleteTaskProgress 保存删除任务的进度与状态
+func (d *shortLinkGroupsDao) SaveDeleteTaskProgress(ctx context.Context, task *model.GroupDeleteTask) error {
+	return d.db.WithContext(ctx).Table(task.TName()).
+		Where("id = ?", task.ID).
+		Select("status", "cursor", "processed", "last_error", "updated_at").
+		Updates(task).Error
+}
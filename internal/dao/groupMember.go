@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/model"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// GetMember 查询用户在分组中的成员记录
+func (d *shortLinkGroupsDao) GetMember(ctx context.Context, gid, username string) (*model.GroupMember, error) {
+	member := &model.GroupMember{Username: username}
+	err := d.db.WithContext(ctx).Table(member.TName()).
+		Where("gid = ? AND username = ?", gid, username).
+		First(member).Error
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// CreateMember 邀请用户加入分组,已经是成员或已被邀请时返回 ErrDuplicateEntry
+func (d *shortLinkGroupsDao) CreateMember(ctx context.Context, member *model.GroupMember) error {
+	return d.db.WithContext(ctx).Table(member.TName()).Create(member).Error
+}
+
+// UpdateMember 修改成员的角色或状态
+func (d *shortLinkGroupsDao) UpdateMember(ctx context.Context, member *model.GroupMember) error {
+	result := d.db.WithContext(ctx).Table(member.TName()).
+		Where("gid = ? AND username = ?", member.Gid, member.Username).
+		Select("role", "status", "updated_at").
+		Updates(member)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return custom_err.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteMember 移除分组成员,包括拒绝邀请与退出分组
+func (d *shortLinkGroupsDao) DeleteMember(ctx context.Context, gid, username string) error {
+	member := &model.GroupMember{Username: username}
+	result := d.db.WithContext(ctx).Table(member.TName()).
+		Where("gid = ? AND username = ?", gid, username).
+		Delete(member)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return custom_err.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListMembers 查询分组的全部成员
+// 成员表按成员用户名分表,需要查询全部分表
+func (d *shortLinkGroupsDao) ListMembers(ctx context.Context, gid string) ([]*model.GroupMember, error) {
+	q := &ScatterQuery[*model.GroupMember]{
+		Prefix:      model.GroupMemberPrefix,
+		ShardingNum: model.GroupMemberShardingNum,
+		Scope:       func(db *gorm.DB) *gorm.DB { return db.Where("gid = ?", gid) },
+	}
+	members, _, err := q.Find(ctx, d.db)
+	return members, err
+}
+
+// ListMemberships 查询用户在指定状态下的成员记录
+func (d *shortLinkGroupsDao) ListMemberships(ctx context.Context, username, status string) ([]*model.GroupMember, error) {
+	member := &model.GroupMember{Username: username}
+	var members []*model.GroupMember
+	err := d.db.WithContext(ctx).Table(member.TName()).
+		Where("username = ? AND status = ?", username, status).
+		Order("id").
+		Find(&members).Error
+	return members, err
+}
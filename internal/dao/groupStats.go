@@ -0,0 +1,85 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var instanceGroupStats struct {
+	IGroupStatsDao
+	sync.Once
+}
+
+func GroupStatsDao() IGroupStatsDao {
+	instanceGroupStats.Once.Do(func() {
+		instanceGroupStats.IGroupStatsDao = NewGroupStatsDao(model.GetDB())
+	})
+	return instanceGroupStats.IGroupStatsDao
+}
+
+// IGroupStatsDao 分组访问统计
+type IGroupStatsDao interface {
+	// Save 写入统计数据,已存在的统计以新的数据覆盖
+	Save(ctx context.Context, stats []*model.LinkAccessStatisticBasic) error
+	// ListGroupStats 查询日期范围内整个分组的统计,hourly 为 true 时按小时返回,否则按天返回
+	ListGroupStats(ctx context.Context, gid, startDate, endDate string, hourly bool) ([]*model.LinkAccessStatisticBasic, error)
+	// TopLinks 查询日期范围内访问量最高的短链接,uv 与 uip 为每天去重数之和
+	TopLinks(ctx context.Context, gid, startDate, endDate string, limit int) ([]*model.LinkAccessStatisticBasic, error)
+}
+
+type groupStatsDao struct {
+	db *gorm.DB
+}
+
+// NewGroupStatsDao creating the dao interface
+func NewGroupStatsDao(db *gorm.DB) IGroupStatsDao {
+	return &groupStatsDao{db: db}
+}
+
+func (d *groupStatsDao) Save(ctx context.Context, stats []*model.LinkAccessStatisticBasic) error {
+	tables := make(map[string][]*model.LinkAccessStatisticBasic)
+	for _, stat := range stats {
+		tables[stat.TName()] = append(tables[stat.TName()], stat)
+	}
+	for table, rows := range tables {
+		err := d.db.WithContext(ctx).Table(table).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "gid"}, {Name: "uri"}, {Name: "date"}, {Name: "hour"}},
+				DoUpdates: clause.AssignmentColumns([]string{"pv", "uv", "uip", "regions", "devices", "updated_at"}),
+			}).
+			Create(&rows).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *groupStatsDao) ListGroupStats(ctx context.Context, gid, startDate, endDate string, hourly bool) ([]*model.LinkAccessStatisticBasic, error) {
+	var stats []*model.LinkAccessStatisticBasic
+	tx := d.db.WithContext(ctx).Table(model.LinkAccessStatisticBasic{Gid: gid}.TName()).
+		Where("gid = ? AND uri = '' AND date BETWEEN ? AND ?", gid, startDate, endDate)
+	if hourly {
+		tx = tx.Where("hour >= 0")
+	} else {
+		tx = tx.Where("hour = ?", model.StatisticWholeDay)
+	}
+	err := tx.Order("date, hour").Find(&stats).Error
+	return stats, err
+}
+
+func (d *groupStatsDao) TopLinks(ctx context.Context, gid, startDate, endDate string, limit int) ([]*model.LinkAccessStatisticBasic, error) {
+	var stats []*model.LinkAccessStatisticBasic
+	err := d.db.WithContext(ctx).Table(model.LinkAccessStatisticBasic{Gid: gid}.TName()).
+		Select("uri", "SUM(pv) AS pv", "SUM(uv) AS uv", "SUM(uip) AS uip").
+		Where("gid = ? AND uri <> '' AND hour = ? AND date BETWEEN ? AND ?", gid, model.StatisticWholeDay, startDate, endDate).
+		Group("uri").
+		Order("pv desc").
+		Limit(limit).
+		Find(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,144 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// defaultScatterConcurrency 跨分表查询时默认同时查询的分表数量,避免占满连接池
+const defaultScatterConcurrency = 4
+
+// ScatterQuery 跨分表查询,在每张分表上并发执行相同的查询,再合并、排序并分页
+//
+// 每张分表最多查询 Offset+Limit 条记录,合并后按 Less 排序再取出 [Offset, Offset+Limit),
+// 因此 Order 与 Less 的排序规则必须一致,否则分页结果不正确;深分页时查询量随分表数量增加
+type ScatterQuery[T any] struct {
+	Prefix      string
+	ShardingNum int
+	// Scope 查询条件,在每张分表上执行,为空时查询全部记录
+	Scope func(db *gorm.DB) *gorm.DB
+	// Order 分表内的排序,例如 "created_at DESC, username"
+	Order string
+	// Less 合并后的排序,为空时按分表序号合并
+	Less   func(a, b T) bool
+	Offset int
+	// Limit 为 0 时不分页,返回全部记录
+	Limit int
+	// Count 是否统计全部分表中满足条件的记录数
+	Count bool
+	// Concurrency 同时查询的分表数量,为 0 时使用默认值
+	Concurrency int
+}
+
+// Find 执行查询,返回当前页的记录与满足条件的记录总数,Count 为 false 时总数为 0
+func (q *ScatterQuery[T]) Find(ctx context.Context, db *gorm.DB) ([]T, int64, error) {
+	var (
+		shards = make([][]T, q.ShardingNum)
+		counts = make([]int64, q.ShardingNum)
+	)
+	err := scatterShards(ctx, q.ShardingNum, q.Concurrency, func(ctx context.Context, shard int) error {
+		tx := q.table(ctx, db, shard)
+		if q.Count {
+			// Count 需要指定 Model,否则不会带上软删除等模型上的查询条件
+			if err := tx.Session(&gorm.Session{}).Model(q.model()).Count(&counts[shard]).Error; err != nil {
+				return err
+			}
+			if counts[shard] == 0 {
+				return nil
+			}
+		}
+		if q.Order != "" {
+			tx = tx.Order(q.Order)
+		}
+		if q.Limit > 0 {
+			tx = tx.Limit(q.Offset + q.Limit)
+		}
+		return tx.Find(&shards[shard]).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return mergeShards(shards, q.Less, q.Offset, q.Limit), total, nil
+}
+
+// mergeShards 合并各分表的查询结果,按 less 排序后取出 [offset, offset+limit)
+// less 为空时按分表序号合并,limit 为 0 时返回全部记录
+func mergeShards[T any](shards [][]T, less func(a, b T) bool, offset, limit int) []T {
+	records := make([]T, 0)
+	for _, shard := range shards {
+		records = append(records, shard...)
+	}
+	if less != nil {
+		sort.SliceStable(records, func(i, j int) bool { return less(records[i], records[j]) })
+	}
+	if limit <= 0 {
+		return records
+	}
+	if offset >= len(records) {
+		return []T{}
+	}
+	end := offset + limit
+	if end > len(records) {
+		end = len(records)
+	}
+	return records[offset:end]
+}
+
+// First 返回任意一张分表中满足条件的第一条记录,设置 Less 时返回排序最靠前的记录,未找到时返回 gorm.ErrRecordNotFound
+func (q *ScatterQuery[T]) First(ctx context.Context, db *gorm.DB) (T, error) {
+	first := *q
+	first.Offset, first.Limit, first.Count = 0, 1, false
+	records, _, err := first.Find(ctx, db)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if len(records) == 0 {
+		var zero T
+		return zero, gorm.ErrRecordNotFound
+	}
+	return records[0], nil
+}
+
+// model 返回 T 对应的模型实例,T 为指针类型时返回其指向类型的实例
+func (q *ScatterQuery[T]) model() any {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
+
+func (q *ScatterQuery[T]) table(ctx context.Context, db *gorm.DB, shard int) *gorm.DB {
+	tx := db.WithContext(ctx).Table(fmt.Sprintf("%s-%d", q.Prefix, shard))
+	if q.Scope != nil {
+		tx = q.Scope(tx)
+	}
+	return tx
+}
+
+// scatterShards 以 concurrency 的并发度在每张分表上执行 fn,任意分表出错时取消其余查询并返回第一个错误
+func scatterShards(ctx context.Context, shardingNum, concurrency int, fn func(ctx context.Context, shard int) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultScatterConcurrency
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i := 0; i < shardingNum; i++ {
+		shard := i
+		g.Go(func() error {
+			return fn(ctx, shard)
+		})
+	}
+	return g.Wait()
+}
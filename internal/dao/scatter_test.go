@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func usernames(users []*model.TUser) string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return strings.Join(names, ",")
+}
+
+// testUserShards 每张分表内已按 created_at DESC, username 排序,不同分表中存在创建时间相同的用户
+func testUserShards() [][]*model.TUser {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := func(name string, minutes int) *model.TUser {
+		return &model.TUser{Username: name, CreatedAt: t0.Add(time.Duration(minutes) * time.Minute)}
+	}
+	return [][]*model.TUser{
+		{user("bob", 3), user("dave", 2), user("alice", 1)},
+		{},
+		{user("carol", 3), user("erin", 2)},
+		{user("frank", 4), user("amy", 2)},
+	}
+}
+
+func TestMergeShards(t *testing.T) {
+	_, less, err := parseUserSort("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          string
+	}{
+		{"first page", 0, 3, "frank,bob,carol"},
+		{"ties ordered by username", 3, 3, "amy,dave,erin"},
+		{"last partial page", 6, 3, "alice"},
+		{"offset at the end", 7, 3, ""},
+		{"offset past the end", 100, 3, ""},
+		{"no limit", 0, 0, "frank,bob,carol,amy,dave,erin,alice"},
+	}
+	for _, tt := range tests {
+		got := mergeShards(testUserShards(), less, tt.offset, tt.limit)
+		if got == nil {
+			t.Errorf("%s: mergeShards returned nil", tt.name)
+		}
+		if names := usernames(got); names != tt.want {
+			t.Errorf("%s: mergeShards(%d, %d) = %s, want %s", tt.name, tt.offset, tt.limit, names, tt.want)
+		}
+	}
+}
+
+func TestMergeShardsWithoutLess(t *testing.T) {
+	got := mergeShards(testUserShards(), nil, 2, 3)
+	if names := usernames(got); names != "alice,carol,erin" {
+		t.Errorf("mergeShards without less = %s, want alice,carol,erin", names)
+	}
+}
+
+func TestParseUserSort(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &model.TUser{Username: "a", RealName: "Zed", Role: "admin", CreatedAt: t0}
+	b := &model.TUser{Username: "b", RealName: "Zed", Role: "user", CreatedAt: t0.Add(time.Hour)}
+	tests := []struct {
+		sort      string
+		wantOrder string
+		aBeforeB  bool
+		wantErr   bool
+	}{
+		{"", "created_at DESC, username", false, false},
+		{"ignore count", "created_at DESC, username", false, false},
+		{"created_at", "created_at, username", true, false},
+		{"-username", "username DESC", false, false},
+		{"real_name", "real_name, username", true, false},
+		{"-real_name", "real_name DESC, username", true, false},
+		{"role, -created_at", "role, created_at DESC, username", true, false},
+		{" -role , username ", "role DESC, username", false, false},
+		{"password", "", false, true},
+		{"username;drop table", "", false, true},
+	}
+	for _, tt := range tests {
+		order, less, err := parseUserSort(tt.sort)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidSort) {
+				t.Errorf("parseUserSort(%q) error = %v, want ErrInvalidSort", tt.sort, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseUserSort(%q) error = %v", tt.sort, err)
+			continue
+		}
+		if order != tt.wantOrder {
+			t.Errorf("parseUserSort(%q) order = %q, want %q", tt.sort, order, tt.wantOrder)
+		}
+		if got := less(a, b); got != tt.aBeforeB {
+			t.Errorf("parseUserSort(%q) less(a, b) = %v, want %v", tt.sort, got, tt.aBeforeB)
+		}
+		if less(a, a) {
+			t.Errorf("parseUserSort(%q) less(a, a) = true", tt.sort)
+		}
+	}
+}
+
+func TestScatterQueryModel(t *testing.T) {
+	q := &ScatterQuery[*model.TUser]{}
+	if _, ok := q.model().(*model.TUser); !ok {
+		t.Errorf("model() = %T, want *model.TUser", q.model())
+	}
+}
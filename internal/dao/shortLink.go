@@ -5,11 +5,13 @@ import (
 	"SnapLink/internal/custom_err"
 	"SnapLink/internal/model"
 	"context"
+	"database/sql"
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 	cacheBase "github.com/zhufuyi/sponge/pkg/cache"
 	"github.com/zhufuyi/sponge/pkg/logger"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
@@ -36,11 +38,29 @@ type IShortLinkDao interface {
 	Create(ctx context.Context, table *model.ShortLink) error
 	CreateBatch(ctx context.Context, tables []*model.ShortLink) (*model.ShortLink, error)
 	List(ctx context.Context, gid string, page, pageSize int) ([]*model.ShortLink, error)
+	ListNewest(ctx context.Context, gid string, page, pageSize int) ([]*model.ShortLink, error)
+	ListByRank(ctx context.Context, gid, orderTag string, page, pageSize int) ([]*model.ShortLink, error)
+	ListByURIs(ctx context.Context, gid string, uris []string) ([]*model.ShortLink, error)
+	Scan(ctx context.Context, gid string, cursor uint, limit int) ([]*model.ShortLink, error)
 	Count(ctx context.Context, gid string) (int64, error)
 	Delete(ctx context.Context, uri string) error
 	GeRedirectByURI(ctx context.Context, uri string) (*model.Redirect, error)
-	Update(ctx context.Context, shortLink *model.ShortLink) error
-	UpdateWithMove(ctx context.Context, shortLink *model.ShortLink, newGid string) error
+	GetByURI(ctx context.Context, uri string) (*model.ShortLink, error)
+	HasURI(ctx context.Context, uri string) (bool, error)
+	Update(ctx context.Context, shortLink *model.ShortLink, history *model.ShortLinkHistory) error
+	UpdateWithMove(ctx context.Context, shortLink *model.ShortLink, newGid string, history *model.ShortLinkHistory) error
+	ListHistory(ctx context.Context, uri string, page, pageSize int) ([]*model.ShortLinkHistory, error)
+	CountHistory(ctx context.Context, uri string) (int64, error)
+	GetHistory(ctx context.Context, uri string, id uint) (*model.ShortLinkHistory, error)
+	GetRecycled(ctx context.Context, uri string) (*model.RecycleBin, error)
+	ListRecycled(ctx context.Context, gid string, page, pageSize int) ([]*model.ShortLink, error)
+	CountRecycled(ctx context.Context, gid string) (int64, error)
+	Restore(ctx context.Context, uri string) (string, error)
+	Purge(ctx context.Context, uri string) error
+	PurgeExpired(ctx context.Context, before time.Time) (int, error)
+	GetByURIs(ctx context.Context, uris []string) (map[string]*model.ShortLink, error)
+	BulkUpdate(ctx context.Context, changes []*ShortLinkChange, actor, owner string) map[string]error
+	BulkDelete(ctx context.Context, links []*model.ShortLink, owner string) map[string]error
 }
 
 type shortLinkDao struct {
@@ -69,11 +89,7 @@ func NewShortLinkDao(db *gorm.DB, client *redis.Client) (IShortLinkDao, error) {
 // 2. 创建重定向
 // 3. 理论上来说,此处创建成功即为成功,缓存的更新不在此处进行
 func (d *shortLinkDao) Create(ctx context.Context, shortLink *model.ShortLink) error {
-	redirect := &model.Redirect{
-		Uri:         shortLink.Uri,
-		Gid:         shortLink.Gid,
-		OriginalURL: shortLink.OriginUrl,
-	}
+	redirect := model.NewRedirect(shortLink)
 	// 同时创建短链接和重定向
 	err := d.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Table(redirect.TName()).WithContext(ctx).Create(redirect).Error; err != nil {
@@ -81,7 +97,14 @@ func (d *shortLinkDao) Create(ctx context.Context, shortLink *model.ShortLink) e
 		}
 		return tx.Table(shortLink.TName()).WithContext(ctx).Create(shortLink).Error
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// 排行榜需要包含分组内的全部短链接,新建的短链接分数为 0
+	if err = cache.ShortLinkStats().RebuildRank(ctx, shortLink.Gid, []string{shortLink.Uri}); err != nil {
+		logger.Warn("写入短链接排行失败", logger.Err(err), logger.String("uri", shortLink.Uri))
+	}
+	return nil
 }
 
 // CreateBatch 批量创建短链接
@@ -91,11 +114,7 @@ func (d *shortLinkDao) CreateBatch(ctx context.Context, tables []*model.ShortLin
 	i := 0
 	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for i = 0; i < l; i++ {
-			redirect := &model.Redirect{
-				Uri:         tables[i].Uri,
-				Gid:         tables[i].Gid,
-				OriginalURL: tables[i].OriginUrl,
-			}
+			redirect := model.NewRedirect(tables[i])
 			if err := tx.Table(redirect.TName()).Create(redirect).Error; err != nil {
 				return err
 			}
@@ -109,6 +128,15 @@ func (d *shortLinkDao) CreateBatch(ctx context.Context, tables []*model.ShortLin
 		return tables[i], err
 
 	}
+	groups := make(map[string][]string)
+	for _, sl := range tables {
+		groups[sl.Gid] = append(groups[sl.Gid], sl.Uri)
+	}
+	for gid, uris := range groups {
+		if err = cache.ShortLinkStats().RebuildRank(ctx, gid, uris); err != nil {
+			logger.Warn("写入短链接排行失败", logger.Err(err), logger.String("gid", gid))
+		}
+	}
 	return nil, nil
 }
 
@@ -139,6 +167,100 @@ func (d *shortLinkDao) List(ctx context.Context, gid string, page, pageSize int)
 	return list, err
 }
 
+// ListNewest 按创建时间倒序分页查询
+// 与 List 相同,基于子查询优化深分页
+func (d *shortLinkDao) ListNewest(ctx context.Context, gid string, page, pageSize int) ([]*model.ShortLink, error) {
+	var list []*model.ShortLink
+	tableName := model.ShortLink{Gid: gid}.TName()
+	subQuery := d.db.WithContext(ctx).
+		Select("id").
+		Table(tableName).
+		Where("gid = ?", gid).
+		Order("id DESC").
+		Limit(1).
+		Offset((page - 1) * pageSize)
+	err := d.db.WithContext(ctx).
+		Table(tableName).
+		Where("gid = ?", gid).
+		Where("id <= (?)", subQuery).
+		Order("id DESC").
+		Limit(pageSize).
+		Find(&list).Error
+	return list, err
+}
+
+// ListByRank 按访问统计排序分页查询
+// 1. 累计排行榜包含分组内的全部短链接,由 Setup 回填,并在创建、恢复、移动短链接时维护
+// 2. 当日排行榜只包含当天被访问过的短链接,数量不足时以累计排行榜补全,未被访问的短链接分数为 0
+// 3. 从排行榜中取出当前页的 uri,再回表查询短链接,并保持排行榜中的顺序
+func (d *shortLinkDao) ListByRank(ctx context.Context, gid, orderTag string, page, pageSize int) ([]*model.ShortLink, error) {
+	if cache.IsDayRankOrderTag(orderTag) {
+		total, err := d.Count(ctx, gid)
+		if err != nil {
+			return nil, err
+		}
+		card, err := cache.ShortLinkStats().RankCard(ctx, gid, orderTag)
+		if err != nil {
+			return nil, err
+		}
+		if card < total {
+			_, err, _ = d.sfg.Do("rank:"+gid, func() (interface{}, error) {
+				return nil, cache.ShortLinkStats().FillDayRank(ctx, gid)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	uris, err := cache.ShortLinkStats().RankRange(ctx, gid, orderTag, (page-1)*pageSize, pageSize)
+	if err != nil || len(uris) == 0 {
+		return nil, err
+	}
+	records, err := d.ListByURIs(ctx, gid, uris)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]*model.ShortLink, len(records))
+	for _, record := range records {
+		index[record.Uri] = record
+	}
+	list := make([]*model.ShortLink, 0, len(records))
+	for _, uri := range uris {
+		if record, ok := index[uri]; ok {
+			list = append(list, record)
+		}
+	}
+	return list, nil
+}
+
+// ListByURIs 查询分组内指定 uri 的短链接,按 id 排序
+func (d *shortLinkDao) ListByURIs(ctx context.Context, gid string, uris []string) ([]*model.ShortLink, error) {
+	list := make([]*model.ShortLink, 0, len(uris))
+	if len(uris) == 0 {
+		return list, nil
+	}
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLink{Gid: gid}.TName()).
+		Where("gid = ? AND uri IN ?", gid, uris).
+		Order("id").
+		Find(&list).Error
+	return list, err
+}
+
+// Scan 基于游标遍历分组下的短链接
+// 返回 id 大于 cursor 的至多 limit 条记录,调用方以最后一条记录的 id 作为下一次的 cursor
+// 用于导出等需要遍历全部数据的场景,避免深分页
+func (d *shortLinkDao) Scan(ctx context.Context, gid string, cursor uint, limit int) ([]*model.ShortLink, error) {
+	var list []*model.ShortLink
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLink{Gid: gid}.TName()).
+		Where("gid = ? AND id > ?", gid, cursor).
+		Order("id").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
 // Count 计算总数
 func (d *shortLinkDao) Count(ctx context.Context, gid string) (int64, error) {
 	count, err := cache.ShortLinkGroupCountCache().Get(ctx, gid)
@@ -151,7 +273,8 @@ func (d *shortLinkDao) Count(ctx context.Context, gid string) (int64, error) {
 			// 从数据库中查询
 			tableName := model.ShortLink{Gid: gid}.TName()
 			total := new(int64)
-			err = d.db.WithContext(ctx).Table(tableName).Where("gid = ?", gid).Count(total).Error
+			// 指定 Model 以排除回收站中(软删除)的短链接
+			err = d.db.WithContext(ctx).Table(tableName).Model(&model.ShortLink{}).Where("gid = ?", gid).Count(total).Error
 			if err != nil {
 				// 设置空值来防御缓存穿透
 				if errors.Is(err, custom_err.ErrRecordNotFound) {
@@ -186,25 +309,35 @@ func (d *shortLinkDao) Count(ctx context.Context, gid string) (int64, error) {
 // todo 封装一个计算完成的方法
 
 // Delete 删除短链接
+// 短链接软删除后进入回收站,同时写入回收站记录以保留 uri
 func (d *shortLinkDao) Delete(ctx context.Context, uri string) error {
 	var gid string
 	redirectTableName := model.Redirect{
 		Uri: uri,
 	}.TName()
 	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		err := d.db.WithContext(ctx).Table(redirectTableName).Select("gid").Where("uri = ?", uri).Row().Scan(&gid)
+		err := tx.Table(redirectTableName).Select("gid").Where("uri = ?", uri).Row().Scan(&gid)
 		if err != nil {
+			// Row().Scan 不会返回 gorm 的错误
+			if errors.Is(err, sql.ErrNoRows) {
+				return custom_err.ErrRecordNotFound
+			}
 			return err
 		}
-		shortLinkTableName := model.ShortLink{Uri: gid}.TName()
-		err = d.db.WithContext(ctx).Table(redirectTableName).Where("uri = ?", uri).Delete(&model.Redirect{}).Error
+		group, err := NewShortLinkGroupDao(d.db).GetByGid(ctx, gid)
 		if err != nil {
 			return err
 		}
-		err = d.db.WithContext(ctx).Table(shortLinkTableName).Where("uri = ?", uri).Delete(&model.ShortLink{}).Error
-		return err
+		return deleteInTx(tx, gid, group.CUsername, uri)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// 排行榜不会随 binlog 更新,此处直接移除
+	if err = cache.ShortLinkStats().RemoveRank(ctx, gid, uri); err != nil {
+		logger.Warn("移除短链接排行失败", logger.Err(err), logger.String("uri", uri))
+	}
+	return nil
 }
 
 func (d *shortLinkDao) GeRedirectByURI(ctx context.Context, uri string) (*model.Redirect, error) {
@@ -270,57 +403,177 @@ func (d *shortLinkDao) GeRedirectByURI(ctx context.Context, uri string) (*model.
 	return nil, err
 }
 
-// Update 更新短链接
-func (d *shortLinkDao) Update(ctx context.Context, shortLink *model.ShortLink) error {
-	redirect := &model.Redirect{
-		Uri:         shortLink.Uri,
-		Gid:         shortLink.Gid,
-		OriginalURL: shortLink.OriginUrl,
+// GetByURI 根据 uri 查询短链接
+// 短链接按 gid 分表,因此先从 redirect 表中查出 gid,此处不经过缓存,用于需要读取最新数据的场景
+func (d *shortLinkDao) GetByURI(ctx context.Context, uri string) (*model.ShortLink, error) {
+	redirect := &model.Redirect{Uri: uri}
+	err := d.db.WithContext(ctx).Table(redirect.TName()).Where("uri = ?", uri).First(redirect).Error
+	if err != nil {
+		return nil, err
+	}
+	shortLink := &model.ShortLink{Gid: redirect.Gid}
+	err = d.db.WithContext(ctx).Table(shortLink.TName()).Where("gid = ? AND uri = ?", redirect.Gid, uri).First(shortLink).Error
+	if err != nil {
+		return nil, err
 	}
+	return shortLink, nil
+}
+
+// HasURI 查询短链接是否已经被占用
+func (d *shortLinkDao) HasURI(ctx context.Context, uri string) (bool, error) {
+	//1. 在布隆过滤器中查询
+	exist, err := cache.BFCache().BFExists(ctx, "uri", uri)
+	if err != nil {
+		return true, err
+	}
+	// 布隆过滤器认为不存在，就是真不存在
+	if !exist {
+		return false, nil
+	}
+	//2. 在数据库中查询,此处不写缓存,避免大量的空值占用缓存
+	var count int64
+	err = d.db.WithContext(ctx).Table(model.Redirect{Uri: uri}.TName()).Where("uri = ?", uri).Count(&count).Error
+	if err != nil {
+		return true, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	//3. 回收站中的短链接同样占用 uri
+	err = d.db.WithContext(ctx).Table(model.RecycleBin{Uri: uri}.TName()).Where("uri = ?", uri).Count(&count).Error
+	if err != nil {
+		return true, err
+	}
+	return count > 0, nil
+}
+
+// Update 更新短链接
+// 短链接的全部可修改字段都会被写入(包括零值),history 不为空时在同一事务中记录修改历史
+func (d *shortLinkDao) Update(ctx context.Context, shortLink *model.ShortLink, history *model.ShortLinkHistory) error {
 	// 同时更新短链接和重定向
-	err := d.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Table(redirect.TName()).WithContext(ctx).
-			Where("uri = ?", redirect.Uri).Updates(redirect).Error; err != nil {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateInTx(tx, shortLink); err != nil {
 			return err
 		}
-		return tx.Table(shortLink.TName()).WithContext(ctx).
-			Where("uri = ?", shortLink.Uri).Updates(shortLink).Error
-
+		return createHistory(tx, history)
 	})
 	return err
 
 }
 
+// updateInTx 在事务中更新短链接的可修改字段与对应的 redirect
+func updateInTx(tx *gorm.DB, shortLink *model.ShortLink) error {
+	redirect := model.NewRedirect(shortLink)
+	if err := tx.Table(redirect.TName()).
+		Where("uri = ?", redirect.Uri).Select(model.RedirectColumns).Updates(redirect).Error; err != nil {
+		return err
+	}
+	return tx.Table(shortLink.TName()).
+		Where("gid = ? AND uri = ?", shortLink.Gid, shortLink.Uri).
+		Select(shortLinkMutableColumns).Updates(shortLink).Error
+}
+
+// moveInTx 在事务中将短链接移动到新的分组
+// 短链接按 gid 分表,需要先删除,再插入到新的分表中
+func moveInTx(tx *gorm.DB, shortLink *model.ShortLink, newGid string) error {
+	oldGid := shortLink.Gid
+	redirect := model.NewRedirect(shortLink)
+	redirect.Gid = newGid
+	// redirect 路由可以直接更新
+	if err := tx.Table(redirect.TName()).
+		Where("uri = ?", redirect.Uri).Select(model.RedirectColumns).Updates(redirect).Error; err != nil {
+		return err
+	}
+	tableName := shortLink.TName()
+	// 定位到原来的短链接
+	old := new(model.ShortLink)
+	if err := tx.Table(tableName).Where("gid = ? AND uri = ?", oldGid, shortLink.Uri).First(old).Error; err != nil {
+		return err
+	}
+	// 硬删除原来的短链接
+	if err := tx.Table(tableName).Where("id = ?", old.ID).Unscoped().Delete(old).Error; err != nil {
+		return err
+	}
+	// 插入新的短链接,保留原有的创建时间
+	shortLink.ID = 0
+	shortLink.CreatedAt = old.CreatedAt
+	shortLink.Gid = newGid
+	return tx.Table(shortLink.TName()).Create(shortLink).Error
+}
+
+// deleteInTx 在事务中删除短链接
+// redirect 记录被删除,短链接软删除后进入回收站,同时写入回收站记录以保留 uri;
+// owner 为分组创建人,其名下该短链接的标签关系被标记为回收,不再计入标签统计
+func deleteInTx(tx *gorm.DB, gid, owner, uri string) error {
+	err := tx.Table(model.Redirect{Uri: uri}.TName()).Where("uri = ?", uri).Delete(&model.Redirect{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Table(model.ShortLink{Gid: gid}.TName()).Where("gid = ? AND uri = ?", gid, uri).Delete(&model.ShortLink{}).Error
+	if err != nil {
+		return err
+	}
+	recycled := &model.RecycleBin{Uri: uri, Gid: gid, CUsername: owner}
+	if err = tx.Table(recycled.TName()).Create(recycled).Error; err != nil {
+		return err
+	}
+	return setLinkTagsRecycled(tx, owner, uri, true)
+}
+
+// shortLinkMutableColumns 更新短链接时允许修改的字段
+var shortLinkMutableColumns = []string{"origin_url", "domain", "description", "valid_date_type", "valid_time", "enable", "redirect_code", "utm_template", "query_mode"}
+
+// createHistory 记录短链接的修改历史
+func createHistory(tx *gorm.DB, history *model.ShortLinkHistory) error {
+	if history == nil {
+		return nil
+	}
+	return tx.Table(history.TName()).Create(history).Error
+}
+
 // UpdateWithMove 更新短链接
 // 取出短链接，移动到新的分组
-func (d *shortLinkDao) UpdateWithMove(ctx context.Context, shortLink *model.ShortLink, newGid string) error {
-	redirect := &model.Redirect{
-		Uri:         shortLink.Uri,
-		Gid:         newGid,
-		OriginalURL: shortLink.OriginUrl,
-	}
+func (d *shortLinkDao) UpdateWithMove(ctx context.Context, shortLink *model.ShortLink, newGid string, history *model.ShortLinkHistory) error {
+	oldGid := shortLink.Gid
 	// 同时更新短链接和重定向
-	err := d.db.Transaction(func(tx *gorm.DB) error {
-		// redirect 路由可以直接更新
-		if err := tx.Table(redirect.TName()).WithContext(ctx).
-			Where("uri = ?", redirect.Uri).Updates(redirect).Error; err != nil {
-			return err
-		}
-		tableName := shortLink.TName()
-		// shortLink 需要先删除，再插入
-		// 定位到原来的短链接
-		if err := tx.Table(tableName).WithContext(ctx).Where("uri = ?", shortLink.Uri).Find(shortLink).Error; err != nil {
-			return err
-		}
-		// 硬删除原来的短链接
-		if err := tx.Table(tableName).WithContext(ctx).Where("id = ?", shortLink.ID).Unscoped().Delete(shortLink).Error; err != nil {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := moveInTx(tx, shortLink, newGid); err != nil {
 			return err
 		}
-		// 插入新的短链接
-		shortLink.ID = 0
-		shortLink.Gid = newGid
-		return tx.Table(shortLink.TName()).WithContext(ctx).Create(shortLink).Error
+		return createHistory(tx, history)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// 访问统计跟随短链接移动到新的分组排行榜中
+	if err = cache.ShortLinkStats().RemoveRank(ctx, oldGid, shortLink.Uri); err != nil {
+		logger.Warn("移除短链接排行失败", logger.Err(err), logger.String("uri", shortLink.Uri))
+	}
+	if err = cache.ShortLinkStats().RebuildRank(ctx, newGid, []string{shortLink.Uri}); err != nil {
+		logger.Warn("写入短链接排行失败", logger.Err(err), logger.String("uri", shortLink.Uri))
+	}
+	oldGroup, err := NewShortLinkGroupDao(d.db).GetByGid(ctx, oldGid)
+	if err != nil {
+		logger.Warn("查询短链接原分组失败", logger.Err(err), logger.String("uri", shortLink.Uri))
+		return nil
+	}
+	d.clearTagsOnOwnerChange(ctx, shortLink.Uri, oldGroup.CUsername, newGid)
+	return nil
+
+}
 
+// clearTagsOnOwnerChange 短链接的标签保存在分组创建人名下,移动到其他人的分组后原有的标签不再适用,将其清除
+// oldOwner 为原分组的创建人,原分组可能已被删除,因此由调用方传入
+func (d *shortLinkDao) clearTagsOnOwnerChange(ctx context.Context, uri, oldOwner, newGid string) {
+	newGroup, err := NewShortLinkGroupDao(d.db).GetByGid(ctx, newGid)
+	if err != nil {
+		logger.Warn("查询短链接新分组失败", logger.Err(err), logger.String("uri", uri))
+		return
+	}
+	if oldOwner == newGroup.CUsername {
+		return
+	}
+	if err = NewTagDao(d.db).SetLinkTags(ctx, oldOwner, uri, nil); err != nil {
+		logger.Warn("清理短链接标签失败", logger.Err(err), logger.String("uri", uri))
+	}
 }
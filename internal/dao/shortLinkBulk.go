@@ -0,0 +1,174 @@
+package dao
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/model"
+	"context"
+
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ShortLinkChange 批量修改中的一项
+// After 为修改后的短链接,After.Gid 与 Before.Gid 不同时将短链接移动到新的分组
+type ShortLinkChange struct {
+	Before *model.ShortLink
+	After  *model.ShortLink
+}
+
+// GetByURIs 批量查询短链接,不存在的 uri 不会出现在结果中
+// 先按 redirect 的分表查出 gid,再按短链接的分表查询
+func (d *shortLinkDao) GetByURIs(ctx context.Context, uris []string) (map[string]*model.ShortLink, error) {
+	result := make(map[string]*model.ShortLink, len(uris))
+	redirectShards := make(map[string][]string)
+	for _, uri := range uris {
+		tableName := model.Redirect{Uri: uri}.TName()
+		redirectShards[tableName] = append(redirectShards[tableName], uri)
+	}
+	linkShards := make(map[string][]string)
+	for tableName, shardURIs := range redirectShards {
+		var redirects []*model.Redirect
+		err := d.db.WithContext(ctx).Table(tableName).Where("uri IN ?", shardURIs).Find(&redirects).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, redirect := range redirects {
+			linkTable := model.ShortLink{Gid: redirect.Gid}.TName()
+			linkShards[linkTable] = append(linkShards[linkTable], redirect.Uri)
+		}
+	}
+	for tableName, shardURIs := range linkShards {
+		var links []*model.ShortLink
+		err := d.db.WithContext(ctx).Table(tableName).Where("uri IN ?", shardURIs).Find(&links).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, sl := range links {
+			result[sl.Uri] = sl
+		}
+	}
+	return result, nil
+}
+
+// BulkUpdate 批量修改短链接,同一分表中的短链接在同一个事务中修改
+// owner 为短链接原分组的创建人,为空时按分组查询;原分组已被删除时由调用方传入
+// 返回失败的 uri 及其原因,某个分表的事务失败不影响其他分表
+func (d *shortLinkDao) BulkUpdate(ctx context.Context, changes []*ShortLinkChange, actor, owner string) map[string]error {
+	shards := make(map[string][]*ShortLinkChange)
+	for _, change := range changes {
+		tableName := change.Before.TName()
+		shards[tableName] = append(shards[tableName], change)
+	}
+	failed := make(map[string]error)
+	owners := make(map[string]string)
+	groupDao := NewShortLinkGroupDao(d.db)
+	for _, shard := range shards {
+		err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, change := range shard {
+				history := &model.ShortLinkHistory{
+					Uri:    change.Before.Uri,
+					Actor:  actor,
+					Action: model.HistoryActionUpdate,
+					Before: model.NewShortLinkSnapshot(change.Before),
+					After:  model.NewShortLinkSnapshot(change.After),
+				}
+				var err error
+				if change.After.Gid == change.Before.Gid {
+					err = updateInTx(tx, change.After)
+				} else {
+					newGid := change.After.Gid
+					change.After.Gid = change.Before.Gid
+					err = moveInTx(tx, change.After, newGid)
+				}
+				if err != nil {
+					return err
+				}
+				if err = createHistory(tx, history); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			for _, change := range shard {
+				failed[change.Before.Uri] = err
+			}
+			continue
+		}
+		// 访问统计跟随短链接移动到新的分组排行榜中
+		for _, change := range shard {
+			if change.After.Gid == change.Before.Gid {
+				continue
+			}
+			if err = cache.ShortLinkStats().RemoveRank(ctx, change.Before.Gid, change.Before.Uri); err != nil {
+				logger.Warn("移除短链接排行失败", logger.Err(err), logger.String("uri", change.Before.Uri))
+			}
+			if err = cache.ShortLinkStats().RebuildRank(ctx, change.After.Gid, []string{change.After.Uri}); err != nil {
+				logger.Warn("写入短链接排行失败", logger.Err(err), logger.String("uri", change.After.Uri))
+			}
+			oldOwner, ok := owners[change.Before.Gid]
+			if !ok {
+				oldOwner = owner
+				if oldOwner == "" {
+					group, err := groupDao.GetByGid(ctx, change.Before.Gid)
+					if err != nil {
+						logger.Warn("查询短链接原分组失败", logger.Err(err), logger.String("uri", change.Before.Uri))
+						continue
+					}
+					oldOwner = group.CUsername
+				}
+				owners[change.Before.Gid] = oldOwner
+			}
+			d.clearTagsOnOwnerChange(ctx, change.After.Uri, oldOwner, change.After.Gid)
+		}
+	}
+	return failed
+}
+
+// BulkDelete 批量删除短链接,删除后进入回收站,同一分表中的短链接在同一个事务中删除
+// owner 为短链接所在分组的创建人,为空时按分组查询;分组已被删除时由调用方传入
+// 返回失败的 uri 及其原因,某个分表的事务失败不影响其他分表
+func (d *shortLinkDao) BulkDelete(ctx context.Context, links []*model.ShortLink, owner string) map[string]error {
+	shards := make(map[string][]*model.ShortLink)
+	for _, sl := range links {
+		tableName := sl.TName()
+		shards[tableName] = append(shards[tableName], sl)
+	}
+	failed := make(map[string]error)
+	owners := make(map[string]string)
+	groupDao := NewShortLinkGroupDao(d.db)
+	for _, shard := range shards {
+		err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, sl := range shard {
+				slOwner, ok := owners[sl.Gid]
+				if !ok {
+					slOwner = owner
+					if slOwner == "" {
+						group, err := groupDao.GetByGid(ctx, sl.Gid)
+						if err != nil {
+							return err
+						}
+						slOwner = group.CUsername
+					}
+					owners[sl.Gid] = slOwner
+				}
+				if err := deleteInTx(tx, sl.Gid, slOwner, sl.Uri); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			for _, sl := range shard {
+				failed[sl.Uri] = err
+			}
+			continue
+		}
+		for _, sl := range shard {
+			if err = cache.ShortLinkStats().RemoveRank(ctx, sl.Gid, sl.Uri); err != nil {
+				logger.Warn("移除短链接排行失败", logger.Err(err), logger.String("uri", sl.Uri))
+			}
+		}
+	}
+	return failed
+}
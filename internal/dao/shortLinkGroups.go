@@ -7,8 +7,11 @@ import (
 	"time"
 
 	"SnapLink/internal/cache"
+	"SnapLink/internal/custom_err"
 	"SnapLink/internal/model"
 
+	"github.com/google/uuid"
+	"github.com/zhufuyi/sponge/pkg/logger"
 	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
@@ -20,9 +23,22 @@ type ShortLinkGroupDao interface {
 	Create(ctx context.Context, record *model.ShortLinkGroup) error
 	GetAllByCUser(ctx context.Context, cUser string) ([]*model.ShortLinkGroup, error)
 	GetAll(ctx context.Context) ([]*model.ShortLinkGroup, error)
+	GetByGid(ctx context.Context, gid string) (*model.ShortLinkGroup, error)
+	GetDefault(ctx context.Context, username string) (*model.ShortLinkGroup, error)
 	UpdateByGidAndUsername(ctx context.Context, gid string, name, username string) (*model.ShortLinkGroup, error)
 	UpdateSortOrderByGidAndUsername(ctx context.Context, gids []string, sortOrders []int, username string) error
-	DelByGidAndUsername(ctx context.Context, gid, username string) error
+	UpdateSettings(ctx context.Context, group *model.ShortLinkGroup, settings model.GroupSettings) error
+	UpdateParent(ctx context.Context, group *model.ShortLinkGroup, parentGid string) error
+	DelByGidAndUsername(ctx context.Context, gid, username, strategy, targetGid string) (*model.GroupDeleteTask, error)
+	GetDeleteTask(ctx context.Context, gid, username string) (*model.GroupDeleteTask, error)
+	ListRunningDeleteTasks(ctx context.Context) ([]*model.GroupDeleteTask, error)
+	SaveDeleteTaskProgress(ctx context.Context, task *model.GroupDeleteTask) error
+	GetMember(ctx context.Context, gid, username string) (*model.GroupMember, error)
+	CreateMember(ctx context.Context, member *model.GroupMember) error
+	UpdateMember(ctx context.Context, member *model.GroupMember) error
+	DeleteMember(ctx context.Context, gid, username string) error
+	ListMembers(ctx context.Context, gid string) ([]*model.GroupMember, error)
+	ListMemberships(ctx context.Context, username, status string) ([]*model.GroupMember, error)
 }
 type shortLinkGroupsDao struct {
 	db  *gorm.DB
@@ -83,21 +99,84 @@ func (d *shortLinkGroupsDao) GetAllByCUser(ctx context.Context, cUser string) ([
 }
 
 // GetAll 获取所有的分组
-func (d *shortLinkGroupsDao) GetAll(ctx context.Context) (result []*model.ShortLinkGroup, err error) {
-	for i := 0; i < model.SLGroupShardingNum; i++ {
-		var records []*model.ShortLinkGroup
-		tableName := fmt.Sprintf("t_link_group%d", i)
-		err = d.db.Table(tableName).WithContext(ctx).Find(&records).Error
+func (d *shortLinkGroupsDao) GetAll(ctx context.Context) ([]*model.ShortLinkGroup, error) {
+	q := &ScatterQuery[*model.ShortLinkGroup]{Prefix: model.SLGroupPrefix, ShardingNum: model.SLGroupShardingNum}
+	records, _, err := q.Find(ctx, d.db)
+	return records, err
+}
+
+// GetByGid 根据 gid 获取分组
+// 分组表按创建人分表,此处并发查询全部分表,并对结果进行缓存
+func (d *shortLinkGroupsDao) GetByGid(ctx context.Context, gid string) (*model.ShortLinkGroup, error) {
+	group, err := cache.GroupInfo().Get(ctx, gid)
+	if err == nil {
+		if group.Gid == "" {
+			return nil, custom_err.ErrRecordNotFound
+		}
+		return group, nil
+	}
+	val, err, _ := d.sfg.Do("gid:"+gid, func() (interface{}, error) {
+		q := &ScatterQuery[*model.ShortLinkGroup]{
+			Prefix:      model.SLGroupPrefix,
+			ShardingNum: model.SLGroupShardingNum,
+			Scope:       func(db *gorm.DB) *gorm.DB { return db.Where("gid = ?", gid) },
+			Concurrency: model.SLGroupShardingNum,
+		}
+		found, err := q.First(ctx, d.db)
 		if err != nil {
-			return nil, err
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			// 设置空值来防御缓存穿透
+			if err := cache.GroupInfo().SetCacheWithNotFound(ctx, gid); err != nil {
+				logger.Warn("设置缓存失败", logger.Err(err), logger.String("gid", gid))
+			}
+			return nil, custom_err.ErrRecordNotFound
 		}
+		if err := cache.GroupInfo().Set(ctx, gid, found); err != nil {
+			logger.Warn("设置缓存失败", logger.Err(err), logger.String("gid", gid))
+		}
+		return found, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*model.ShortLinkGroup), nil
+}
 
+// GetDefault 获取用户的默认分组
+// 默认分组在注册时创建,此前注册的用户没有默认分组,首次使用时补建
+func (d *shortLinkGroupsDao) GetDefault(ctx context.Context, username string) (*model.ShortLinkGroup, error) {
+	val, err, _ := d.sfg.Do("default:"+username, func() (interface{}, error) {
+		groups, err := d.GetAllByCUser(ctx, username)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, records...)
+		for _, group := range groups {
+			if group.IsDefault {
+				return group, nil
+			}
+		}
+		group := newDefaultGroup(username)
+		if err = d.Create(ctx, group); err != nil {
+			return nil, err
+		}
+		return group, cache.SLGroup().Del(ctx, username)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*model.ShortLinkGroup), nil
+}
+
+// newDefaultGroup 生成用户的默认分组
+func newDefaultGroup(username string) *model.ShortLinkGroup {
+	return &model.ShortLinkGroup{
+		Gid:       uuid.NewString(),
+		Name:      model.DefaultGroupName,
+		CUsername: username,
+		IsDefault: true,
 	}
-	return result, nil
 }
 
 // UpdateByGidAndUsername 根据gid更新分组名称
@@ -157,15 +236,67 @@ func (d *shortLinkGroupsDao) UpdateSortOrderByGidAndUsername(ctx context.Context
 	return nil
 }
 
+// UpdateSettings 修改分组的默认设置
+func (d *shortLinkGroupsDao) UpdateSettings(ctx context.Context, group *model.ShortLinkGroup, settings model.GroupSettings) error {
+	group.Settings = settings
+	err := d.db.WithContext(ctx).Table(group.TName()).
+		Where("gid = ?", group.Gid).
+		Select("settings", "updated_at").
+		Updates(group).Error
+	if err != nil {
+		return err
+	}
+	if err = cache.GroupInfo().Del(ctx, group.Gid); err != nil {
+		logger.Warn("删除缓存失败", logger.Err(err), logger.String("gid", group.Gid))
+	}
+	return cache.SLGroup().Del(ctx, group.CUsername)
+}
+
+// UpdateParent 修改分组的父分组,parentGid 为空时移动到顶层
+func (d *shortLinkGroupsDao) UpdateParent(ctx context.Context, group *model.ShortLinkGroup, parentGid string) error {
+	group.ParentGid = parentGid
+	err := d.db.WithContext(ctx).Table(group.TName()).
+		Where("gid = ?", group.Gid).
+		Select("parent_gid", "updated_at").
+		Updates(group).Error
+	if err != nil {
+		return err
+	}
+	if err = cache.GroupInfo().Del(ctx, group.Gid); err != nil {
+		logger.Warn("删除缓存失败", logger.Err(err), logger.String("gid", group.Gid))
+	}
+	return cache.SLGroup().Del(ctx, group.CUsername)
+}
+
 // DelByGidAndUsername 根据gid删除分组
-func (d *shortLinkGroupsDao) DelByGidAndUsername(ctx context.Context, gid, username string) error {
+// 分组与删除任务在同一个事务中写入,分组内的短链接由后台任务按 strategy 处理
+func (d *shortLinkGroupsDao) DelByGidAndUsername(ctx context.Context, gid, username, strategy, targetGid string) (*model.GroupDeleteTask, error) {
 	group := &model.ShortLinkGroup{
 		Gid:       gid,
 		CUsername: username,
 	}
-	err := d.db.Table(group.TName()).WithContext(ctx).Where("gid = ?", gid).Delete(group).Error
+	task := &model.GroupDeleteTask{
+		Gid:       gid,
+		CUsername: username,
+		Strategy:  strategy,
+		TargetGid: targetGid,
+		Status:    model.GroupDeleteTaskRunning,
+	}
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Table(group.TName()).Where("gid = ?", gid).Delete(group)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return custom_err.ErrRecordNotFound
+		}
+		return tx.Table(task.TName()).Create(task).Error
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err = cache.GroupInfo().Del(ctx, gid); err != nil {
+		logger.Warn("删除缓存失败", logger.Err(err), logger.String("gid", gid))
 	}
-	return cache.SLGroup().Del(ctx, username)
+	return task, cache.SLGroup().Del(ctx, username)
 }
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"context"
+)
+
+// ListHistory 分页查询短链接的修改历史,最近的修改在前
+func (d *shortLinkDao) ListHistory(ctx context.Context, uri string, page, pageSize int) ([]*model.ShortLinkHistory, error) {
+	list := make([]*model.ShortLinkHistory, 0, pageSize)
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLinkHistory{Uri: uri}.TName()).
+		Where("uri = ?", uri).
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error
+	return list, err
+}
+
+// CountHistory 统计短链接的修改次数
+func (d *shortLinkDao) CountHistory(ctx context.Context, uri string) (int64, error) {
+	var total int64
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLinkHistory{Uri: uri}.TName()).
+		Where("uri = ?", uri).
+		Count(&total).Error
+	return total, err
+}
+
+// GetHistory 查询短链接的某一条修改历史
+func (d *shortLinkDao) GetHistory(ctx context.Context, uri string, id uint) (*model.ShortLinkHistory, error) {
+	history := &model.ShortLinkHistory{Uri: uri}
+	err := d.db.WithContext(ctx).
+		Table(history.TName()).
+		Where("id = ? AND uri = ?", id, uri).
+		First(history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
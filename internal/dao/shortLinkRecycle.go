@@ -0,0 +1,210 @@
+package dao
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/model"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize 清理过期回收站记录时每批次处理的条数
+const purgeBatchSize = 500
+
+// GetRecycled 查询回收站中的短链接记录
+func (d *shortLinkDao) GetRecycled(ctx context.Context, uri string) (*model.RecycleBin, error) {
+	recycled := &model.RecycleBin{Uri: uri}
+	err := d.db.WithContext(ctx).Table(recycled.TName()).Where("uri = ?", uri).First(recycled).Error
+	if err != nil {
+		return nil, err
+	}
+	return recycled, nil
+}
+
+// ListRecycled 分页查询分组回收站中的短链接,最近删除的在前
+func (d *shortLinkDao) ListRecycled(ctx context.Context, gid string, page, pageSize int) ([]*model.ShortLink, error) {
+	var list []*model.ShortLink
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLink{Gid: gid}.TName()).
+		Unscoped().
+		Where("gid = ? AND deleted_at IS NOT NULL", gid).
+		Order("deleted_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error
+	return list, err
+}
+
+// CountRecycled 统计分组回收站中的短链接数量
+func (d *shortLinkDao) CountRecycled(ctx context.Context, gid string) (int64, error) {
+	var total int64
+	err := d.db.WithContext(ctx).
+		Table(model.ShortLink{Gid: gid}.TName()).
+		Model(&model.ShortLink{}).
+		Unscoped().
+		Where("gid = ? AND deleted_at IS NOT NULL", gid).
+		Count(&total).Error
+	return total, err
+}
+
+// Restore 从回收站恢复短链接,重新创建 redirect 记录,返回恢复到的分组
+// 删除前所在的分组已被删除时,恢复到分组创建人的默认分组
+func (d *shortLinkDao) Restore(ctx context.Context, uri string) (string, error) {
+	recycled, err := d.GetRecycled(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	targetGid, err := d.restoreTarget(ctx, recycled)
+	if err != nil {
+		return "", err
+	}
+	shortLink := &model.ShortLink{Gid: recycled.Gid}
+	tableName := shortLink.TName()
+	err = d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(tableName).Unscoped().
+			Where("gid = ? AND uri = ? AND deleted_at IS NOT NULL", recycled.Gid, uri).
+			First(shortLink).Error
+		if err != nil {
+			return err
+		}
+		if targetGid == recycled.Gid {
+			err = tx.Table(tableName).Unscoped().
+				Where("id = ?", shortLink.ID).
+				Updates(map[string]any{"deleted_at": nil}).Error
+		} else {
+			err = restoreToGroup(tx, shortLink, targetGid)
+		}
+		if err != nil {
+			return err
+		}
+		redirect := model.NewRedirect(shortLink)
+		if err = tx.Table(redirect.TName()).Create(redirect).Error; err != nil {
+			return err
+		}
+		if err = setLinkTagsRecycled(tx, recycled.CUsername, uri, false); err != nil {
+			return err
+		}
+		return tx.Table(recycled.TName()).Where("id = ?", recycled.ID).Delete(&model.RecycleBin{}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	// 布隆过滤器重建后可能已不包含该 uri,恢复后需要重新加入,否则无法跳转
+	if err = cache.BFCache().BFAdd(ctx, "uri", uri); err != nil {
+		logger.Warn("布隆过滤器添加失败", logger.Err(err), logger.String("uri", uri))
+	}
+	if err = cache.ShortLinkStats().RebuildRank(ctx, targetGid, []string{uri}); err != nil {
+		logger.Warn("写入短链接排行失败", logger.Err(err), logger.String("uri", uri))
+	}
+	return targetGid, nil
+}
+
+// restoreTarget 短链接恢复到的分组
+// 旧版本的回收站记录没有保存分组创建人,所在分组已被删除时无法恢复
+func (d *shortLinkDao) restoreTarget(ctx context.Context, recycled *model.RecycleBin) (string, error) {
+	groupDao := NewShortLinkGroupDao(d.db)
+	_, err := groupDao.GetByGid(ctx, recycled.Gid)
+	if err == nil {
+		return recycled.Gid, nil
+	}
+	if !errors.Is(err, custom_err.ErrRecordNotFound) || recycled.CUsername == "" {
+		return "", err
+	}
+	group, err := groupDao.GetDefault(ctx, recycled.CUsername)
+	if err != nil {
+		return "", err
+	}
+	return group.Gid, nil
+}
+
+// restoreToGroup 在事务中将回收站中的短链接恢复到其他分组
+// 短链接按 gid 分表,需要硬删除原记录后插入到新的分表中,保留原有的创建时间
+func restoreToGroup(tx *gorm.DB, shortLink *model.ShortLink, gid string) error {
+	err := tx.Table(shortLink.TName()).Unscoped().Where("id = ?", shortLink.ID).Delete(&model.ShortLink{}).Error
+	if err != nil {
+		return err
+	}
+	shortLink.ID = 0
+	shortLink.Gid = gid
+	shortLink.DeletedAt = gorm.DeletedAt{}
+	return tx.Table(shortLink.TName()).Create(shortLink).Error
+}
+
+// Purge 彻底删除回收站中的短链接,删除后 uri 可以被重新使用
+func (d *shortLinkDao) Purge(ctx context.Context, uri string) error {
+	recycled, err := d.GetRecycled(ctx, uri)
+	if err != nil {
+		return err
+	}
+	err = d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(model.ShortLink{Gid: recycled.Gid}.TName()).Unscoped().
+			Where("gid = ? AND uri = ? AND deleted_at IS NOT NULL", recycled.Gid, uri).
+			Delete(&model.ShortLink{}).Error
+		if err != nil {
+			return err
+		}
+		// 修改历史同样需要删除,避免被复用该 uri 的新短链接继承
+		history := model.ShortLinkHistory{Uri: uri}
+		if err = tx.Table(history.TName()).Where("uri = ?", uri).Delete(&model.ShortLinkHistory{}).Error; err != nil {
+			return err
+		}
+		return tx.Table(recycled.TName()).Where("id = ?", recycled.ID).Delete(&model.RecycleBin{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	// 清理短链接的标签与访问统计,避免被新的短链接继承
+	// 旧版本的回收站记录没有保存分组创建人,需要按分组查询
+	owner := recycled.CUsername
+	if owner == "" {
+		var group *model.ShortLinkGroup
+		if group, err = NewShortLinkGroupDao(d.db).GetByGid(ctx, recycled.Gid); err == nil {
+			owner = group.CUsername
+		}
+	}
+	if owner != "" {
+		err = NewTagDao(d.db).SetLinkTags(ctx, owner, uri, nil)
+	}
+	if err != nil && !errors.Is(err, custom_err.ErrRecordNotFound) {
+		logger.Warn("清理短链接标签失败", logger.Err(err), logger.String("uri", uri))
+	}
+	if err = cache.ShortLinkStats().Clear(ctx, uri); err != nil {
+		logger.Warn("清理短链接统计失败", logger.Err(err), logger.String("uri", uri))
+	}
+	return nil
+}
+
+// PurgeExpired 彻底删除在 before 之前进入回收站的短链接,返回删除的数量
+func (d *shortLinkDao) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	for i := 0; i < model.RecycleBinShardingNum; i++ {
+		tableName := fmt.Sprintf("%s-%d", model.RecycleBinPrefix, i)
+		for {
+			var uris []string
+			err := d.db.WithContext(ctx).Table(tableName).
+				Where("created_at < ?", before).
+				Order("id").
+				Limit(purgeBatchSize).
+				Pluck("uri", &uris).Error
+			if err != nil {
+				return purged, err
+			}
+			for _, uri := range uris {
+				// 并发清理时记录可能已经被其他实例删除
+				if err = d.Purge(ctx, uri); err != nil && !errors.Is(err, custom_err.ErrRecordNotFound) {
+					return purged, err
+				}
+				purged++
+			}
+			if len(uris) < purgeBatchSize {
+				break
+			}
+		}
+	}
+	return purged, nil
+}
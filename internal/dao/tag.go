@@ -0,0 +1,196 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var _ TagDao = (*tagDao)(nil)
+
+var ErrTagNotFound = errors.New("tag not found")
+
+// TagDao 标签与短链接标签关系
+// 标签与关系表都按创建人分表,同一用户的数据总在同一张分表中,因此可以使用事务与联表查询
+type TagDao interface {
+	Create(ctx context.Context, tag *model.Tag) error
+	ListByUsername(ctx context.Context, username string) ([]*model.Tag, error)
+	GetByIDs(ctx context.Context, username string, ids []uint) ([]*model.Tag, error)
+	Update(ctx context.Context, tag *model.Tag) error
+	Delete(ctx context.Context, username string, id uint) error
+	CountLinks(ctx context.Context, username string) (map[uint]int64, error)
+	SetLinkTags(ctx context.Context, username, uri string, tagIDs []uint) error
+	GetLinkTags(ctx context.Context, username string, uris []string) (map[string][]*model.Tag, error)
+	URIsByTags(ctx context.Context, username string, tagIDs []uint) ([]string, error)
+}
+
+type tagDao struct {
+	db *gorm.DB
+}
+
+// NewTagDao creating the dao interface
+func NewTagDao(db *gorm.DB) TagDao {
+	return &tagDao{db: db}
+}
+
+// Create 创建标签
+func (d *tagDao) Create(ctx context.Context, tag *model.Tag) error {
+	return d.db.WithContext(ctx).Table(tag.TName()).Create(tag).Error
+}
+
+// ListByUsername 获取用户的全部标签
+func (d *tagDao) ListByUsername(ctx context.Context, username string) ([]*model.Tag, error) {
+	tags := make([]*model.Tag, 0)
+	err := d.db.WithContext(ctx).
+		Table(model.Tag{CUsername: username}.TName()).
+		Where("c_username = ?", username).
+		Order("id").
+		Find(&tags).Error
+	return tags, err
+}
+
+// GetByIDs 获取用户的指定标签,不属于该用户的标签会被忽略
+func (d *tagDao) GetByIDs(ctx context.Context, username string, ids []uint) ([]*model.Tag, error) {
+	tags := make([]*model.Tag, 0, len(ids))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	err := d.db.WithContext(ctx).
+		Table(model.Tag{CUsername: username}.TName()).
+		Where("c_username = ? AND id IN ?", username, ids).
+		Find(&tags).Error
+	return tags, err
+}
+
+// Update 更新标签名称与颜色
+func (d *tagDao) Update(ctx context.Context, tag *model.Tag) error {
+	tDB := d.db.WithContext(ctx).
+		Table(tag.TName()).
+		Where("id = ? AND c_username = ?", tag.ID, tag.CUsername).
+		Updates(map[string]any{"name": tag.Name, "color": tag.Color})
+	if tDB.Error != nil {
+		return tDB.Error
+	}
+	if tDB.RowsAffected == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// Delete 删除标签,同时删除其与短链接的关系
+func (d *tagDao) Delete(ctx context.Context, username string, id uint) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tDB := tx.Table(model.Tag{CUsername: username}.TName()).
+			Where("id = ? AND c_username = ?", id, username).
+			Delete(&model.Tag{})
+		if tDB.Error != nil {
+			return tDB.Error
+		}
+		if tDB.RowsAffected == 0 {
+			return ErrTagNotFound
+		}
+		return tx.Table(model.LinkTag{CUsername: username}.TName()).
+			Where("tag_id = ? AND c_username = ?", id, username).
+			Delete(&model.LinkTag{}).Error
+	})
+}
+
+// CountLinks 统计用户每个标签下的短链接数量,不包含回收站中的短链接
+func (d *tagDao) CountLinks(ctx context.Context, username string) (map[uint]int64, error) {
+	var rows []struct {
+		TagID uint
+		Total int64
+	}
+	err := d.db.WithContext(ctx).
+		Table(model.LinkTag{CUsername: username}.TName()).
+		Select("tag_id, COUNT(*) AS total").
+		Where("c_username = ? AND recycled = 0", username).
+		Group("tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Total
+	}
+	return counts, nil
+}
+
+// SetLinkTags 设置短链接的标签,覆盖原有的标签
+func (d *tagDao) SetLinkTags(ctx context.Context, username, uri string, tagIDs []uint) error {
+	tableName := model.LinkTag{CUsername: username}.TName()
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(tableName).
+			Where("c_username = ? AND uri = ?", username, uri).
+			Delete(&model.LinkTag{}).Error
+		if err != nil || len(tagIDs) == 0 {
+			return err
+		}
+		records := make([]*model.LinkTag, 0, len(tagIDs))
+		for _, id := range tagIDs {
+			records = append(records, &model.LinkTag{TagID: id, Uri: uri, CUsername: username})
+		}
+		return tx.Table(tableName).Create(&records).Error
+	})
+}
+
+// GetLinkTags 批量获取短链接的标签
+func (d *tagDao) GetLinkTags(ctx context.Context, username string, uris []string) (map[string][]*model.Tag, error) {
+	result := make(map[string][]*model.Tag, len(uris))
+	if len(uris) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		model.Tag
+		Uri string
+	}
+	linkTable := model.LinkTag{CUsername: username}.TName()
+	tagTable := model.Tag{CUsername: username}.TName()
+	err := d.db.WithContext(ctx).
+		Table("`"+linkTable+"` AS lt").
+		Select("t.id, t.name, t.color, lt.uri").
+		Joins("JOIN `"+tagTable+"` AS t ON t.id = lt.tag_id").
+		Where("lt.c_username = ? AND lt.uri IN ?", username, uris).
+		Order("t.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		tag := rows[i].Tag
+		result[rows[i].Uri] = append(result[rows[i].Uri], &tag)
+	}
+	return result, nil
+}
+
+// URIsByTags 获取同时拥有全部指定标签的短链接,不包含回收站中的短链接
+func (d *tagDao) URIsByTags(ctx context.Context, username string, tagIDs []uint) ([]string, error) {
+	uris := make([]string, 0)
+	if len(tagIDs) == 0 {
+		return uris, nil
+	}
+	err := d.db.WithContext(ctx).
+		Table(model.LinkTag{CUsername: username}.TName()).
+		Where("c_username = ? AND tag_id IN ? AND recycled = 0", username, tagIDs).
+		Group("uri").
+		Having("COUNT(DISTINCT tag_id) = ?", len(tagIDs)).
+		Pluck("uri", &uris).Error
+	return uris, err
+}
+
+// setLinkTagsRecycled 在事务中标记短链接的标签关系是否处于回收站中
+func setLinkTagsRecycled(tx *gorm.DB, username, uri string, recycled bool) error {
+	if username == "" {
+		return nil
+	}
+	flag := 0
+	if recycled {
+		flag = 1
+	}
+	return tx.Table(model.LinkTag{CUsername: username}.TName()).
+		Where("c_username = ? AND uri = ?", username, uri).
+		Update("recycled", flag).Error
+}
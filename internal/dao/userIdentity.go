@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"SnapLink/internal/model"
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+var instanceUserIdentity struct {
+	IUserIdentityDao
+	sync.Once
+}
+
+func UserIdentityDao() IUserIdentityDao {
+	instanceUserIdentity.Once.Do(func() {
+		instanceUserIdentity.IUserIdentityDao = NewUserIdentityDao(model.GetDB())
+	})
+	return instanceUserIdentity.IUserIdentityDao
+}
+
+// IUserIdentityDao 外部身份关联
+type IUserIdentityDao interface {
+	// Create 关联身份,身份已关联时返回 ErrDuplicateEntry
+	Create(ctx context.Context, identity *model.UserIdentity) error
+	// GetBySubject 根据身份提供方与用户标识查询关联,未关联时返回 gorm.ErrRecordNotFound
+	GetBySubject(ctx context.Context, issuer, subject string) (*model.UserIdentity, error)
+}
+
+type userIdentityDao struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityDao creating the dao interface
+func NewUserIdentityDao(db *gorm.DB) IUserIdentityDao {
+	return &userIdentityDao{db: db}
+}
+
+func (d *userIdentityDao) Create(ctx context.Context, identity *model.UserIdentity) error {
+	return d.db.WithContext(ctx).Table(identity.TName()).Create(identity).Error
+}
+
+func (d *userIdentityDao) GetBySubject(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	identity := &model.UserIdentity{Issuer: issuer, Subject: subject}
+	err := d.db.WithContext(ctx).
+		Table(identity.TName()).
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		First(identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
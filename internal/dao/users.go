@@ -3,6 +3,8 @@ package dao
 import (
 	"SnapLink/internal/cache"
 	"SnapLink/internal/model"
+	"SnapLink/internal/utils"
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
@@ -10,21 +12,41 @@ import (
 	"github.com/zhufuyi/sponge/pkg/ggorm/query"
 	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"sync"
 )
 
 var _ TUserDao = (*tUserDao)(nil)
 
+var instanceTUser struct {
+	TUserDao
+	sync.Once
+}
+
+func UserDao() TUserDao {
+	instanceTUser.Once.Do(func() {
+		instanceTUser.TUserDao = NewTUserDao(model.GetDB(), cache.NewTUserCache(model.GetCacheType()))
+	})
+	return instanceTUser.TUserDao
+}
+
 // TUserDao defining the dao interface
 type TUserDao interface {
 	GetDB() *gorm.DB
 	Create(ctx context.Context, table *model.TUser) error
 	Update(ctx context.Context, table *model.TUser) error
 	GetByUsername(ctx context.Context, username string) (*model.TUser, error)
+	GetByContact(ctx context.Context, kind, value string) (*model.TUser, error)
 	GetByCondition(ctx context.Context, condition *query.Conditions) (*model.TUser, error)
 	GetByConditionWithUsername(ctx context.Context, condition *query.Conditions, username string) (*model.TUser, error)
 	GetByColumns(ctx context.Context, params *query.Params) ([]*model.TUser, int64, error)
 	HasUsername(ctx context.Context, username string) (bool, error)
 	GetAllUserName(ctx context.Context) ([]string, error)
+	UpdateRole(ctx context.Context, username, role string) error
+	SetMailVerified(ctx context.Context, username, mail string) (bool, error)
+	UpdateTwoFactor(ctx context.Context, username string, enabled bool, secret, recoveryCodes string) error
+	ReplaceRecoveryCodes(ctx context.Context, username, old, recoveryCodes string) (bool, error)
 }
 
 type tUserDao struct {
@@ -50,20 +72,85 @@ func (d *tUserDao) GetDB() *gorm.DB {
 }
 
 // Create 创建用户记录
+// 用户与其默认分组在同一个事务中创建,保证新用户可以直接创建短链接
+// 手机号或邮箱已被其他用户占用时返回 ErrPhoneExist 或 ErrMailExist
 func (d *tUserDao) Create(ctx context.Context, u *model.TUser) error {
-	err := d.db.Table(u.TName()).WithContext(ctx).Create(u).Error
-	return err
+	group := newDefaultGroup(u.Username)
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先写入手机号与邮箱的唯一索引,已被占用时整个事务回滚
+		if err := createContact(tx, model.ContactPhone, utils.NormalizePhone(u.Phone), u.Username); err != nil {
+			return err
+		}
+		if err := createContact(tx, model.ContactMail, utils.NormalizeMail(u.Mail), u.Username); err != nil {
+			return err
+		}
+		if err := tx.Table(u.TName()).Create(u).Error; err != nil {
+			return err
+		}
+		return tx.Table(group.TName()).Create(group).Error
+	})
 }
 
 // Update 根据用户名更新用户信息
+// 修改手机号或邮箱时,在同一个事务中占用新的联系方式并释放旧的联系方式,已被占用时返回 ErrPhoneExist 或 ErrMailExist
 func (d *tUserDao) Update(ctx context.Context, table *model.TUser) error {
-	err := d.updateData(ctx, d.db, table)
-	// delete slCache
-	// todo 解耦为异步删除
-	//_ = d.deleteCache(ctx, table.ID)
+	if table.Phone == "" && table.Mail == "" {
+		return d.updateData(ctx, d.db, table)
+	}
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := &model.TUser{Username: table.Username}
+		err := tx.Table(old.TName()).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("username = ?", table.Username).Select("phone", "mail").Take(old).Error
+		if err != nil {
+			return err
+		}
+		if table.Phone != "" {
+			if err = replaceContact(tx, model.ContactPhone, utils.NormalizePhone(old.Phone), utils.NormalizePhone(table.Phone), table.Username); err != nil {
+				return err
+			}
+		}
+		if table.Mail != "" {
+			if err = replaceContact(tx, model.ContactMail, utils.NormalizeMail(old.Mail), utils.NormalizeMail(table.Mail), table.Username); err != nil {
+				return err
+			}
+		}
+		return d.updateData(ctx, tx, table)
+	})
+}
+
+// createContact 占用联系方式,value 为空时不占用
+func createContact(tx *gorm.DB, kind, value, username string) error {
+	if value == "" {
+		return nil
+	}
+	contact := &model.UserContact{Kind: kind, Value: value, Username: username}
+	err := tx.Table(contact.TName()).Create(contact).Error
+	if err != nil && ErrDuplicateEntry.Is(err) {
+		if kind == model.ContactPhone {
+			return ErrPhoneExist
+		}
+		return ErrMailExist
+	}
 	return err
 }
 
+// replaceContact 将用户的联系方式由 old 修改为 value
+func replaceContact(tx *gorm.DB, kind, old, value, username string) error {
+	if old == value {
+		return nil
+	}
+	if err := createContact(tx, kind, value, username); err != nil {
+		return err
+	}
+	if old == "" {
+		return nil
+	}
+	contact := &model.UserContact{Kind: kind, Value: old}
+	return tx.Table(contact.TName()).
+		Where("kind = ? AND value = ? AND username = ?", kind, old, username).
+		Delete(&model.UserContact{}).Error
+}
+
 func (d *tUserDao) updateData(ctx context.Context, db *gorm.DB, table *model.TUser) error {
 	if table.Username == "" {
 		return errors.New("username cannot be empty")
@@ -80,6 +167,7 @@ func (d *tUserDao) updateData(ctx context.Context, db *gorm.DB, table *model.TUs
 	}
 	if table.Mail != "" {
 		update["mail"] = table.Mail
+		update["mail_verified"] = table.MailVerified
 	}
 	return db.Table(table.TName()).WithContext(ctx).Where("username = ?", table.Username).Updates(update).Error
 }
@@ -106,23 +194,18 @@ func (d *tUserDao) updateData(ctx context.Context, db *gorm.DB, table *model.TUs
 //		},
 //	}
 //
-// PS: 此接口由于不带有 username 字段，所以会扫描所有的表
+// PS: 此接口由于不带有 username 字段，所以会并发扫描所有的表
 func (d *tUserDao) GetByCondition(ctx context.Context, c *query.Conditions) (*model.TUser, error) {
 	queryStr, args, err := c.ConvertToGorm()
 	if err != nil {
 		return nil, err
 	}
-	table := &model.TUser{}
-	for i := 0; i < model.TUserShardingNum; i++ {
-		err = d.db.WithContext(ctx).Table(fmt.Sprintf("%s-%d", model.TUserPrefix, i)).Where(queryStr, args...).First(table).Error
-		if err == nil {
-			return table, nil
-		}
+	q := &ScatterQuery[*model.TUser]{
+		Prefix:      model.TUserPrefix,
+		ShardingNum: model.TUserShardingNum,
+		Scope:       func(db *gorm.DB) *gorm.DB { return db.Where(queryStr, args...) },
 	}
-	if err != nil {
-		return nil, err
-	}
-	return table, nil
+	return q.First(ctx, d.db)
 }
 
 // GetByConditionWithUsername 效果同 GetByCondition，但是会带上 username 字段,因此只会扫描一个表
@@ -149,7 +232,7 @@ func (d *tUserDao) GetByConditionWithUsername(ctx context.Context, c *query.Cond
 //
 //	page: page number, starting from 0
 //	size: lines per page
-//	sort: sort fields, default is id backwards, you can add - sign before the field to indicate reverse order, no - sign to indicate ascending order, multiple fields separated by comma
+//	sort: sort fields, default is created_at backwards, you can add - sign before the field to indicate reverse order, no - sign to indicate ascending order, multiple fields separated by comma
 //
 // query parameters (not required):
 //
@@ -175,33 +258,87 @@ func (d *tUserDao) GetByConditionWithUsername(ctx context.Context, c *query.Cond
 //		},
 //	}
 //
-// 此为扫描所有的表
-// todo 实现全表扫描
+// 此为并发扫描所有的表,排序字段只支持 userSortFields 中的字段,各分表的 id 独立自增,不适合用于跨分表排序
 func (d *tUserDao) GetByColumns(ctx context.Context, params *query.Params) ([]*model.TUser, int64, error) {
 	queryStr, args, err := params.ConvertToGormConditions()
 	if err != nil {
 		return nil, 0, errors.New("query params error: " + err.Error())
 	}
+	order, less, err := parseUserSort(params.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, limit, offset := params.ConvertToPage()
+	q := &ScatterQuery[*model.TUser]{
+		Prefix:      model.TUserPrefix,
+		ShardingNum: model.TUserShardingNum,
+		Scope:       func(db *gorm.DB) *gorm.DB { return db.Where(queryStr, args...) },
+		Order:       order,
+		Less:        less,
+		Offset:      offset,
+		Limit:       limit,
+		Count:       params.Sort != "ignore count", // determine if count is required
+	}
+	return q.Find(ctx, d.db)
+}
 
-	var total int64
-	if params.Sort != "ignore count" { // determine if count is required
-		err = d.db.WithContext(ctx).Model(&model.TUser{}).Select([]string{"id"}).Where(queryStr, args...).Count(&total).Error
-		if err != nil {
-			return nil, 0, err
+// userSortFields 用户列表支持的排序字段,跨分表合并时在内存中按相同的规则排序
+var userSortFields = map[string]func(a, b *model.TUser) int{
+	"username":   func(a, b *model.TUser) int { return cmp.Compare(a.Username, b.Username) },
+	"created_at": func(a, b *model.TUser) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b *model.TUser) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	"real_name":  func(a, b *model.TUser) int { return cmp.Compare(a.RealName, b.RealName) },
+	"role":       func(a, b *model.TUser) int { return cmp.Compare(a.Role, b.Role) },
+}
+
+// parseUserSort 将排序参数转换为分表内的排序与合并后的排序,多个字段以逗号分隔,字段前加 - 表示倒序,默认按创建时间倒序;
+// 排序字段中没有用户名时,最后按全局唯一的用户名排序,保证分页结果稳定
+func parseUserSort(sort string) (string, func(a, b *model.TUser) bool, error) {
+	if sort == "" || sort == "ignore count" {
+		sort = "-created_at"
+	}
+	type sortField struct {
+		compare func(a, b *model.TUser) int
+		desc    bool
+	}
+	var (
+		orders      = make([]string, 0)
+		fields      = make([]sortField, 0)
+		hasUsername bool
+	)
+	for _, name := range strings.Split(sort, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		compare, ok := userSortFields[name]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSort, name)
 		}
-		if total == 0 {
-			return nil, total, nil
+		if desc {
+			orders = append(orders, name+" DESC")
+		} else {
+			orders = append(orders, name)
 		}
+		fields = append(fields, sortField{compare: compare, desc: desc})
+		hasUsername = hasUsername || name == "username"
 	}
-
-	records := []*model.TUser{}
-	order, limit, offset := params.ConvertToPage()
-	err = d.db.WithContext(ctx).Order(order).Limit(limit).Offset(offset).Where(queryStr, args...).Find(&records).Error
-	if err != nil {
-		return nil, 0, err
+	if !hasUsername {
+		orders = append(orders, "username")
+		fields = append(fields, sortField{compare: userSortFields["username"]})
 	}
-
-	return records, total, err
+	less := func(a, b *model.TUser) bool {
+		for _, field := range fields {
+			c := field.compare(a, b)
+			if field.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}
+	return strings.Join(orders, ", "), less, nil
 }
 
 // GetByUsername 根据用户名查询用户信息
@@ -226,6 +363,23 @@ func (d *tUserDao) GetByUsername(ctx context.Context, username string) (*model.T
 	return &user, nil
 }
 
+// GetByContact 根据手机号或邮箱查询用户信息,kind 为 model.ContactPhone 或 model.ContactMail
+func (d *tUserDao) GetByContact(ctx context.Context, kind, value string) (*model.TUser, error) {
+	if kind == model.ContactPhone {
+		value = utils.NormalizePhone(value)
+	} else {
+		value = utils.NormalizeMail(value)
+	}
+	contact := &model.UserContact{Kind: kind, Value: value}
+	err := d.db.WithContext(ctx).Table(contact.TName()).
+		Where("kind = ? AND value = ?", kind, value).
+		Select("username").Take(contact).Error
+	if err != nil {
+		return nil, err
+	}
+	return d.GetByUsername(ctx, contact.Username)
+}
+
 // HasUsername 查询用户名是否存在
 func (d *tUserDao) HasUsername(ctx context.Context, username string) (bool, error) {
 
@@ -255,14 +409,61 @@ func (d *tUserDao) HasUsername(ctx context.Context, username string) (bool, erro
 
 // GetAllUserName 获取所有的用户名
 func (d *tUserDao) GetAllUserName(ctx context.Context) ([]string, error) {
+	shards := make([][]string, model.TUserShardingNum)
+	err := scatterShards(ctx, model.TUserShardingNum, 0, func(ctx context.Context, shard int) error {
+		return d.db.WithContext(ctx).Table(fmt.Sprintf("%s-%d", model.TUserPrefix, shard)).Pluck("username", &shards[shard]).Error
+	})
+	if err != nil {
+		return nil, err
+	}
 	usernames := make([]string, 0)
-	for i := 0; i < model.TUserShardingNum; i++ {
-		tUsernames := make([]string, 0)
-		err := d.db.WithContext(ctx).Table(fmt.Sprintf("%s-%d", model.TUserPrefix, i)).Model(&model.TUser{}).Pluck("username", &tUsernames).Error
-		if err != nil {
-			return nil, err
-		}
-		usernames = append(usernames, tUsernames...)
+	for _, shard := range shards {
+		usernames = append(usernames, shard...)
 	}
 	return usernames, nil
 }
+
+// UpdateRole 修改用户的角色
+func (d *tUserDao) UpdateRole(ctx context.Context, username, role string) error {
+	u := &model.TUser{Username: username}
+	return d.db.WithContext(ctx).Table(u.TName()).Where("username = ?", username).Update("role", role).Error
+}
+
+// SetMailVerified 将用户的邮箱标记为已验证,邮箱已经修改为其他地址时返回 false
+func (d *tUserDao) SetMailVerified(ctx context.Context, username, mail string) (bool, error) {
+	u := &model.TUser{Username: username}
+	result := d.db.WithContext(ctx).Table(u.TName()).
+		Where("username = ? AND mail = ?", username, mail).
+		Update("mail_verified", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	// 已经验证过时影响行数同样为 0
+	err := d.db.WithContext(ctx).Table(u.TName()).
+		Where("username = ? AND mail = ? AND mail_verified = ?", username, mail, true).
+		Select("id").Take(u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UpdateTwoFactor 启用或关闭两步验证
+func (d *tUserDao) UpdateTwoFactor(ctx context.Context, username string, enabled bool, secret, recoveryCodes string) error {
+	u := &model.TUser{Username: username}
+	return d.db.WithContext(ctx).Table(u.TName()).
+		Where("username = ?", username).
+		Updates(map[string]interface{}{"totp_enabled": enabled, "totp_secret": secret, "recovery_codes": recoveryCodes}).Error
+}
+
+// ReplaceRecoveryCodes 恢复码仍为 old 时替换为新的恢复码,用于使用恢复码时防止并发重复使用
+func (d *tUserDao) ReplaceRecoveryCodes(ctx context.Context, username, old, recoveryCodes string) (bool, error) {
+	u := &model.TUser{Username: username}
+	result := d.db.WithContext(ctx).Table(u.TName()).
+		Where("username = ? AND recovery_codes = ?", username, old).
+		Update("recovery_codes", recoveryCodes)
+	return result.RowsAffected > 0, result.Error
+}
@@ -35,6 +35,7 @@ var (
 	PasswordShortError            = newErrCode(400, "A000121", "密码长度不够")
 	PhoneVerifyError              = newErrCode(400, "A000151", "手机格式校验失败")
 	PhoneExistError               = newErrCode(409, "A000152", "手机号已存在") // 409 Conflict 更适合表示资源冲突，如手机号已存在
+	MailExistError                = newErrCode(409, "A000161", "邮箱已存在")
 
 	// ========== 二级宏观错误码 系统请求缺少幂等Token ==========
 	IdempotentTokenNullError   = newErrCode(401, "A000200", "幂等Token为空") // 缺少必要的请求参数，400 Bad Request 更适合
@@ -44,6 +45,15 @@ var (
 	UserLoginError    = newErrCode(401, "A000300", "用户登录错误")
 	UserNotExistError = newErrCode(401, "A000301", "用户不存在")
 	UserPasswordError = newErrCode(401, "A000302", "密码错误")
+	TokenInvalidError = newErrCode(401, "A000303", "登录已失效,请重新登录")
+	LoginFailedError  = newErrCode(401, "A000304", "用户名或密码错误")
+	LoginLockedError  = newErrCode(429, "A000305", "登录失败次数过多,请稍后重试")
+	SSODisabledError  = newErrCode(404, "A000306", "未启用单点登录")
+	SSOLoginError     = newErrCode(401, "A000307", "单点登录失败,请重新登录")
+	SSOLinkedError    = newErrCode(409, "A000308", "该身份已关联其他账号")
+
+	// ========== 二级宏观错误码 访问权限错误 ==========
+	AccessForbiddenError = newErrCode(403, "A000310", "无权访问该资源") // 403 Forbidden 表示已登录但无权操作
 
 	// ========== 二级宏观错误码 限流 ==========
 	FlowLimitError = newErrCode(429, "A000400", "Too Many Requests") // 429 Too Many Requests 表示请求过多
@@ -0,0 +1,130 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AccessLogIndex 访问日志使用的索引,由 logstash 按天创建
+const AccessLogIndex = "logstash-accesslog-*"
+
+// accessLogCompositeSize 组合聚合每页的桶数量
+const accessLogCompositeSize = 1000
+
+// accessLogQuery 查询 uris 在 before(yyyy-MM-dd,本地时间)之前的访问日志
+func accessLogQuery(uris []string, before string) map[string]any {
+	return map[string]any{
+		"bool": map[string]any{
+			"filter": []any{
+				map[string]any{"terms": map[string]any{"info.uri.keyword": uris}},
+				map[string]any{"range": map[string]any{"@timestamp": map[string]any{
+					"lt":        before,
+					"format":    "yyyy-MM-dd",
+					"time_zone": time.Now().Format("-07:00"),
+				}}},
+			},
+		},
+	}
+}
+
+// AccessLogPV 统计 uris 在 before 之前的访问次数,没有访问日志的 uri 不会出现在结果中
+func AccessLogPV(ctx context.Context, uris []string, before string) (map[string]int64, error) {
+	pvs := make(map[string]int64, len(uris))
+	if len(uris) == 0 {
+		return pvs, nil
+	}
+	body := map[string]any{
+		"size":  0,
+		"query": accessLogQuery(uris, before),
+		"aggs": map[string]any{
+			"uris": map[string]any{
+				// terms 聚合默认只返回 10 个桶
+				"terms": map[string]any{"field": "info.uri.keyword", "size": len(uris)},
+			},
+		},
+	}
+	_, err := Search(ctx, AccessLogIndex, body, func(body io.ReadCloser) (map[string]any, error) {
+		var r struct {
+			Aggregations struct {
+				URIs struct {
+					Buckets []struct {
+						Key      string `json:"key"`
+						DocCount int64  `json:"doc_count"`
+					} `json:"buckets"`
+				} `json:"uris"`
+			} `json:"aggregations"`
+		}
+		if err := json.NewDecoder(body).Decode(&r); err != nil {
+			return nil, errors.Wrap(err, "解析响应失败")
+		}
+		for _, bucket := range r.Aggregations.URIs.Buckets {
+			pvs[bucket.Key] = bucket.DocCount
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pvs, nil
+}
+
+// AccessLogDistinct 查询 uris 在 before 之前的访问日志中 field 的全部取值,例如 uid.keyword 与 ip.keyword
+// 使用组合聚合分页遍历,结果按 uri 分组
+func AccessLogDistinct(ctx context.Context, uris []string, field, before string) (map[string][]string, error) {
+	values := make(map[string][]string)
+	if len(uris) == 0 {
+		return values, nil
+	}
+	var after map[string]any
+	for {
+		composite := map[string]any{
+			"size": accessLogCompositeSize,
+			"sources": []any{
+				map[string]any{"uri": map[string]any{"terms": map[string]any{"field": "info.uri.keyword"}}},
+				map[string]any{"value": map[string]any{"terms": map[string]any{"field": field}}},
+			},
+		}
+		if after != nil {
+			composite["after"] = after
+		}
+		body := map[string]any{
+			"size":  0,
+			"query": accessLogQuery(uris, before),
+			"aggs":  map[string]any{"pairs": map[string]any{"composite": composite}},
+		}
+		var n int
+		_, err := Search(ctx, AccessLogIndex, body, func(body io.ReadCloser) (map[string]any, error) {
+			var r struct {
+				Aggregations struct {
+					Pairs struct {
+						AfterKey map[string]any `json:"after_key"`
+						Buckets  []struct {
+							Key struct {
+								URI   string `json:"uri"`
+								Value string `json:"value"`
+							} `json:"key"`
+						} `json:"buckets"`
+					} `json:"pairs"`
+				} `json:"aggregations"`
+			}
+			if err := json.NewDecoder(body).Decode(&r); err != nil {
+				return nil, errors.Wrap(err, "解析响应失败")
+			}
+			for _, bucket := range r.Aggregations.Pairs.Buckets {
+				values[bucket.Key.URI] = append(values[bucket.Key.URI], bucket.Key.Value)
+			}
+			n, after = len(r.Aggregations.Pairs.Buckets), r.Aggregations.Pairs.AfterKey
+			return nil, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if n < accessLogCompositeSize || after == nil {
+			return values, nil
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/pkg/errors"
 	"github.com/zhufuyi/sponge/pkg/logger"
+	"io"
 	"sync"
 )
 
@@ -60,3 +61,23 @@ func esInstance() *ES {
 func Search(ctx context.Context, index string, body any, parser ResponseParse) (map[string]any, error) {
 	return esInstance().Search(ctx, index, body, parser)
 }
+
+// Index ES 写入文档 API
+func Index(ctx context.Context, index, id string, doc any) error {
+	return esInstance().Index(ctx, index, id, doc)
+}
+
+// Delete ES 删除文档 API
+func Delete(ctx context.Context, index, id string) error {
+	return esInstance().Delete(ctx, index, id)
+}
+
+// Bulk ES 批量写入 API
+func Bulk(ctx context.Context, index string, body io.Reader) error {
+	return esInstance().Bulk(ctx, index, body)
+}
+
+// CreateIndexIfNotExists ES 创建索引 API
+func CreateIndexIfNotExists(ctx context.Context, index string, mapping any) error {
+	return esInstance().CreateIndexIfNotExists(ctx, index, mapping)
+}
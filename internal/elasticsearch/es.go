@@ -8,6 +8,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/pkg/errors"
 	"io"
+	"net/http"
 )
 
 type ES struct {
@@ -54,6 +55,97 @@ func (es ES) Search(ctx context.Context, index string, body any, parser Response
 	return parser(data.Body)
 }
 
+// Index 写入文档,文档已存在时覆盖
+func (es ES) Index(ctx context.Context, index, id string, doc any) error {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(doc); err != nil {
+		return errors.Wrap(err, "Error encoding document")
+	}
+	data, err := es.client.Index(index, buf,
+		es.client.Index.WithContext(ctx),
+		es.client.Index.WithDocumentID(id),
+	)
+	if err != nil {
+		return errors.Wrap(err, "写入ES文档失败")
+	}
+	defer data.Body.Close()
+	if data.IsError() {
+		return decodeErrorResponse(data.Body, data.Status())
+	}
+	return nil
+}
+
+// Delete 删除文档,文档不存在时视为成功
+func (es ES) Delete(ctx context.Context, index, id string) error {
+	data, err := es.client.Delete(index, id,
+		es.client.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return errors.Wrap(err, "删除ES文档失败")
+	}
+	defer data.Body.Close()
+	if data.IsError() && data.StatusCode != http.StatusNotFound {
+		return decodeErrorResponse(data.Body, data.Status())
+	}
+	return nil
+}
+
+// Bulk 批量写入,body 为 NDJSON 格式的 bulk 请求体
+func (es ES) Bulk(ctx context.Context, index string, body io.Reader) error {
+	data, err := es.client.Bulk(body,
+		es.client.Bulk.WithContext(ctx),
+		es.client.Bulk.WithIndex(index),
+	)
+	if err != nil {
+		return errors.Wrap(err, "批量写入ES失败")
+	}
+	defer data.Body.Close()
+	if data.IsError() {
+		return decodeErrorResponse(data.Body, data.Status())
+	}
+	var r struct {
+		Errors bool `json:"errors"`
+	}
+	if err = json.NewDecoder(data.Body).Decode(&r); err != nil {
+		return errors.Wrap(err, "解析响应失败")
+	}
+	if r.Errors {
+		return errors.New("批量写入ES部分失败")
+	}
+	return nil
+}
+
+// CreateIndexIfNotExists 创建索引,索引已存在时不做任何处理
+func (es ES) CreateIndexIfNotExists(ctx context.Context, index string, mapping any) error {
+	exists, err := es.client.Indices.Exists([]string{index},
+		es.client.Indices.Exists.WithContext(ctx),
+	)
+	if err != nil {
+		return errors.Wrap(err, "查询ES索引失败")
+	}
+	exists.Body.Close()
+	if exists.StatusCode == http.StatusOK {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err = json.NewEncoder(buf).Encode(mapping); err != nil {
+		return errors.Wrap(err, "Error encoding mapping")
+	}
+	data, err := es.client.Indices.Create(index,
+		es.client.Indices.Create.WithContext(ctx),
+		es.client.Indices.Create.WithBody(buf),
+	)
+	if err != nil {
+		return errors.Wrap(err, "创建ES索引失败")
+	}
+	defer data.Body.Close()
+	// 并发创建时,其他实例可能已经创建了索引
+	if data.IsError() && data.StatusCode != http.StatusBadRequest {
+		return decodeErrorResponse(data.Body, data.Status())
+	}
+	return nil
+}
+
 // decodeErrorResponse 解析错误响应
 func decodeErrorResponse(body io.ReadCloser, status string) error {
 	var e map[string]interface{}
@@ -0,0 +1,158 @@
+package elasticsearch
+
+import (
+	"SnapLink/internal/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ShortLinkIndex 短链接全文检索使用的索引
+const ShortLinkIndex = "snaplink-shortlink"
+
+// shortLinkMapping 短链接索引的映射
+// uri, origin_url, description 使用 search_as_you_type 以支持前缀匹配
+var shortLinkMapping = map[string]any{
+	"mappings": map[string]any{
+		"properties": map[string]any{
+			"uri":         map[string]any{"type": "search_as_you_type"},
+			"origin_url":  map[string]any{"type": "search_as_you_type"},
+			"domain":      map[string]any{"type": "text", "fields": map[string]any{"keyword": map[string]any{"type": "keyword"}}},
+			"description": map[string]any{"type": "search_as_you_type"},
+			"tags":        map[string]any{"type": "text", "fields": map[string]any{"keyword": map[string]any{"type": "keyword"}}},
+			"gid":         map[string]any{"type": "keyword"},
+			"owner":       map[string]any{"type": "keyword"},
+			"created_at":  map[string]any{"type": "date", "format": "yyyy-MM-dd HH:mm:ss"},
+		},
+	},
+}
+
+// ShortLinkDocument 短链接文档,以 uri 作为文档 id
+type ShortLinkDocument struct {
+	Uri         string   `json:"uri"`
+	OriginUrl   string   `json:"origin_url"`
+	Domain      string   `json:"domain"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Gid         string   `json:"gid"`
+	Owner       string   `json:"owner"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// NewShortLinkDocument 由短链接构建文档
+func NewShortLinkDocument(sl *model.ShortLink, owner string, tags []string) *ShortLinkDocument {
+	if tags == nil {
+		tags = []string{}
+	}
+	return &ShortLinkDocument{
+		Uri:         sl.Uri,
+		OriginUrl:   sl.OriginUrl,
+		Domain:      sl.Domain,
+		Description: sl.Description,
+		Tags:        tags,
+		Gid:         sl.Gid,
+		Owner:       owner,
+		CreatedAt:   sl.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// CreateShortLinkIndex 创建短链接索引
+func CreateShortLinkIndex(ctx context.Context) error {
+	return CreateIndexIfNotExists(ctx, ShortLinkIndex, shortLinkMapping)
+}
+
+// IndexShortLink 写入短链接文档
+func IndexShortLink(ctx context.Context, doc *ShortLinkDocument) error {
+	return Index(ctx, ShortLinkIndex, doc.Uri, doc)
+}
+
+// DeleteShortLink 删除短链接文档
+func DeleteShortLink(ctx context.Context, uri string) error {
+	return Delete(ctx, ShortLinkIndex, uri)
+}
+
+// BulkIndexShortLink 批量写入短链接文档
+func BulkIndexShortLink(ctx context.Context, docs []*ShortLinkDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, doc := range docs {
+		meta := map[string]any{"index": map[string]any{"_id": doc.Uri}}
+		if err := enc.Encode(meta); err != nil {
+			return errors.Wrap(err, "Error encoding bulk meta")
+		}
+		if err := enc.Encode(doc); err != nil {
+			return errors.Wrap(err, "Error encoding document")
+		}
+	}
+	return Bulk(ctx, ShortLinkIndex, buf)
+}
+
+// SearchShortLink 在指定分组内搜索短链接
+// 同时使用前缀匹配(bool_prefix)与模糊匹配(fuzziness),满足其一即可
+func SearchShortLink(ctx context.Context, q string, gids []string, from, size int) (int64, []*ShortLinkDocument, error) {
+	body := map[string]any{
+		"from":             from,
+		"size":             size,
+		"track_total_hits": true,
+		"query": map[string]any{
+			"bool": map[string]any{
+				"filter": []any{
+					map[string]any{"terms": map[string]any{"gid": gids}},
+				},
+				"should": []any{
+					map[string]any{
+						"multi_match": map[string]any{
+							"query": q,
+							"type":  "bool_prefix",
+							"fields": []string{
+								"uri^3", "uri._2gram", "uri._3gram",
+								"description^2", "description._2gram", "description._3gram",
+								"origin_url", "origin_url._2gram", "origin_url._3gram",
+							},
+						},
+					},
+					map[string]any{
+						"multi_match": map[string]any{
+							"query":     q,
+							"fuzziness": "AUTO",
+							"fields":    []string{"uri^3", "description^2", "origin_url", "domain", "tags^2"},
+						},
+					},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+	}
+	var total int64
+	docs := make([]*ShortLinkDocument, 0, size)
+	_, err := Search(ctx, ShortLinkIndex, body, func(body io.ReadCloser) (map[string]any, error) {
+		var r struct {
+			Hits struct {
+				Total struct {
+					Value int64 `json:"value"`
+				} `json:"total"`
+				Hits []struct {
+					Source *ShortLinkDocument `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := json.NewDecoder(body).Decode(&r); err != nil {
+			return nil, errors.Wrap(err, "解析响应失败")
+		}
+		total = r.Hits.Total.Value
+		for _, hit := range r.Hits.Hits {
+			docs = append(docs, hit.Source)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return total, docs, nil
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+var _ AccessTokenHandler = (*accessTokenHandler)(nil)
+
+// maxAccessTokens 每个用户最多可以创建的个人访问令牌数量
+const maxAccessTokens = 20
+
+// AccessTokenHandler defining the handler interface
+type AccessTokenHandler interface {
+	Create(c *gin.Context)
+	List(c *gin.Context)
+	Revoke(c *gin.Context)
+}
+
+type accessTokenHandler struct {
+	iDao dao.IAccessTokenDao
+}
+
+// NewAccessTokenHandler creating the handler interface
+func NewAccessTokenHandler() AccessTokenHandler {
+	return &accessTokenHandler{
+		iDao: dao.AccessTokenDao(),
+	}
+}
+
+// Create 创建个人访问令牌
+// @Summary 创建个人访问令牌
+// @Description 创建供 API 客户端使用的个人访问令牌,令牌明文只在创建时返回一次;请求头 Authorization: Bearer <token>
+// @Tags accessToken
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.AccessTokenCreateReq true "令牌信息"
+// @Success 200 {object} types.AccessTokenCreateRes{}
+// @Router /api/short-link/admin/v1/user/tokens [post]
+func (h *accessTokenHandler) Create(c *gin.Context) {
+	req := new(types.AccessTokenCreateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]struct{}, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !token.ValidScope(scope) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("不支持的权限范围: "+scope)).ToJSON(c)
+			return
+		}
+		if _, ok := seen[scope]; !ok {
+			seen[scope] = struct{}{}
+			scopes = append(scopes, scope)
+		}
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	tokens, err := h.iDao.ListByUsername(ctx, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if len(tokens) >= maxAccessTokens {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("个人访问令牌最多创建 "+strconv.Itoa(maxAccessTokens)+" 个")).ToJSON(c)
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		at := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &at
+	}
+	plain, t, err := token.NewPAT(claims.UID, req.Name, scopes, expiresAt)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = h.iDao.Create(ctx, t); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := &types.AccessTokenCreateRes{AccessTokenItem: *newAccessTokenItem(t), Token: plain}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// List 列出个人访问令牌
+// @Summary 列出个人访问令牌
+// @Description 列出用户的全部个人访问令牌及最近使用情况,不包含令牌明文
+// @Tags accessToken
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Success 200 {object} []types.AccessTokenItem{}
+// @Router /api/short-link/admin/v1/user/tokens [get]
+func (h *accessTokenHandler) List(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	tokens, err := h.iDao.ListByUsername(middleware.WrapCtx(c), claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := make([]*types.AccessTokenItem, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, newAccessTokenItem(t))
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// Revoke 吊销个人访问令牌
+// @Summary 吊销个人访问令牌
+// @Description 吊销个人访问令牌,吊销后立即失效
+// @Tags accessToken
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param id path int true "令牌id"
+// @Router /api/short-link/admin/v1/user/tokens/{id} [delete]
+func (h *accessTokenHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("令牌id格式错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	if err = h.iDao.Delete(middleware.WrapCtx(c), claims.UID, uint(id)); err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("令牌不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+func newAccessTokenItem(t *model.AccessToken) *types.AccessTokenItem {
+	item := &types.AccessTokenItem{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Split(t.Scopes, ","),
+		CreatedAt:  t.CreatedAt.Format("2006-01-02 15:04:05"),
+		LastUsedIP: t.LastUsedIP,
+	}
+	if t.ExpiresAt != nil {
+		item.ExpiresAt = t.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	if t.LastUsedAt != nil {
+		item.LastUsedAt = t.LastUsedAt.Format("2006-01-02 15:04:05")
+	}
+	return item
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"SnapLink/internal/authz"
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/ecode"
+	"SnapLink/pkg/serialize"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// responseAuthzError 将鉴权失败转换为响应,资源不存在时使用 notFoundMsg 作为提示
+func responseAuthzError(c *gin.Context, err error, notFoundMsg string) {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		serialize.NewResponseWithErrCode(ecode.AccessForbiddenError).ToJSON(c)
+	case errors.Is(err, custom_err.ErrRecordNotFound):
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg(notFoundMsg)).ToJSON(c)
+	default:
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+	}
+}
@@ -27,3 +27,13 @@ func (h *FixHandler) RebulidBF(c *gin.Context) {
 	}
 	serialize.NewResponse(200, serialize.WithMsg("重建布隆过滤器成功")).ToJSON(c)
 }
+
+// RebuildSearchIndex 重建短链接的全文检索索引
+func (h *FixHandler) RebuildSearchIndex(c *gin.Context) {
+	errs := h.iDao.RebuildSearchIndex()
+	if len(errs) > 0 {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithMsg("重建全文检索索引失败"), serialize.WithData(errs)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithMsg("重建全文检索索引成功")).ToJSON(c)
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+const (
+	// maxGroupStatisticDays 分组访问统计单次查询的最大天数
+	maxGroupStatisticDays = 90
+	// maxGroupStatisticTop 排行榜的最大条数
+	maxGroupStatisticTop = 100
+)
+
+// GetGroupStatistic 获取分组访问统计
+// @Summary 获取分组访问统计
+// @Description 获取日期范围内分组的按天与按小时的 PV,UV,UIP,以及访问量最高的短链接、地区与设备
+// @Tags LinkAccessStatistic
+// @Produce json
+// @Param Authorization header string true "token"
+// @Param gid query string true "组id"
+// @Param startDate query string true "开始日期,format:2006-01-02"
+// @Param endDate query string false "结束日期,format:2006-01-02,默认为开始日期"
+// @Param top query int false "排行榜条数,默认为10"
+// @Success 200 {object} types.GroupStatisticResponse{}
+// @Router /stats/group [get]
+func (h *LinkAccessStatisticHandler) GetGroupStatistic(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	gid := c.Query("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("gid不能为空")).ToJSON(c)
+		return
+	}
+	start, end, ok := parseStatisticDateRange(c)
+	if !ok {
+		return
+	}
+	top, err := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if err != nil || top < 1 || top > maxGroupStatisticTop {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("top 参数错误")).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	if _, err = h.iAuthz.AuthorizeGroup(ctx, claims.UID, gid, model.GroupRoleViewer); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+
+	daily, err := h.iGroupStatsDao.ListGroupStats(ctx, gid, start, end, false)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	hourly, err := h.iGroupStatsDao.ListGroupStats(ctx, gid, start, end, true)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	links, err := h.iGroupStatsDao.TopLinks(ctx, gid, start, end, top)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+
+	res := &types.GroupStatisticResponse{
+		Gid:       gid,
+		StartDate: start,
+		EndDate:   end,
+		Daily:     make([]*types.GroupStatisticPeriod, 0, len(daily)),
+		Hourly:    make([]*types.GroupStatisticPeriod, 0, len(hourly)),
+		TopLinks:  make([]*types.GroupStatisticLink, 0, len(links)),
+	}
+	regions, devices := make(map[string]int64), make(map[string]int64)
+	for _, stat := range daily {
+		res.Pv += stat.Pv
+		res.Uv += stat.Uv
+		res.Uip += stat.Uip
+		res.Daily = append(res.Daily, newGroupStatisticPeriod(stat))
+		if err = sumStatisticCounter(regions, stat.Regions); err != nil {
+			logger.Warn("解析分组地区统计失败", logger.Err(err), logger.String("gid", gid), middleware.GCtxRequestIDField(c))
+		}
+		if err = sumStatisticCounter(devices, stat.Devices); err != nil {
+			logger.Warn("解析分组设备统计失败", logger.Err(err), logger.String("gid", gid), middleware.GCtxRequestIDField(c))
+		}
+	}
+	for _, stat := range hourly {
+		res.Hourly = append(res.Hourly, newGroupStatisticPeriod(stat))
+	}
+	for _, stat := range links {
+		res.TopLinks = append(res.TopLinks, &types.GroupStatisticLink{
+			Uri:      stat.URI,
+			ShortUrl: makeFullShortURL(Domain, stat.URI),
+			Pv:       stat.Pv,
+			Uv:       stat.Uv,
+			Uip:      stat.Uip,
+		})
+	}
+	res.TopRegions = topStatisticDimensions(regions, top)
+	res.TopDevices = topStatisticDimensions(devices, top)
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// parseStatisticDateRange 解析查询参数中的日期范围,参数错误时写入响应并返回 false
+func parseStatisticDateRange(c *gin.Context) (string, string, bool) {
+	startDate, err := time.Parse("2006-01-02", c.Query("startDate"))
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("startDate 参数错误")).ToJSON(c)
+		return "", "", false
+	}
+	endDate := startDate
+	if c.Query("endDate") != "" {
+		if endDate, err = time.Parse("2006-01-02", c.Query("endDate")); err != nil {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("endDate 参数错误")).ToJSON(c)
+			return "", "", false
+		}
+	}
+	if endDate.Before(startDate) || endDate.Sub(startDate) >= maxGroupStatisticDays*24*time.Hour {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("日期范围错误,最多查询 "+strconv.Itoa(maxGroupStatisticDays)+" 天")).ToJSON(c)
+		return "", "", false
+	}
+	return startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), true
+}
+
+func newGroupStatisticPeriod(stat *model.LinkAccessStatisticBasic) *types.GroupStatisticPeriod {
+	date := stat.Date
+	// date 列为 date 类型时,部分驱动配置下会返回完整的时间
+	if len(date) > len("2006-01-02") {
+		date = date[:len("2006-01-02")]
+	}
+	return &types.GroupStatisticPeriod{
+		Date: date,
+		Hour: stat.Hour,
+		Pv:   stat.Pv,
+		Uv:   stat.Uv,
+		Uip:  stat.Uip,
+	}
+}
+
+// sumStatisticCounter 将 json 格式的计数累加到 counter 中
+func sumStatisticCounter(counter map[string]int64, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	values := make(map[string]int64)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	for k, v := range values {
+		counter[k] += v
+	}
+	return nil
+}
+
+// topStatisticDimensions 按访问量从高到低取前 n 个
+func topStatisticDimensions(counter map[string]int64, n int) []*types.GroupStatisticDimension {
+	result := make([]*types.GroupStatisticDimension, 0, len(counter))
+	for name, pv := range counter {
+		result = append(result, &types.GroupStatisticDimension{Name: name, Pv: pv})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pv != result[j].Pv {
+			return result[i].Pv > result[j].Pv
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"SnapLink/internal/authz"
+	"SnapLink/internal/dao"
 	"SnapLink/internal/model"
 	"context"
 	"fmt"
@@ -17,7 +19,9 @@ type LinkAccessStatisticDao interface {
 	GetStatisticByDay(ctx context.Context, uri string, startDate, endDate string, order string, pageNum, pageSize uint64) ([]model.LinkAccessStatisticDay, error)
 }
 type LinkAccessStatisticHandler struct {
-	iDao LinkAccessStatisticDao
+	iDao           LinkAccessStatisticDao
+	iGroupStatsDao dao.IGroupStatsDao
+	iAuthz         authz.Authorizer
 }
 
 func NewLinkAccessStatisticHandler() *LinkAccessStatisticHandler {
@@ -25,6 +29,8 @@ func NewLinkAccessStatisticHandler() *LinkAccessStatisticHandler {
 		//todo 实现
 		//iDao: dao.NewLinkAccessStatisticDao(
 		//	cache.NewLinkStatsCache(model.GetCacheType())),
+		iGroupStatsDao: dao.GroupStatsDao(),
+		iAuthz:         authz.NewAuthorizer(dao.ShortLinkDao(), dao.NewShortLinkGroupDao(model.GetDB())),
 	}
 	return h
 }
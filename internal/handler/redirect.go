@@ -7,6 +7,8 @@ import (
 	"SnapLink/pkg/serialize"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"net/url"
+	"time"
 )
 
 type RedirectHandler struct {
@@ -30,8 +32,10 @@ func NewRedirectHandler() (*RedirectHandler, error) {
 // @Accept json
 // @Produce json
 // @Param short_uri path string true "短链接"
-// @Success 302 {string} string "重定向到原始链接"
+// @Success 302 {string} string "重定向到原始链接,状态码由短链接的设置决定"
 // @Failure 400 {string} string "请求失败"
+// @Failure 404 {string} string "短链接不存在或已禁用"
+// @Failure 410 {string} string "短链接已过期"
 // @Router /{uri} [get]
 // 流程图: https://drive.google.com/file/d/1hAHa5ZzhMjueqcIlkjkpvrejxsdo0Qk_/view?usp=sharing
 func (h *RedirectHandler) Redirect(c *gin.Context) {
@@ -51,7 +55,50 @@ func (h *RedirectHandler) Redirect(c *gin.Context) {
 		).ToJSON(c)
 		return
 	}
+	// 禁用与过期的短链接不再跳转,也不记录访问
+	if info.Disabled != 0 {
+		serialize.NewResponse(404, serialize.WithMsg("短链接已禁用")).ToJSON(c)
+		return
+	}
+	if info.Expired(time.Now()) {
+		serialize.NewResponse(410, serialize.WithMsg("短链接已过期")).ToJSON(c)
+		return
+	}
 	c.Set("info", info)
 	// 进行重定向
-	c.Redirect(302, info.OriginalURL)
+	code := info.RedirectCode
+	if code == 0 {
+		code = model.DefaultRedirectCode
+	}
+	c.Redirect(code, buildRedirectURL(info, c.Request.URL.Query()))
+}
+
+// buildRedirectURL 生成跳转的目标链接
+// utm 参数与访问时携带的查询参数均不会覆盖原始链接中已有的参数,override 模式下访问时携带的参数除外
+func buildRedirectURL(info *model.Redirect, query url.Values) string {
+	passthrough := len(query) > 0 && (info.QueryMode == model.QueryModeAppend || info.QueryMode == model.QueryModeOverride)
+	if info.UtmTemplate == "" && !passthrough {
+		return info.OriginalURL
+	}
+	u, err := url.Parse(info.OriginalURL)
+	if err != nil {
+		return info.OriginalURL
+	}
+	values := u.Query()
+	utm, _ := url.ParseQuery(info.UtmTemplate)
+	for k, v := range utm {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	if passthrough {
+		for k, v := range query {
+			if _, ok := values[k]; ok && info.QueryMode != model.QueryModeOverride {
+				continue
+			}
+			values[k] = v
+		}
+	}
+	u.RawQuery = values.Encode()
+	return u.String()
 }
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeRedirectsDao map[string]*model.Redirect
+
+func (f fakeRedirectsDao) GetByURI(_ context.Context, uri string) (*model.Redirect, error) {
+	if r, ok := f[uri]; ok {
+		return r, nil
+	}
+	return nil, custom_err.ErrRecordNotFound
+}
+
+func (f fakeRedirectsDao) CleanUp(context.Context) {}
+
+func TestRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	h := &RedirectHandler{iDao: fakeRedirectsDao{
+		"live":     {Uri: "live", OriginalURL: "https://example.com"},
+		"moved":    {Uri: "moved", OriginalURL: "https://example.com", RedirectCode: 301},
+		"disabled": {Uri: "disabled", OriginalURL: "https://example.com", Disabled: 1},
+		"expired":  {Uri: "expired", OriginalURL: "https://example.com", ExpireAt: &past},
+		"valid":    {Uri: "valid", OriginalURL: "https://example.com", ExpireAt: &future},
+	}}
+	r := gin.New()
+	r.GET("/:uri", h.Redirect)
+
+	tests := []struct {
+		uri  string
+		want int
+	}{
+		{"live", http.StatusFound},
+		{"moved", http.StatusMovedPermanently},
+		{"valid", http.StatusFound},
+		{"disabled", http.StatusNotFound},
+		{"expired", http.StatusGone},
+		{"missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.uri, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET /%s = %d, want %d", tt.uri, w.Code, tt.want)
+		}
+		if tt.want >= 400 && w.Header().Get("Location") != "" {
+			t.Errorf("GET /%s redirected to %s", tt.uri, w.Header().Get("Location"))
+		}
+	}
+}
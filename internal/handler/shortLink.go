@@ -1,22 +1,20 @@
 package handler
 
 import (
+	"SnapLink/internal/authz"
 	"SnapLink/internal/cache"
 	"SnapLink/internal/config"
 	"SnapLink/internal/custom_err"
 	"SnapLink/internal/dao"
 	"SnapLink/internal/ecode"
-	"SnapLink/internal/elasticsearch"
 	"SnapLink/internal/model"
+	"SnapLink/internal/token"
 	"SnapLink/internal/types"
 	"SnapLink/internal/utils/GenerateShortLink"
 	"SnapLink/pkg/serialize"
 	"context"
-	"encoding/json"
-	"fmt"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
-	"io"
 	"net/url"
 	"strconv"
 	"strings"
@@ -38,10 +36,23 @@ type ShortLinkHandler interface {
 	Update(c *gin.Context)
 	List(c *gin.Context)
 	Delete(c *gin.Context)
+	Export(c *gin.Context)
+	Import(c *gin.Context)
+	Search(c *gin.Context)
+	SetTags(c *gin.Context)
+	ListRecycled(c *gin.Context)
+	Restore(c *gin.Context)
+	Purge(c *gin.Context)
+	History(c *gin.Context)
+	Rollback(c *gin.Context)
+	Bulk(c *gin.Context)
 }
 
 type shortLinkHandler struct {
-	iDao dao.IShortLinkDao
+	iDao      dao.IShortLinkDao
+	iGroupDao dao.ShortLinkGroupDao
+	iTagDao   dao.TagDao
+	iAuthz    authz.Authorizer
 }
 
 // NewShortLinkHandler creating the handler interface
@@ -53,6 +64,9 @@ func NewShortLinkHandler() (ShortLinkHandler, error) {
 	if err != nil {
 		return nil, err
 	}
+	h.iGroupDao = dao.NewShortLinkGroupDao(model.GetDB())
+	h.iTagDao = dao.NewTagDao(model.GetDB())
+	h.iAuthz = authz.NewAuthorizer(h.iDao, h.iGroupDao)
 	return h, nil
 }
 
@@ -86,20 +100,31 @@ func (h *shortLinkHandler) Create(c *gin.Context) {
 		serialize.NewResponse(400, serialize.WithMsg("参数错误"), serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	//2. 生成短链接
-	sLink := model.ShortLink{
-		Enable:        1,
-		Domain:        u.Host,
-		OriginUrl:     u.String(),
-		Gid:           form.Gid,
-		Description:   form.Description,
-		CreatedType:   form.CreatedType,
-		ValidDateType: form.ValidDateType,
+	// 只允许在有编辑权限的分组下创建,未指定分组时使用默认分组
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	var group *model.ShortLinkGroup
+	if form.Gid == "" {
+		group, err = h.iGroupDao.GetDefault(ctx, claims.UID)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		form.Gid = group.Gid
+	} else if group, err = h.iAuthz.AuthorizeGroup(ctx, claims.UID, form.Gid, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
 	}
-	if sLink.ValidDateType > 0 {
-		sLink.ValidTime, err = time.Parse("2006-01-02 15:04:05", form.ValidDate)
+	//2. 生成短链接,未指定的设置继承分组的默认设置
+	sLink := model.ShortLink{
+		Enable:      1,
+		Domain:      u.Host,
+		OriginUrl:   u.String(),
+		Gid:         form.Gid,
+		Description: form.Description,
+		CreatedType: createdType(claims, form.CreatedType),
 	}
-	if err != nil {
+	if err = applyCreateSettings(&sLink, form, group.Settings); err != nil {
 		serialize.NewResponse(400, serialize.WithMsg("参数错误"), serialize.WithErr(err)).ToJSON(c)
 		return
 	}
@@ -108,7 +133,6 @@ func (h *shortLinkHandler) Create(c *gin.Context) {
 	sLink.Uri = ToHash(u)
 	//3. 保存到数据库
 	// 对布隆过滤器误判的情况进行判断
-	ctx := middleware.WrapCtx(c)
 	err = h.iDao.Create(ctx, &sLink)
 
 	// 特别对于唯一索引的错误进行处理
@@ -141,6 +165,43 @@ func (h *shortLinkHandler) CreateBatch(c *gin.Context) {
 		return
 	}
 	l := len(forms)
+	// 只允许在有编辑权限的分组下创建,未指定分组时使用默认分组
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	gids := make([]string, 0, l)
+	var defaultGid string
+	for i := 0; i < l; i++ {
+		if forms[i].Gid == "" {
+			if defaultGid == "" {
+				group, err := h.iGroupDao.GetDefault(ctx, claims.UID)
+				if err != nil {
+					serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+					return
+				}
+				defaultGid = group.Gid
+			}
+			forms[i].Gid = defaultGid
+			continue
+		}
+		gids = append(gids, forms[i].Gid)
+	}
+	if err := h.iAuthz.AuthorizeGroups(ctx, claims.UID, gids, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	// 分组信息已缓存,用于继承分组的默认设置
+	groups := make(map[string]*model.ShortLinkGroup)
+	for i := 0; i < l; i++ {
+		if _, ok := groups[forms[i].Gid]; ok {
+			continue
+		}
+		group, err := h.iGroupDao.GetByGid(ctx, forms[i].Gid)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		groups[forms[i].Gid] = group
+	}
 	shortLinks := make([]*model.ShortLink, 0, l)
 	for i := 0; i < l; i++ {
 		u, err := url.Parse(forms[i].OriginUrl)
@@ -151,18 +212,14 @@ func (h *shortLinkHandler) CreateBatch(c *gin.Context) {
 		}
 		//2. 生成短链接
 		sLink := &model.ShortLink{
-			Enable:        1,
-			Domain:        u.Host,
-			OriginUrl:     u.String(),
-			Gid:           forms[i].Gid,
-			Description:   forms[i].Description,
-			CreatedType:   forms[i].CreatedType,
-			ValidDateType: forms[i].ValidDateType,
+			Enable:      1,
+			Domain:      u.Host,
+			OriginUrl:   u.String(),
+			Gid:         forms[i].Gid,
+			Description: forms[i].Description,
+			CreatedType: createdType(claims, forms[i].CreatedType),
 		}
-		if sLink.ValidDateType > 0 {
-			sLink.ValidTime, err = time.Parse("2006-01-02 15:04:05", forms[i].ValidDate)
-		}
-		if err != nil {
+		if err = applyCreateSettings(sLink, forms[i], groups[forms[i].Gid].Settings); err != nil {
 			serialize.NewResponse(400, serialize.WithMsg("参数错误"), serialize.WithErr(err)).ToJSON(c)
 			return
 		}
@@ -171,7 +228,6 @@ func (h *shortLinkHandler) CreateBatch(c *gin.Context) {
 
 		shortLinks = append(shortLinks, sLink)
 	}
-	ctx := middleware.WrapCtx(c)
 
 	// 特别对于唯一索引的错误进行处理
 	if sLink, err := h.iDao.CreateBatch(ctx, shortLinks); err != nil || sLink != nil {
@@ -200,7 +256,8 @@ func (h *shortLinkHandler) CreateBatch(c *gin.Context) {
 // @Param gid query string false "组id"
 // @Param current query int false "当前页"
 // @Param size query int false "每页大小"
-// @Param orderTag query string false "排序"
+// @Param orderTag query string false "排序 createTime, todayPv, todayUv, todayUip, totalPv, totalUv, totalUip"
+// @Param tags query string false "标签id,以逗号分隔,返回同时拥有全部标签的短链接"
 func (h *shortLinkHandler) List(c *gin.Context) {
 
 	gid := c.Query("gid")
@@ -217,10 +274,33 @@ func (h *shortLinkHandler) List(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
+	if orderTag != "" && orderTag != types.OrderTagCreateTime && !cache.IsRankOrderTag(orderTag) {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("不支持的排序方式")).ToJSON(c)
+		return
+	}
+	tagIDs, err := parseTagIDs(c.Query("tags"))
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
 	ctx := middleware.WrapCtx(c)
-	var list []*model.ShortLink
-	var uris []string
-	total, err := h.iDao.Count(ctx, gid)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, gid, model.GroupRoleViewer)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	//查询
+	var (
+		total int64
+		list  []*model.ShortLink
+	)
+	if len(tagIDs) > 0 {
+		total, list, err = h.listByTags(ctx, group.CUsername, gid, tagIDs, orderTag, current, size)
+	} else {
+		total, list, err = h.listByGid(ctx, gid, orderTag, current, size)
+	}
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
@@ -228,135 +308,75 @@ func (h *shortLinkHandler) List(c *gin.Context) {
 
 	//转换
 	res := types.ListShortLinkResponse{
-		Total:   total,
-		Size:    size,
-		Current: current,
+		Total:    total,
+		Size:     size,
+		Current:  current,
+		OrderTag: orderTag,
 	}
-	if orderTag == "" {
-		//查询
-		list, err = h.iDao.List(ctx, gid, current, size)
-		if err != nil {
-			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
-			return
-		}
-		//查询基本统计数据
-		l := len(list)
-		uris = make([]string, 0, l)
-		for i := 0; i < l; i++ {
-			uris = append(uris, list[i].Uri)
-		}
-		// 根据实际查询到的条数,进行数据钻取
-		res.Records = make([]*types.ShortLinkRecord, 0, l)
-		statics, err := searchTodayAccessStatic(ctx, uris)
-		if err != nil {
-			// 如果不是今日无数据，则返回错误
-			if !errors.Is(err, ErrBucketsIsEmpty) {
-				serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
-				return
-			}
-		}
-		// 制造响应数据
-		for i := 0; i < l; i++ {
-			record := &types.ShortLinkRecord{
-				CreatedAt:     list[i].CreatedAt.Format("2006-01-02 15:04:05"),
-				OriginUrl:     list[i].OriginUrl,
-				ShortUrl:      makeFullShortURL(Domain, list[i].Uri),
-				ValidDateType: list[i].ValidDateType,
-				ValidDate:     list[i].ValidTime.Format("2006-01-02 15:04:05"),
-				Describe:      list[i].Description,
-			}
-			// 如果查询不到数据，则返回 0
-			if err == nil {
-				static, ok := statics[list[i].Uri].(map[string]any)
-				if ok {
-					record.TodayPV = int(static["today_pv"].(map[string]any)["value"].(float64))
-					record.TodayUV = int(static["today_uv"].(map[string]any)["value"].(float64))
-					record.TodayUIP = int(static["today_uip"].(map[string]any)["value"].(float64))
-				}
-
-			}
-			res.Records = append(res.Records, record)
-		}
-	} else {
-
+	//查询基本统计数据
+	l := len(list)
+	uris := make([]string, 0, l)
+	for i := 0; i < l; i++ {
+		uris = append(uris, list[i].Uri)
+	}
+	statics, err := cache.ShortLinkStats().Get(ctx, uris)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	linkTags, err := h.iTagDao.GetLinkTags(ctx, group.CUsername, uris)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	// 制造响应数据
+	res.Records = make([]*types.ShortLinkRecord, 0, l)
+	for i := 0; i < l; i++ {
+		static := statics[list[i].Uri]
+		res.Records = append(res.Records, &types.ShortLinkRecord{
+			CreatedAt:     list[i].CreatedAt.Format("2006-01-02 15:04:05"),
+			OriginUrl:     list[i].OriginUrl,
+			ShortUrl:      makeFullShortURL(Domain, list[i].Uri),
+			ValidDateType: list[i].ValidDateType,
+			ValidDate:     list[i].ValidTime.Format("2006-01-02 15:04:05"),
+			Describe:      list[i].Description,
+			RedirectCode:  list[i].RedirectCode,
+			UtmTemplate:   list[i].UtmTemplate,
+			QueryMode:     list[i].QueryMode,
+			TodayPV:       int(static.TodayPV),
+			TotalPV:       int(static.TotalPV),
+			TodayUV:       int(static.TodayUV),
+			TotalUV:       int(static.TotalUV),
+			TodayUIP:      int(static.TodayUIP),
+			TotalUIP:      int(static.TotalUIP),
+			Tags:          toTagItems(linkTags[list[i].Uri]),
+		})
 	}
 
 	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
 }
 
-var (
-	ErrBucketsIsEmpty = errors.New("buckets is empty")
-)
-
-// 去 ES 中查询访问情况
-func searchTodayAccessStatic(ctx context.Context, uris []string) (map[string]any, error) {
-	// 获取 ES 实例
-	body := map[string]any{
-		"size":    0,
-		"_source": false,
-		"query": map[string]any{
-			"terms": map[string]any{
-				"info.uri.keyword": uris,
-			},
-		},
-		"aggs": map[string]any{
-			"statics": map[string]any{
-				"terms": map[string]any{
-					"field": "info.uri.keyword",
-				},
-				"aggs": map[string]any{
-					"today_pv": map[string]any{
-						"value_count": map[string]any{
-							"field": "info.uri.keyword",
-						},
-					},
-					"today_uip": map[string]any{
-						"cardinality": map[string]any{
-							"field": "ip.keyword",
-						},
-					},
-					"today_uv": map[string]any{
-						"cardinality": map[string]any{
-							"field": "uid.keyword",
-						},
-					},
-				},
-			},
-		},
-	}
-	index := fmt.Sprintf("logstash-accesslog-%s.*", time.Now().Format("2006.01.02"))
-	return elasticsearch.Search(ctx, index, body, accessStaticResponseParser)
-}
-
-// accessStaticResponseParser 访问日志查询响应
-func accessStaticResponseParser(body io.ReadCloser) (map[string]any, error) {
-	var r map[string]interface{}
-	if err := json.NewDecoder(body).Decode(&r); err != nil {
-		return nil, errors.Wrap(err, "解析响应失败")
-	}
-
-	aggregations, ok := r["aggregations"].(map[string]any)
-	if !ok {
-		return nil, ErrBucketsIsEmpty
-	}
-
-	buckets := aggregations["statics"].(map[string]any)["buckets"].([]any)
-	if len(buckets) == 0 {
-		return nil, ErrBucketsIsEmpty
+// listByGid 按排序方式分页查询分组内的短链接
+func (h *shortLinkHandler) listByGid(ctx context.Context, gid, orderTag string, page, size int) (int64, []*model.ShortLink, error) {
+	total, err := h.iDao.Count(ctx, gid)
+	if err != nil {
+		return 0, nil, err
 	}
-
-	statics := make(map[string]any, len(buckets))
-	for _, bucket := range buckets {
-		key := bucket.(map[string]any)["key"].(string)
-		statics[key] = bucket
+	var list []*model.ShortLink
+	switch {
+	case orderTag == types.OrderTagCreateTime:
+		list, err = h.iDao.ListNewest(ctx, gid, page, size)
+	case cache.IsRankOrderTag(orderTag):
+		list, err = h.iDao.ListByRank(ctx, gid, orderTag, page, size)
+	default:
+		list, err = h.iDao.List(ctx, gid, page, size)
 	}
-
-	return statics, nil
+	return total, list, err
 }
 
 // Delete 删除短链接
 // @Summary 删除短链接
-// @Description 删除短链接
+// @Description 删除短链接,删除后进入回收站,在保留期内可以恢复
 // @Tags shortLink
 // @Accept application/json
 // @Produce application/json
@@ -368,9 +388,18 @@ func (h *shortLinkHandler) Delete(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("uri不能为空")).ToJSON(c)
 		return
 	}
+	claims, _ := token.FromContext(c)
 	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeShortLink(ctx, claims.UID, uri, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "短链接不存在")
+		return
+	}
 	err := h.iDao.Delete(ctx, uri)
 	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("短链接不存在")).ToJSON(c)
+			return
+		}
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
@@ -387,52 +416,96 @@ func (h *shortLinkHandler) Update(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	validTime, err := time.Parse("2006-01-02 15:04:05", form.ValidDate)
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	// 0. 获取对应短链接的当前状态,用于构建更新后的短链接与修改历史
+	// 当前用户需要拥有短链接所在分组与移动到的分组的编辑权限
+	current, err := h.iAuthz.AuthorizeShortLink(ctx, claims.UID, form.Uri, model.GroupRoleEditor)
 	if err != nil {
-		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+		responseAuthzError(c, err, "短链接不存在")
 		return
 	}
-	ctx := middleware.WrapCtx(c)
-	// 0. 获取对应短链接的基本信息
-	info, err := h.iDao.GeRedirectByURI(ctx, form.Uri)
-	if err != nil {
-		// 尝试从此处进行攻击，对此进行防御
-		if errors.Is(err, custom_err.ErrRecordNotFound) {
-			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+	if form.Gid != current.Gid {
+		if _, err = h.iAuthz.AuthorizeGroup(ctx, claims.UID, form.Gid, model.GroupRoleEditor); err != nil {
+			responseAuthzError(c, err, "分组不存在")
 			return
 		}
-		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
-		return
 	}
 	// 构建更新后的短链接
-	sl := &model.ShortLink{
-		OriginUrl:     form.OriginUrl,
-		Gid:           info.Gid,
-		Uri:           form.Uri,
-		Description:   form.Description,
-		ValidDateType: form.ValidDateType,
-		ValidTime:     validTime,
-	}
-	// 1. 校验短链接 gid 是否变更
-	// 短链接未发生改变
-	if strings.Compare(info.Gid, form.Gid) == 0 {
-		// 更新短链接
-		err = h.iDao.Update(ctx, sl)
-		if err != nil {
-			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
-			return
-		}
-		serialize.NewResponse(200, serialize.WithData(sl)).ToJSON(c)
-		return
+	target := model.NewShortLinkSnapshot(current)
+	target.Gid = form.Gid
+	if form.OriginUrl != "" {
+		target.OriginUrl = form.OriginUrl
+	}
+	target.Description = form.Description
+	target.ValidDateType = form.ValidDateType
+	target.ValidDate = form.ValidDate
+	if form.Enable != nil {
+		target.Enable = *form.Enable
 	}
-	// 短链接发生改变
-	err = h.iDao.UpdateWithMove(ctx, sl, form.Gid)
+	sl, err := h.applySnapshot(ctx, current, target, claims.UID, model.HistoryActionUpdate)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
 	serialize.NewResponse(200, serialize.WithData(sl)).ToJSON(c)
-	return
+}
+
+// applySnapshot 将短链接更新为快照中的状态,并记录修改历史
+// 1. 校验短链接 gid 是否变更,变更时需要移动到新的分组
+func (h *shortLinkHandler) applySnapshot(ctx context.Context, current *model.ShortLink, target model.ShortLinkSnapshot, actor, action string) (*model.ShortLink, error) {
+	sl := *current
+	sl.OriginUrl = target.OriginUrl
+	sl.Description = target.Description
+	sl.ValidDateType = target.ValidDateType
+	sl.Enable = target.Enable
+	sl.RedirectCode, sl.UtmTemplate, sl.QueryMode = normalizeRedirectSettings(target.RedirectCode, target.UtmTemplate, target.QueryMode)
+	sl.ValidTime = time.Time{}
+	if target.ValidDateType > 0 {
+		validTime, err := time.Parse("2006-01-02 15:04:05", target.ValidDate)
+		if err != nil {
+			return nil, err
+		}
+		sl.ValidTime = validTime
+	}
+	if current.OriginUrl != target.OriginUrl {
+		u, err := url.Parse(target.OriginUrl)
+		if err != nil {
+			return nil, errors.Wrap(err, "url格式错误")
+		}
+		sl.OriginUrl = u.String()
+		sl.Domain = u.Host
+	}
+	before := model.NewShortLinkSnapshot(current)
+	after := model.NewShortLinkSnapshot(&sl)
+	after.Gid = target.Gid
+	// 没有任何修改时不记录历史
+	if before == after {
+		return &sl, nil
+	}
+	history := &model.ShortLinkHistory{
+		Uri:    current.Uri,
+		Actor:  actor,
+		Action: action,
+		Before: before,
+		After:  after,
+	}
+	var err error
+	if strings.Compare(current.Gid, target.Gid) == 0 {
+		// 短链接未发生改变
+		err = h.iDao.Update(ctx, &sl, history)
+	} else {
+		// 短链接发生改变
+		err = h.iDao.UpdateWithMove(ctx, &sl, target.Gid, history)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// redirect 未变化时不会产生 binlog,此处主动清除跳转缓存
+	if err = cache.Redirect().Del(ctx, sl.Uri); err != nil {
+		logger.Warn("清除跳转缓存失败", logger.Err(err), logger.String("uri", sl.Uri))
+	}
+	return &sl, nil
 }
 
 // makeFullShortURL 生成完整的短链接
@@ -469,3 +542,11 @@ func ToHash(u *url.URL) string {
 	}
 	return uri
 }
+
+// createdType 通过个人访问令牌创建的短链接总是记为 api 创建
+func createdType(claims *token.Claims, requested int) int {
+	if claims.Type == token.TypePAT {
+		return model.CreatedTypeAPI
+	}
+	return requested
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+const (
+	// maxBulkItems 单次批量操作的短链接数量上限
+	maxBulkItems = 1000
+	// bulkScanBatchSize 按筛选条件查询时每批次读取的条数
+	bulkScanBatchSize = 500
+)
+
+// errTooManyBulkItems 匹配的短链接数量超过上限
+var errTooManyBulkItems = errors.Errorf("单次最多操作 %d 条短链接", maxBulkItems)
+
+// Bulk 批量操作短链接
+// @Summary 批量操作短链接
+// @Description 对指定的短链接或满足筛选条件的短链接批量启用、停用、设置有效期、移动分组或删除,返回每个短链接的结果
+// @Tags shortLink
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.BulkShortLinkRequest true "批量操作"
+// @Success 200 {object} types.BulkShortLinkResponse{}
+// @Router /api/short-link/admin/v1/shortlink/bulk [post]
+func (h *shortLinkHandler) Bulk(c *gin.Context) {
+	req := new(types.BulkShortLinkRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if (len(req.Uris) == 0) == (req.Filter == nil) {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("uris 与 filter 需要且只能指定一个")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	// 校验操作参数
+	var validTime time.Time
+	switch req.Action {
+	case types.BulkActionExpire:
+		if req.ValidDateType > 0 {
+			t, err := time.Parse("2006-01-02 15:04:05", req.ValidDate)
+			if err != nil {
+				serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("有效期格式错误")).ToJSON(c)
+				return
+			}
+			validTime = t
+		}
+	case types.BulkActionMove:
+		if req.TargetGid == "" {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("targetGid不能为空")).ToJSON(c)
+			return
+		}
+		if _, err := h.iAuthz.AuthorizeGroup(ctx, username, req.TargetGid, model.GroupRoleEditor); err != nil {
+			responseAuthzError(c, err, "分组不存在")
+			return
+		}
+	}
+
+	// 解析需要操作的短链接
+	res := &types.BulkShortLinkResponse{Results: make([]*types.BulkItemResult, 0)}
+	var (
+		links []*model.ShortLink
+		err   error
+	)
+	if req.Filter != nil {
+		links, err = h.bulkFilter(ctx, c, username, req.Filter)
+		if err != nil {
+			return
+		}
+	} else {
+		links, res.Results, err = h.bulkResolve(ctx, username, req.Uris)
+		if err != nil {
+			if errors.Is(err, errTooManyBulkItems) {
+				serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+				return
+			}
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+	}
+
+	// 执行操作
+	var failed map[string]error
+	if req.Action == types.BulkActionDelete {
+		failed = h.iDao.BulkDelete(ctx, links, "")
+	} else {
+		changes := make([]*dao.ShortLinkChange, 0, len(links))
+		for _, sl := range links {
+			after := *sl
+			switch req.Action {
+			case types.BulkActionEnable:
+				after.Enable = 1
+			case types.BulkActionDisable:
+				after.Enable = 0
+			case types.BulkActionExpire:
+				after.ValidDateType = req.ValidDateType
+				after.ValidTime = validTime
+			case types.BulkActionMove:
+				after.Gid = req.TargetGid
+			}
+			// 没有任何修改的短链接直接视为成功
+			if model.NewShortLinkSnapshot(sl) == model.NewShortLinkSnapshot(&after) {
+				continue
+			}
+			changes = append(changes, &dao.ShortLinkChange{Before: sl, After: &after})
+		}
+		failed = h.iDao.BulkUpdate(ctx, changes, username, "")
+	}
+
+	// 批量清除跳转缓存
+	succeeded := make([]string, 0, len(links))
+	for _, sl := range links {
+		item := &types.BulkItemResult{Uri: sl.Uri, Success: true}
+		if err, ok := failed[sl.Uri]; ok {
+			logger.Error("批量操作短链接失败", logger.Err(err), logger.String("uri", sl.Uri), middleware.GCtxRequestIDField(c))
+			item.Success = false
+			item.Reason = "操作失败"
+		} else {
+			succeeded = append(succeeded, sl.Uri)
+		}
+		res.Results = append(res.Results, item)
+	}
+	if err = cache.Redirect().MDel(ctx, succeeded...); err != nil {
+		logger.Warn("批量清除跳转缓存失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
+	res.Total = len(res.Results)
+	for _, item := range res.Results {
+		if item.Success {
+			res.Succeeded++
+		} else {
+			res.Failed++
+		}
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// bulkResolve 查询 uris 对应的短链接,不存在或无权操作的短链接作为失败结果返回
+func (h *shortLinkHandler) bulkResolve(ctx context.Context, username string, uris []string) ([]*model.ShortLink, []*types.BulkItemResult, error) {
+	seen := make(map[string]struct{}, len(uris))
+	unique := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if _, ok := seen[uri]; ok {
+			continue
+		}
+		seen[uri] = struct{}{}
+		unique = append(unique, uri)
+	}
+	if len(unique) > maxBulkItems {
+		return nil, nil, errTooManyBulkItems
+	}
+	found, err := h.iDao.GetByURIs(ctx, unique)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 同一分组只校验一次
+	authorized := make(map[string]error)
+	links := make([]*model.ShortLink, 0, len(found))
+	results := make([]*types.BulkItemResult, 0)
+	for _, uri := range unique {
+		sl, ok := found[uri]
+		if !ok {
+			results = append(results, &types.BulkItemResult{Uri: uri, Reason: "短链接不存在"})
+			continue
+		}
+		authErr, checked := authorized[sl.Gid]
+		if !checked {
+			_, authErr = h.iAuthz.AuthorizeGroup(ctx, username, sl.Gid, model.GroupRoleEditor)
+			authorized[sl.Gid] = authErr
+		}
+		if authErr != nil {
+			results = append(results, &types.BulkItemResult{Uri: uri, Reason: "无权操作该短链接"})
+			continue
+		}
+		links = append(links, sl)
+	}
+	return links, results, nil
+}
+
+// bulkFilter 查询满足筛选条件的短链接,失败时写入错误响应
+func (h *shortLinkHandler) bulkFilter(ctx context.Context, c *gin.Context, username string, filter *types.BulkShortLinkFilter) ([]*model.ShortLink, error) {
+	var from, to time.Time
+	var err error
+	if filter.CreatedFrom != "" {
+		if from, err = time.Parse("2006-01-02 15:04:05", filter.CreatedFrom); err != nil {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("createdFrom 格式错误")).ToJSON(c)
+			return nil, err
+		}
+	}
+	if filter.CreatedTo != "" {
+		if to, err = time.Parse("2006-01-02 15:04:05", filter.CreatedTo); err != nil {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("createdTo 格式错误")).ToJSON(c)
+			return nil, err
+		}
+	}
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, filter.Gid, model.GroupRoleEditor)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return nil, err
+	}
+	match := func(sl *model.ShortLink) bool {
+		if !from.IsZero() && sl.CreatedAt.Before(from) {
+			return false
+		}
+		if !to.IsZero() && !sl.CreatedAt.Before(to) {
+			return false
+		}
+		return true
+	}
+
+	links := make([]*model.ShortLink, 0)
+	if tagIDs := uniqueTagIDs(filter.TagIDs); len(tagIDs) > 0 {
+		uris, err := h.iTagDao.URIsByTags(ctx, group.CUsername, tagIDs)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return nil, err
+		}
+		list, err := h.iDao.ListByURIs(ctx, filter.Gid, uris)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return nil, err
+		}
+		for _, sl := range list {
+			if match(sl) {
+				links = append(links, sl)
+			}
+		}
+	} else {
+		// 基于游标遍历分组,超过上限后不再继续读取
+		var cursor uint
+		for len(links) <= maxBulkItems {
+			list, err := h.iDao.Scan(ctx, filter.Gid, cursor, bulkScanBatchSize)
+			if err != nil {
+				serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+				return nil, err
+			}
+			for _, sl := range list {
+				if match(sl) {
+					links = append(links, sl)
+				}
+			}
+			if len(list) < bulkScanBatchSize {
+				break
+			}
+			cursor = list[len(list)-1].ID
+		}
+	}
+	if len(links) > maxBulkItems {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(errTooManyBulkItems)).ToJSON(c)
+		return nil, errTooManyBulkItems
+	}
+	return links, nil
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/pkg/export"
+	"SnapLink/pkg/serialize"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+// exportBatchSize 导出时每批次从数据库中读取的条数,每写完一批即刷新一次输出
+const exportBatchSize = 200
+
+var exportBaseColumns = []string{"gid", "uri", "shortUrl", "originUrl", "describe", "enable", "validDateType", "validDate", "createTime"}
+
+// Export 导出短链接
+// @Summary 导出短链接
+// @Description 流式导出分组或账户下的全部短链接,可附带今日/累计的访问统计
+// @Tags shortLink
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param Authorization header string true "token"
+// @Param gid query string false "组id,为空时导出账户下全部分组"
+// @Param format query string false "导出格式 csv(默认) 或 ndjson"
+// @Param stats query string false "附带的统计列,可选 today,total,以逗号分隔"
+// @Router /api/short-link/admin/v1/shortlink/export [get]
+func (h *shortLinkHandler) Export(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	gid := c.Query("gid")
+	withToday, withTotal := false, false
+	for _, s := range strings.Split(c.Query("stats"), ",") {
+		switch strings.TrimSpace(s) {
+		case "":
+		case "today":
+			withToday = true
+		case "total":
+			withTotal = true
+		default:
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("不支持的统计列: "+s)).ToJSON(c)
+			return
+		}
+	}
+	w, err := export.NewWriter(c.DefaultQuery("format", export.FormatCSV), c.Writer)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	// 只允许导出可以访问的分组
+	groups, _, err := accessibleGroups(ctx, h.iGroupDao, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	gids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if gid == "" || group.Gid == gid {
+			gids = append(gids, group.Gid)
+		}
+	}
+	if gid != "" && len(gids) == 0 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("分组不存在")).ToJSON(c)
+		return
+	}
+
+	columns := append([]string{}, exportBaseColumns...)
+	if withToday {
+		columns = append(columns, "todayPV", "todayUV", "todayUIP")
+	}
+	if withTotal {
+		columns = append(columns, "totalPV", "totalUV", "totalUIP")
+	}
+	// 响应头写出之后便无法再返回错误响应,之后的错误只记录日志并中断输出
+	filename := fmt.Sprintf("shortlink-%s.%s", time.Now().Format("20060102150405"), w.Extension())
+	c.Header("Content-Type", w.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(200)
+	if err = w.WriteHeader(columns); err != nil {
+		logger.Error("导出短链接失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+		return
+	}
+	for _, g := range gids {
+		if err = h.exportGroup(ctx, c, w, g, withToday, withTotal); err != nil {
+			logger.Error("导出短链接失败", logger.Err(err), logger.String("gid", g), middleware.GCtxRequestIDField(c))
+			return
+		}
+	}
+}
+
+// exportGroup 基于游标分批导出一个分组的短链接
+func (h *shortLinkHandler) exportGroup(ctx context.Context, c *gin.Context, w export.Writer, gid string, withToday, withTotal bool) error {
+	var cursor uint
+	for {
+		list, err := h.iDao.Scan(ctx, gid, cursor, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		l := len(list)
+		if l == 0 {
+			return nil
+		}
+		cursor = list[l-1].ID
+		uris := make([]string, 0, l)
+		for i := 0; i < l; i++ {
+			uris = append(uris, list[i].Uri)
+		}
+		var statics map[string]*cache.LinkStats
+		if withToday || withTotal {
+			if statics, err = cache.ShortLinkStats().Get(ctx, uris); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < l; i++ {
+			values := exportValues(list[i])
+			if static := statics[list[i].Uri]; static != nil {
+				if withToday {
+					values = append(values, static.TodayPV, static.TodayUV, static.TodayUIP)
+				}
+				if withTotal {
+					values = append(values, static.TotalPV, static.TotalUV, static.TotalUIP)
+				}
+			}
+			if err = w.Write(values); err != nil {
+				return err
+			}
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		if l < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// exportValues 短链接的基础导出列,与 exportBaseColumns 一一对应
+func exportValues(sl *model.ShortLink) []any {
+	validDate := ""
+	if sl.ValidDateType > 0 {
+		validDate = sl.ValidTime.Format("2006-01-02 15:04:05")
+	}
+	return []any{
+		sl.Gid,
+		sl.Uri,
+		makeFullShortURL(Domain, sl.Uri),
+		sl.OriginUrl,
+		sl.Description,
+		sl.Enable,
+		sl.ValidDateType,
+		validDate,
+		sl.CreatedAt,
+	}
+}
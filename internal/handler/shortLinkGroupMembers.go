@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// InviteMember 邀请用户加入分组
+// @Summary 邀请用户加入分组
+// @Description 分组创建人邀请其他用户以 editor 或 viewer 角色加入分组,对方接受后生效
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.GroupMemberReq true "分组与成员"
+func (h *shortLinkGroupsHandler) InviteMember(c *gin.Context) {
+	req := new(types.GroupMemberReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, req.Gid, model.GroupRoleOwner)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	if req.Username == group.CUsername {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("不能邀请分组创建人")).ToJSON(c)
+		return
+	}
+	has, err := h.iUserDao.HasUsername(ctx, req.Username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !has {
+		serialize.NewResponseWithErrCode(ecode.UserNotExistError).ToJSON(c)
+		return
+	}
+	member := &model.GroupMember{
+		Gid:      req.Gid,
+		Username: req.Username,
+		Role:     req.Role,
+		Status:   model.GroupMemberPending,
+		Inviter:  username,
+	}
+	if err = h.iDao.CreateMember(ctx, member); err != nil {
+		if dao.ErrDuplicateEntry.Is(err) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("该用户已是分组成员或已被邀请")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(member)).ToJSON(c)
+}
+
+// ListMembers 查询分组成员
+// @Summary 查询分组成员
+// @Description 查询分组的创建人与全部成员,包括尚未接受邀请的成员
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "gid"
+func (h *shortLinkGroupsHandler) ListMembers(c *gin.Context) {
+	gid := c.Query("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(errors.New("gid is empty"))).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, claims.UID, gid, model.GroupRoleViewer)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	members, err := h.iDao.ListMembers(ctx, gid)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := make([]*model.GroupMember, 0, len(members)+1)
+	res = append(res, &model.GroupMember{
+		CreatedAt: group.CreatedAt,
+		Gid:       gid,
+		Username:  group.CUsername,
+		Role:      model.GroupRoleOwner,
+		Status:    model.GroupMemberAccepted,
+	})
+	res = append(res, members...)
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// UpdateMember 修改成员角色
+// @Summary 修改成员角色
+// @Description 分组创建人修改成员的角色
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.GroupMemberReq true "分组与成员"
+func (h *shortLinkGroupsHandler) UpdateMember(c *gin.Context) {
+	req := new(types.GroupMemberReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeGroup(ctx, claims.UID, req.Gid, model.GroupRoleOwner); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	member, err := h.iDao.GetMember(ctx, req.Gid, req.Username)
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("成员不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	member.Role = req.Role
+	if err = h.iDao.UpdateMember(ctx, member); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(member)).ToJSON(c)
+}
+
+// RemoveMember 移除分组成员
+// @Summary 移除分组成员
+// @Description 分组创建人移除成员或撤回邀请,username 为自己时表示退出分组
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "gid"
+// @Param username query string true "成员用户名"
+func (h *shortLinkGroupsHandler) RemoveMember(c *gin.Context) {
+	gid, member := c.Query("gid"), c.Query("username")
+	if gid == "" || member == "" {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(errors.New("gid or username is empty"))).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	// 成员可以直接退出,移除其他成员需要是分组创建人
+	if member != username {
+		if _, err := h.iAuthz.AuthorizeGroup(ctx, username, gid, model.GroupRoleOwner); err != nil {
+			responseAuthzError(c, err, "分组不存在")
+			return
+		}
+	}
+	if err := h.iDao.DeleteMember(ctx, gid, member); err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("成员不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// ListInvitations 查询待接受的邀请
+// @Summary 查询待接受的邀请
+// @Description 查询当前用户收到的、尚未接受的分组邀请
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Success 200 {object} []types.GroupInvitationItem{}
+func (h *shortLinkGroupsHandler) ListInvitations(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	members, err := h.iDao.ListMemberships(ctx, username, model.GroupMemberPending)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := make([]*types.GroupInvitationItem, 0, len(members))
+	for _, member := range members {
+		group, err := h.iDao.GetByGid(ctx, member.Gid)
+		if err != nil {
+			// 分组已被删除的邀请不再展示
+			if errors.Is(err, custom_err.ErrRecordNotFound) {
+				continue
+			}
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		res = append(res, &types.GroupInvitationItem{
+			Gid:       group.Gid,
+			Name:      group.Name,
+			Owner:     group.CUsername,
+			Role:      member.Role,
+			Inviter:   member.Inviter,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// AcceptInvitation 接受邀请
+// @Summary 接受邀请
+// @Description 接受分组邀请,接受后分组出现在分组列表中
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.GroupInvitationReq true "分组"
+func (h *shortLinkGroupsHandler) AcceptInvitation(c *gin.Context) {
+	req := new(types.GroupInvitationReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	member, err := h.iDao.GetMember(ctx, req.Gid, claims.UID)
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("邀请不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if member.Status == model.GroupMemberAccepted {
+		serialize.NewResponse(200).ToJSON(c)
+		return
+	}
+	member.Status = model.GroupMemberAccepted
+	if err = h.iDao.UpdateMember(ctx, member); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// DeclineInvitation 拒绝邀请
+// @Summary 拒绝邀请
+// @Description 拒绝分组邀请
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.GroupInvitationReq true "分组"
+func (h *shortLinkGroupsHandler) DeclineInvitation(c *gin.Context) {
+	req := new(types.GroupInvitationReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	member, err := h.iDao.GetMember(ctx, req.Gid, claims.UID)
+	if err != nil || member.Status != model.GroupMemberPending {
+		if err == nil || errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("邀请不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = h.iDao.DeleteMember(ctx, req.Gid, claims.UID); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// accessibleGroups 查询用户创建的分组与已加入的共享分组,并返回用户在每个分组中的角色
+func accessibleGroups(ctx context.Context, groupDao dao.ShortLinkGroupDao, username string) ([]*model.ShortLinkGroup, map[string]string, error) {
+	owned, err := groupDao.GetAllByCUser(ctx, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := make([]*model.ShortLinkGroup, 0, len(owned))
+	roles := make(map[string]string, len(owned))
+	for _, group := range owned {
+		groups = append(groups, group)
+		roles[group.Gid] = model.GroupRoleOwner
+	}
+	members, err := groupDao.ListMemberships(ctx, username, model.GroupMemberAccepted)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, member := range members {
+		group, err := groupDao.GetByGid(ctx, member.Gid)
+		if err != nil {
+			// 共享分组已被删除
+			if errors.Is(err, custom_err.ErrRecordNotFound) {
+				continue
+			}
+			return nil, nil, err
+		}
+		if _, ok := roles[group.Gid]; ok {
+			continue
+		}
+		groups = append(groups, group)
+		roles[group.Gid] = member.Role
+	}
+	return groups, roles, nil
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+// settingsApplyBatchSize 将分组设置应用到已有短链接时每批处理的数量
+const settingsApplyBatchSize = 200
+
+// GetSettings 查询分组的默认设置
+// @Summary 查询分组的默认设置
+// @Description 查询分组的默认设置,新建短链接时未指定的字段继承分组的设置
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "gid"
+// @Success 200 {object} model.GroupSettings{}
+func (h *shortLinkGroupsHandler) GetSettings(c *gin.Context) {
+	gid := c.Query("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(errors.New("gid is empty"))).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	group, err := h.iAuthz.AuthorizeGroup(middleware.WrapCtx(c), claims.UID, gid, model.GroupRoleViewer)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(group.Settings)).ToJSON(c)
+}
+
+// UpdateSettings 修改分组的默认设置
+// @Summary 修改分组的默认设置
+// @Description 修改分组的有效期、跳转状态码、utm 模板与查询参数处理方式,applyToExisting 为 true 时同时应用到分组内已有的短链接
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.GroupSettingsReq true "分组设置"
+// @Success 200 {object} types.GroupSettingsRes{}
+func (h *shortLinkGroupsHandler) UpdateSettings(c *gin.Context) {
+	req := new(types.GroupSettingsReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if _, err := url.ParseQuery(req.UtmTemplate); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("utm 模板格式错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, req.Gid, model.GroupRoleOwner)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	settings := model.GroupSettings{
+		ExpireDays:   req.ExpireDays,
+		RedirectCode: req.RedirectCode,
+		UtmTemplate:  req.UtmTemplate,
+		QueryMode:    req.QueryMode,
+	}
+	if err = h.iDao.UpdateSettings(ctx, group, settings); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := &types.GroupSettingsRes{Settings: settings}
+	if req.ApplyToExisting {
+		res.Applied, res.Failed, err = applySettingsToLinks(ctx, req.Gid, settings, username)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// applySettingsToLinks 基于游标将分组设置应用到分组内已有的短链接,有效期从短链接的创建时间开始计算
+func applySettingsToLinks(ctx context.Context, gid string, settings model.GroupSettings, actor string) (applied, failed int, err error) {
+	linkDao := dao.ShortLinkDao()
+	var cursor uint
+	for {
+		links, err := linkDao.Scan(ctx, gid, cursor, settingsApplyBatchSize)
+		if err != nil {
+			return applied, failed, err
+		}
+		if len(links) == 0 {
+			return applied, failed, nil
+		}
+		changes := make([]*dao.ShortLinkChange, 0, len(links))
+		for _, sl := range links {
+			after := *sl
+			after.RedirectCode, after.UtmTemplate, after.QueryMode = normalizeRedirectSettings(settings.RedirectCode, settings.UtmTemplate, settings.QueryMode)
+			after.ValidDateType, after.ValidTime = 0, time.Time{}
+			if settings.ExpireDays > 0 {
+				after.ValidDateType = 1
+				after.ValidTime = sl.CreatedAt.AddDate(0, 0, settings.ExpireDays)
+			}
+			if model.NewShortLinkSnapshot(sl) == model.NewShortLinkSnapshot(&after) {
+				continue
+			}
+			changes = append(changes, &dao.ShortLinkChange{Before: sl, After: &after})
+		}
+		errs := linkDao.BulkUpdate(ctx, changes, actor, "")
+		uris := make([]string, 0, len(changes))
+		for _, change := range changes {
+			if err, ok := errs[change.Before.Uri]; ok {
+				logger.Warn("应用分组设置失败", logger.Err(err), logger.String("uri", change.Before.Uri))
+				failed++
+				continue
+			}
+			uris = append(uris, change.Before.Uri)
+			applied++
+		}
+		if err = cache.Redirect().MDel(ctx, uris...); err != nil {
+			logger.Warn("批量清除跳转缓存失败", logger.Err(err), logger.String("gid", gid))
+		}
+		cursor = links[len(links)-1].ID
+	}
+}
+
+// applyCreateSettings 合并创建请求与分组的默认设置,请求中指定的值优先
+func applyCreateSettings(sl *model.ShortLink, form *types.CreateShortLinkRequest, settings model.GroupSettings) error {
+	switch {
+	case form.ValidDateType != nil:
+		sl.ValidDateType = *form.ValidDateType
+		if sl.ValidDateType > 0 {
+			validTime, err := time.Parse("2006-01-02 15:04:05", form.ValidDate)
+			if err != nil {
+				return err
+			}
+			sl.ValidTime = validTime
+		}
+	case settings.ExpireDays > 0:
+		sl.ValidDateType = 1
+		sl.ValidTime = time.Now().AddDate(0, 0, settings.ExpireDays)
+	}
+	code, utm, mode := form.RedirectCode, form.UtmTemplate, form.QueryMode
+	if code == 0 {
+		code = settings.RedirectCode
+	}
+	if utm == "" {
+		utm = settings.UtmTemplate
+	}
+	if mode == "" {
+		mode = settings.QueryMode
+	}
+	if _, err := url.ParseQuery(utm); err != nil {
+		return errors.Wrap(err, "utm 模板格式错误")
+	}
+	sl.RedirectCode, sl.UtmTemplate, sl.QueryMode = normalizeRedirectSettings(code, utm, mode)
+	return nil
+}
+
+// normalizeRedirectSettings 将未设置的跳转状态码与查询参数处理方式替换为默认值
+func normalizeRedirectSettings(code int, utm, mode string) (int, string, string) {
+	if code == 0 {
+		code = model.DefaultRedirectCode
+	}
+	if mode == "" {
+		mode = model.QueryModeDrop
+	}
+	return code, utm, mode
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// groupForest 用户可访问的分组之间的父子关系
+// 父分组不可访问(如共享分组的父分组属于其他用户)时,分组作为顶层分组
+type groupForest struct {
+	groups   map[string]*model.ShortLinkGroup
+	children map[string][]*model.ShortLinkGroup
+	roots    []*model.ShortLinkGroup
+}
+
+func newGroupForest(groups []*model.ShortLinkGroup) *groupForest {
+	f := &groupForest{
+		groups:   make(map[string]*model.ShortLinkGroup, len(groups)),
+		children: make(map[string][]*model.ShortLinkGroup),
+	}
+	for _, group := range groups {
+		f.groups[group.Gid] = group
+	}
+	for _, group := range groups {
+		if _, ok := f.groups[group.ParentGid]; ok && group.ParentGid != group.Gid {
+			f.children[group.ParentGid] = append(f.children[group.ParentGid], group)
+			continue
+		}
+		f.roots = append(f.roots, group)
+	}
+	return f
+}
+
+// depth 分组所在的层数,顶层分组为 1
+func (f *groupForest) depth(gid string) int {
+	depth := 1
+	// 嵌套层数由写入时保证,此处限制循环次数以防御异常数据
+	for parent, ok := f.parent(gid); ok && depth <= model.MaxGroupDepth*2; parent, ok = f.parent(parent.Gid) {
+		depth++
+	}
+	return depth
+}
+
+// parent 分组的父分组,父分组不可访问时返回 false
+func (f *groupForest) parent(gid string) (*model.ShortLinkGroup, bool) {
+	group, ok := f.groups[gid]
+	if !ok || group.ParentGid == gid {
+		return nil, false
+	}
+	parent, ok := f.groups[group.ParentGid]
+	return parent, ok
+}
+
+// height 以分组为根的子树的层数,没有子分组时为 1
+func (f *groupForest) height(gid string) int {
+	height := 0
+	for _, child := range f.children[gid] {
+		if h := f.height(child.Gid); h > height {
+			height = h
+		}
+	}
+	return height + 1
+}
+
+// subtree 以分组为根的子树中的全部分组,包括分组自身
+func (f *groupForest) subtree(gid string) []*model.ShortLinkGroup {
+	group, ok := f.groups[gid]
+	if !ok {
+		return nil
+	}
+	result := []*model.ShortLinkGroup{group}
+	for _, child := range f.children[gid] {
+		result = append(result, f.subtree(child.Gid)...)
+	}
+	return result
+}
+
+// contains 分组 gid 是否在以 ancestor 为根的子树中
+func (f *groupForest) contains(ancestor, gid string) bool {
+	for i := 0; i <= model.MaxGroupDepth*2; i++ {
+		if gid == ancestor {
+			return true
+		}
+		parent, ok := f.parent(gid)
+		if !ok {
+			return false
+		}
+		gid = parent.Gid
+	}
+	return false
+}
+
+// MoveGroup 移动分组
+// @Summary 移动分组
+// @Description 将分组及其全部子分组移动到另一个分组下,parentGid 为空时移动到顶层;只能移动到自己创建的分组下,嵌套不能超过 model.MaxGroupDepth 层
+// @Tags shortLinkGroup
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.ShortLinkGroupMoveReq true "分组"
+// @Router /api/short-link/admin/v1/group/parent [put]
+func (h *shortLinkGroupsHandler) MoveGroup(c *gin.Context) {
+	req := new(types.ShortLinkGroupMoveReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, req.Gid, model.GroupRoleOwner)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	if group.ParentGid == req.ParentGid {
+		serialize.NewResponse(200).ToJSON(c)
+		return
+	}
+	owned, err := h.iDao.GetAllByCUser(ctx, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	forest := newGroupForest(owned)
+	if msg := checkGroupParent(forest, req.ParentGid, req.Gid); msg != "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg(msg)).ToJSON(c)
+		return
+	}
+	if err = h.iDao.UpdateParent(ctx, group, req.ParentGid); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// checkGroupParent 校验能否将分组 gid 放到 parentGid 下,gid 为空时为新建分组,校验失败时返回原因
+// 父分组必须是用户自己创建的分组,即在 forest 中
+func checkGroupParent(forest *groupForest, parentGid, gid string) string {
+	if parentGid == "" {
+		return ""
+	}
+	if _, ok := forest.groups[parentGid]; !ok {
+		return "父分组不存在"
+	}
+	if gid != "" && forest.contains(gid, parentGid) {
+		return "不能移动到自身或子分组下"
+	}
+	height := 1
+	if gid != "" {
+		height = forest.height(gid)
+	}
+	if forest.depth(parentGid)+height > model.MaxGroupDepth {
+		return "分组最多嵌套 " + strconv.Itoa(model.MaxGroupDepth) + " 层"
+	}
+	return ""
+}
+
+// Tree 查询分组树
+// @Summary 查询分组树
+// @Description 查询可访问的分组的树形结构及每个分组的短链接数量,指定 gid 时只返回以该分组为根的子树
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string false "子树的根分组"
+// @Success 200 {object} []types.ShortLinkGroupTreeNode{}
+// @Router /api/short-link/admin/v1/group/tree [get]
+func (h *shortLinkGroupsHandler) Tree(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	groups, roles, err := accessibleGroups(ctx, h.iDao, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	forest := newGroupForest(groups)
+	roots := forest.roots
+	if gid := c.Query("gid"); gid != "" {
+		root, ok := forest.groups[gid]
+		if !ok {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("分组不存在")).ToJSON(c)
+			return
+		}
+		roots = []*model.ShortLinkGroup{root}
+	}
+	res := make([]*types.ShortLinkGroupTreeNode, 0, len(roots))
+	for _, root := range roots {
+		node, err := newGroupTreeNode(ctx, forest, roles, root, forest.depth(root.Gid))
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		res = append(res, node)
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// newGroupTreeNode 生成以 group 为根的子树,短链接数量来自 ShortLinkGroupCountCache
+func newGroupTreeNode(ctx context.Context, forest *groupForest, roles map[string]string, group *model.ShortLinkGroup, depth int) (*types.ShortLinkGroupTreeNode, error) {
+	count, err := dao.ShortLinkDao().Count(ctx, group.Gid)
+	if err != nil {
+		return nil, err
+	}
+	node := &types.ShortLinkGroupTreeNode{
+		Gid:        group.Gid,
+		Name:       group.Name,
+		ParentGid:  group.ParentGid,
+		IsDefault:  group.IsDefault,
+		Role:       roles[group.Gid],
+		Depth:      depth,
+		Count:      count,
+		TotalCount: count,
+		Children:   make([]*types.ShortLinkGroupTreeNode, 0, len(forest.children[group.Gid])),
+	}
+	// 嵌套层数由写入时保证,此处防御异常数据导致的无限递归
+	if depth >= model.MaxGroupDepth*2 {
+		return node, nil
+	}
+	for _, child := range forest.children[group.Gid] {
+		childNode, err := newGroupTreeNode(ctx, forest, roles, child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.TotalCount += childNode.TotalCount
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+// TreeStatistic 查询分组及其全部子分组的汇总访问统计
+// @Summary 查询分组及其全部子分组的汇总访问统计
+// @Description 汇总日期范围内分组及其可访问的子分组的按天 PV,UV,UIP
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "组id"
+// @Param startDate query string true "开始日期,format:2006-01-02"
+// @Param endDate query string false "结束日期,format:2006-01-02,默认为开始日期"
+// @Success 200 {object} types.GroupTreeStatisticResponse{}
+// @Router /api/short-link/admin/v1/group/tree/stats [get]
+func (h *shortLinkGroupsHandler) TreeStatistic(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	gid := c.Query("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("gid不能为空")).ToJSON(c)
+		return
+	}
+	start, end, ok := parseStatisticDateRange(c)
+	if !ok {
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeGroup(ctx, claims.UID, gid, model.GroupRoleViewer); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	groups, _, err := accessibleGroups(ctx, h.iDao, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := &types.GroupTreeStatisticResponse{
+		Gid:       gid,
+		StartDate: start,
+		EndDate:   end,
+		Daily:     make([]*types.GroupStatisticPeriod, 0),
+		Groups:    make([]*types.GroupStatisticGroup, 0),
+	}
+	daily := make(map[string]*types.GroupStatisticPeriod)
+	for _, group := range newGroupForest(groups).subtree(gid) {
+		stats, err := dao.GroupStatsDao().ListGroupStats(ctx, group.Gid, start, end, false)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		item := &types.GroupStatisticGroup{Gid: group.Gid, Name: group.Name, ParentGid: group.ParentGid}
+		for _, stat := range stats {
+			period := newGroupStatisticPeriod(stat)
+			item.Pv += period.Pv
+			item.Uv += period.Uv
+			item.Uip += period.Uip
+			sum, ok := daily[period.Date]
+			if !ok {
+				sum = &types.GroupStatisticPeriod{Date: period.Date, Hour: model.StatisticWholeDay}
+				daily[period.Date] = sum
+				res.Daily = append(res.Daily, sum)
+			}
+			sum.Pv += period.Pv
+			sum.Uv += period.Uv
+			sum.Uip += period.Uip
+		}
+		res.Pv += item.Pv
+		res.Uv += item.Uv
+		res.Uip += item.Uip
+		res.Groups = append(res.Groups, item)
+	}
+	sort.Slice(res.Daily, func(i, j int) bool { return res.Daily[i].Date < res.Daily[j].Date })
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
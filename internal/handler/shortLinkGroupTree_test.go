@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"SnapLink/internal/model"
+	"testing"
+)
+
+// 测试用的分组:
+//
+//	a ─ b ─ c ─ d ─ g
+//	└ e
+//	x
+//	p ⇄ q (异常数据,互为父分组)
+func testGroupForest() *groupForest {
+	return newGroupForest([]*model.ShortLinkGroup{
+		{Gid: "a"},
+		{Gid: "b", ParentGid: "a"},
+		{Gid: "c", ParentGid: "b"},
+		{Gid: "d", ParentGid: "c"},
+		{Gid: "g", ParentGid: "d"},
+		{Gid: "e", ParentGid: "a"},
+		{Gid: "x", ParentGid: "other"}, // 父分组不可访问,作为顶层分组
+		{Gid: "p", ParentGid: "q"},
+		{Gid: "q", ParentGid: "p"},
+	})
+}
+
+func TestGroupForestRoots(t *testing.T) {
+	f := testGroupForest()
+	got := make([]string, 0, len(f.roots))
+	for _, root := range f.roots {
+		got = append(got, root.Gid)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "x" {
+		t.Errorf("roots = %v, want [a x]", got)
+	}
+}
+
+func TestGroupForestDepthHeight(t *testing.T) {
+	f := testGroupForest()
+	tests := []struct {
+		gid    string
+		depth  int
+		height int
+	}{
+		{"a", 1, 5},
+		{"b", 2, 4},
+		{"g", 5, 1},
+		{"e", 2, 1},
+		{"x", 1, 1},
+		{"missing", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := f.depth(tt.gid); got != tt.depth {
+			t.Errorf("depth(%s) = %d, want %d", tt.gid, got, tt.depth)
+		}
+		if got := f.height(tt.gid); got != tt.height {
+			t.Errorf("height(%s) = %d, want %d", tt.gid, got, tt.height)
+		}
+	}
+	// 环状的异常数据不能导致死循环
+	if got := f.depth("p"); got > model.MaxGroupDepth*2+1 {
+		t.Errorf("depth(p) = %d, want at most %d", got, model.MaxGroupDepth*2+1)
+	}
+}
+
+func TestGroupForestContains(t *testing.T) {
+	f := testGroupForest()
+	tests := []struct {
+		ancestor, gid string
+		want          bool
+	}{
+		{"a", "a", true},
+		{"a", "g", true},
+		{"b", "d", true},
+		{"d", "b", false},
+		{"b", "e", false},
+		{"x", "a", false},
+		{"a", "missing", false},
+		{"p", "q", true},
+	}
+	for _, tt := range tests {
+		if got := f.contains(tt.ancestor, tt.gid); got != tt.want {
+			t.Errorf("contains(%s, %s) = %v, want %v", tt.ancestor, tt.gid, got, tt.want)
+		}
+	}
+}
+
+func TestGroupForestSubtree(t *testing.T) {
+	f := testGroupForest()
+	if got := len(f.subtree("a")); got != 6 {
+		t.Errorf("len(subtree(a)) = %d, want 6", got)
+	}
+	if got := f.subtree("missing"); got != nil {
+		t.Errorf("subtree(missing) = %v, want nil", got)
+	}
+}
+
+func TestCheckGroupParent(t *testing.T) {
+	f := testGroupForest()
+	tests := []struct {
+		name           string
+		parentGid, gid string
+		wantErr        bool
+	}{
+		{"move to top level", "", "b", false},
+		{"parent not owned", "missing", "b", true},
+		{"move into itself", "b", "b", true},
+		{"move into own subtree", "d", "b", true},
+		{"move into own child", "c", "b", true},
+		{"move sibling under leaf", "d", "e", false},
+		{"move subtree under root", "x", "b", false},
+		{"create under deepest group", "g", "", true},
+		{"create at the depth limit", "d", "", false},
+		{"subtree exceeds the depth limit", "e", "b", true},
+	}
+	for _, tt := range tests {
+		msg := checkGroupParent(f, tt.parentGid, tt.gid)
+		if (msg != "") != tt.wantErr {
+			t.Errorf("%s: checkGroupParent(%s, %s) = %q, wantErr %v", tt.name, tt.parentGid, tt.gid, msg, tt.wantErr)
+		}
+	}
+}
@@ -1,17 +1,19 @@
 package handler
 
 import (
+	"SnapLink/internal/authz"
 	"SnapLink/internal/cache"
+	"SnapLink/internal/custom_err"
 	"SnapLink/internal/dao"
 	"SnapLink/internal/ecode"
 	"SnapLink/internal/model"
+	"SnapLink/internal/token"
 	"SnapLink/internal/types"
 	"SnapLink/pkg/serialize"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/zhufuyi/sponge/pkg/gin/middleware"
-	"github.com/zhufuyi/sponge/pkg/jwt"
 )
 
 var _ ShortLinkGroupHandler = (*shortLinkGroupsHandler)(nil)
@@ -22,21 +24,36 @@ type ShortLinkGroupHandler interface {
 	List(c *gin.Context)
 	UpdateByGID(c *gin.Context)
 	DelByGID(c *gin.Context)
+	DeleteTask(c *gin.Context)
 	UpdateSortOrder(c *gin.Context)
+	InviteMember(c *gin.Context)
+	ListMembers(c *gin.Context)
+	UpdateMember(c *gin.Context)
+	RemoveMember(c *gin.Context)
+	ListInvitations(c *gin.Context)
+	AcceptInvitation(c *gin.Context)
+	DeclineInvitation(c *gin.Context)
+	GetSettings(c *gin.Context)
+	UpdateSettings(c *gin.Context)
+	MoveGroup(c *gin.Context)
+	Tree(c *gin.Context)
+	TreeStatistic(c *gin.Context)
 }
 
 type shortLinkGroupsHandler struct {
-	iDao dao.ShortLinkGroupDao
+	iDao     dao.ShortLinkGroupDao
+	iUserDao dao.TUserDao
+	iAuthz   authz.Authorizer
 }
 
 // NewShortLinkGroupHandler creating the handler interface
 func NewShortLinkGroupHandler() ShortLinkGroupHandler {
-	return &shortLinkGroupsHandler{
-		iDao: dao.NewShortLinkGroupDao(
-			model.GetDB(),
-			cache.SLGroup(),
-		),
+	h := &shortLinkGroupsHandler{
+		iDao:     dao.NewShortLinkGroupDao(model.GetDB()),
+		iUserDao: dao.NewTUserDao(model.GetDB(), cache.NewTUserCache(model.GetCacheType())),
 	}
+	h.iAuthz = authz.NewAuthorizer(dao.ShortLinkDao(), h.iDao)
+	return h
 }
 
 // Create  创建短链接分组
@@ -59,7 +76,7 @@ func (h *shortLinkGroupsHandler) Create(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 	username := claims.UID
 
 	// 2.参数校验
@@ -67,8 +84,21 @@ func (h *shortLinkGroupsHandler) Create(c *gin.Context) {
 		Gid:       uuid.NewString(),
 		Name:      param.Name,
 		CUsername: username,
+		ParentGid: param.ParentGid,
 	}
 	ctx := middleware.WrapCtx(c)
+	if param.ParentGid != "" {
+		// 只能在自己创建的分组下创建子分组
+		owned, err := h.iDao.GetAllByCUser(ctx, username)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		if msg := checkGroupParent(newGroupForest(owned), param.ParentGid, ""); msg != "" {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg(msg)).ToJSON(c)
+			return
+		}
+	}
 	err := h.iDao.Create(ctx, group)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
@@ -89,10 +119,11 @@ func (h *shortLinkGroupsHandler) Create(c *gin.Context) {
 // @Redirect /api/v1/slink/group/list [get]
 func (h *shortLinkGroupsHandler) List(c *gin.Context) {
 	//1. 参数解析
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 	username := claims.UID
 	ctx := middleware.WrapCtx(c)
-	groups, err := h.iDao.GetAllByCUser(ctx, username)
+	// 包括自己创建的分组与已加入的共享分组
+	groups, roles, err := accessibleGroups(ctx, h.iDao, username)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
@@ -107,6 +138,7 @@ func (h *shortLinkGroupsHandler) List(c *gin.Context) {
 		res = append(res, types.NewShortLinkGroupListItem(map[string]any{
 			"group": group,
 			"count": count,
+			"role":  roles[group.Gid],
 		}))
 	}
 	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
@@ -135,9 +167,13 @@ func (h *shortLinkGroupsHandler) UpdateByGID(c *gin.Context) {
 		return
 	}
 
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 	username := claims.UID
 	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeGroup(ctx, username, req.Gid, model.GroupRoleOwner); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
 	group, err := h.iDao.UpdateByGidAndUsername(ctx, req.Gid, req.Name, username)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
@@ -148,27 +184,101 @@ func (h *shortLinkGroupsHandler) UpdateByGID(c *gin.Context) {
 
 // DelByGID 根据 gid 删除对应的短链接分组
 // @Summary 根据 gid 删除对应的短链接分组
-// @Description 根据 gid 删除对应的短链接分组
+// @Description 删除分组后由后台任务按 strategy 处理分组内的短链接: move 移动到 targetGid 分组(为空时移动到默认分组), disable 全部停用, delete 全部删除;默认分组与有子分组的分组不能删除
 // @Tags shortLinkGroup
-// @Accept application/json
 // @Produce application/json
 // @Param Authorization header string true "token"
-// @Param gid body string true "gid"
+// @Param gid query string true "gid"
+// @Param strategy query string true "短链接处理策略"
+// @Param targetGid query string false "短链接移动到的分组,为空时使用默认分组"
+// @Success 200 {object} model.GroupDeleteTask{}
 func (h *shortLinkGroupsHandler) DelByGID(c *gin.Context) {
+	req := new(types.ShortLinkGroupDeleteReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, req.Gid, model.GroupRoleOwner)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	if group.IsDefault {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("默认分组不能删除")).ToJSON(c)
+		return
+	}
+	owned, err := h.iDao.GetAllByCUser(ctx, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if len(newGroupForest(owned).children[req.Gid]) > 0 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("请先移动或删除子分组")).ToJSON(c)
+		return
+	}
+	if req.Strategy == model.GroupDeleteStrategyMove {
+		// 未指定目标分组时移动到默认分组
+		if req.TargetGid == "" {
+			target, err := h.iDao.GetDefault(ctx, username)
+			if err != nil {
+				serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+				return
+			}
+			req.TargetGid = target.Gid
+		}
+		if req.TargetGid == req.Gid {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("请指定其他分组作为目标分组")).ToJSON(c)
+			return
+		}
+		if _, err := h.iAuthz.AuthorizeGroup(ctx, username, req.TargetGid, model.GroupRoleEditor); err != nil {
+			responseAuthzError(c, err, "目标分组不存在")
+			return
+		}
+	} else {
+		req.TargetGid = ""
+	}
+	task, err := h.iDao.DelByGidAndUsername(ctx, req.Gid, username, req.Strategy, req.TargetGid)
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("分组不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(task)).ToJSON(c)
+}
+
+// DeleteTask 查询分组删除任务的进度
+// @Summary 查询分组删除任务的进度
+// @Description 查询已删除分组中短链接的处理进度
+// @Tags shortLinkGroup
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "gid"
+// @Success 200 {object} model.GroupDeleteTask{}
+func (h *shortLinkGroupsHandler) DeleteTask(c *gin.Context) {
 	gid := c.Query("gid")
 	if gid == "" {
 		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(errors.New("gid is empty"))).ToJSON(c)
 		return
 	}
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 	username := claims.UID
-	ctx := middleware.WrapCtx(c)
-	err := h.iDao.DelByGidAndUsername(ctx, gid, username)
+	// 任务按创建人分表,只能查到自己的任务
+	task, err := h.iDao.GetDeleteTask(middleware.WrapCtx(c), gid, username)
 	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("删除任务不存在")).ToJSON(c)
+			return
+		}
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	serialize.NewResponse(200).ToJSON(c)
+	serialize.NewResponse(200, serialize.WithData(task)).ToJSON(c)
 }
 
 // UpdateSortOrder 更新排序
@@ -186,7 +296,7 @@ func (h *shortLinkGroupsHandler) UpdateSortOrder(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 	username := claims.UID
 	n := len(form)
 	gids, sortOrders := make([]string, n), make([]int, n)
@@ -195,6 +305,10 @@ func (h *shortLinkGroupsHandler) UpdateSortOrder(c *gin.Context) {
 		sortOrders[i] = v.SortOrder
 	}
 	ctx := middleware.WrapCtx(c)
+	if err := h.iAuthz.AuthorizeGroups(ctx, username, gids, model.GroupRoleOwner); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
 	err := h.iDao.UpdateSortOrderByGidAndUsername(ctx, gids, sortOrders, username)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// maxHistoryPageSize 修改历史每页的最大条数
+const maxHistoryPageSize = 100
+
+// History 查询短链接的修改历史
+// @Summary 查询短链接的修改历史
+// @Description 分页查询短链接的修改历史,最近的修改在前
+// @Tags shortLink
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param uri path string true "短链接"
+// @Param current query int false "当前页"
+// @Param size query int false "每页大小"
+// @Success 200 {object} types.ListShortLinkHistoryResponse{}
+// @Router /api/short-link/admin/v1/shortlink/{uri}/history [get]
+func (h *shortLinkHandler) History(c *gin.Context) {
+	uri := c.Param("uri")
+	current, err := strconv.Atoi(c.DefaultQuery("current", "1"))
+	if err != nil || current < 1 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("current 参数错误")).ToJSON(c)
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > maxHistoryPageSize {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("size 参数错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if _, err = h.iAuthz.AuthorizeShortLink(ctx, claims.UID, uri, model.GroupRoleViewer); err != nil {
+		responseAuthzError(c, err, "短链接不存在")
+		return
+	}
+	total, err := h.iDao.CountHistory(ctx, uri)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	list, err := h.iDao.ListHistory(ctx, uri, current, size)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := types.ListShortLinkHistoryResponse{
+		Total:   total,
+		Size:    size,
+		Current: current,
+		Records: make([]*types.ShortLinkHistoryRecord, 0, len(list)),
+	}
+	for _, history := range list {
+		res.Records = append(res.Records, &types.ShortLinkHistoryRecord{
+			ID:        history.ID,
+			Actor:     history.Actor,
+			Action:    history.Action,
+			Before:    history.Before,
+			After:     history.After,
+			CreatedAt: history.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// Rollback 回滚短链接的一次修改
+// @Summary 回滚短链接的一次修改
+// @Description 将短链接恢复为该次修改之前的状态,回滚本身也会记录在修改历史中
+// @Tags shortLink
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param uri path string true "短链接"
+// @Param id path int true "修改历史id"
+// @Router /api/short-link/admin/v1/shortlink/{uri}/history/{id}/rollback [post]
+func (h *shortLinkHandler) Rollback(c *gin.Context) {
+	uri := c.Param("uri")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("修改历史id格式错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	current, err := h.iAuthz.AuthorizeShortLink(ctx, username, uri, model.GroupRoleEditor)
+	if err != nil {
+		responseAuthzError(c, err, "短链接不存在")
+		return
+	}
+	history, err := h.iDao.GetHistory(ctx, uri, uint(id))
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("修改历史不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	// 原来的分组可能已经被删除
+	if _, err = h.iAuthz.AuthorizeGroup(ctx, username, history.Before.Gid, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "原分组不存在,无法回滚")
+		return
+	}
+	sl, err := h.applySnapshot(ctx, current, history.Before, username, model.HistoryActionRollback)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(sl)).ToJSON(c)
+}
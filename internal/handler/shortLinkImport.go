@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/importer"
+	"SnapLink/pkg/serialize"
+	"io"
+	"net/url"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxImportFileSize 导入文件大小上限
+	maxImportFileSize = 20 << 20
+	// maxImportRecords 单次导入的条数上限
+	maxImportRecords = 50000
+)
+
+// importCodeRegexp 导入的短链接需要能作为 uri 使用,长度受 redirect 表中 uri 字段限制
+var importCodeRegexp = regexp.MustCompile(`^[0-9A-Za-z_-]{1,10}$`)
+
+// Import 导入短链接
+// @Summary 导入短链接
+// @Description 从其他短链接服务的导出文件中导入短链接,保留原有的短链接作为自定义 uri
+// @Tags shortLink
+// @Accept multipart/form-data
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid formData string true "导入到的分组"
+// @Param format formData string false "文件格式 csv(默认), yourls-csv, yourls-sql"
+// @Param file formData file true "导出文件"
+// @Success 200 {object} types.ImportShortLinkResponse{}
+// @Router /api/short-link/admin/v1/shortlink/import [post]
+func (h *shortLinkHandler) Import(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	gid := c.PostForm("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("gid不能为空")).ToJSON(c)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("导入文件过大")).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	// 只允许导入到有编辑权限的分组
+	if _, err = h.iAuthz.AuthorizeGroup(ctx, username, gid, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	defer file.Close()
+	reader, err := importer.NewReader(c.DefaultPostForm("format", importer.FormatCSV), file)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	// 先完整解析一遍,文件格式有误时不导入任何数据
+	records := make([]*importer.Record, 0)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("文件解析失败"), serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		if len(records) >= maxImportRecords {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("导入条数过多")).ToJSON(c)
+			return
+		}
+		records = append(records, record)
+	}
+
+	res := &types.ImportShortLinkResponse{
+		Total:     len(records),
+		Conflicts: make([]*types.ImportItemResult, 0),
+		Failures:  make([]*types.ImportItemResult, 0),
+	}
+	seen := make(map[string]struct{}, len(records))
+	for _, record := range records {
+		item := &types.ImportItemResult{Row: record.Row, Code: record.Code, Url: record.URL}
+		sLink, reason := newImportedShortLink(gid, record, createdType(claims, model.CreatedTypeConsole))
+		if sLink == nil {
+			item.Reason = reason
+			res.Failures = append(res.Failures, item)
+			continue
+		}
+		// 文件内部的重复
+		if _, ok := seen[record.Code]; ok {
+			item.Reason = "文件中存在重复的短链接"
+			res.Conflicts = append(res.Conflicts, item)
+			continue
+		}
+		seen[record.Code] = struct{}{}
+		exist, err := h.iDao.HasURI(ctx, sLink.Uri)
+		if err != nil {
+			logger.Warn("查询短链接是否存在失败", logger.Err(err), logger.String("uri", sLink.Uri), middleware.GCtxRequestIDField(c))
+		}
+		if exist && err == nil {
+			item.Reason = "短链接已经存在"
+			res.Conflicts = append(res.Conflicts, item)
+			continue
+		}
+		// 布隆过滤器未加载全部数据时,由数据库的唯一索引兜底
+		if err = h.iDao.Create(ctx, sLink); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				item.Reason = "短链接已经存在"
+				res.Conflicts = append(res.Conflicts, item)
+				continue
+			}
+			logger.Error("导入短链接失败", logger.Err(err), logger.Any("record", record), middleware.GCtxRequestIDField(c))
+			item.Reason = "创建失败"
+			res.Failures = append(res.Failures, item)
+			continue
+		}
+		if err = cache.BFCache().BFAdd(ctx, "uri", sLink.Uri); err != nil {
+			logger.Warn("布隆过滤器添加失败", logger.Err(err), logger.String("uri", sLink.Uri), middleware.GCtxRequestIDField(c))
+		}
+		res.Imported++
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// newImportedShortLink 校验导入记录并转换为短链接,校验失败时返回原因
+func newImportedShortLink(gid string, record *importer.Record, createdType int) (*model.ShortLink, string) {
+	if !importCodeRegexp.MatchString(record.Code) {
+		return nil, "短链接格式错误,仅支持 1-10 位的字母、数字、下划线和中划线"
+	}
+	u, err := url.Parse(record.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "原始链接格式错误"
+	}
+	return &model.ShortLink{
+		CreatedAt:   record.CreatedAt,
+		Enable:      1,
+		Domain:      u.Host,
+		OriginUrl:   u.String(),
+		Gid:         gid,
+		Description: record.Title,
+		CreatedType: createdType,
+		Uri:         record.Code,
+	}, ""
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"SnapLink/internal/config"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// maxRecyclePageSize 回收站每页的最大条数
+const maxRecyclePageSize = 100
+
+// ListRecycled 分页查询回收站中的短链接
+// @Summary 分页查询回收站中的短链接
+// @Description 分页查询分组回收站中的短链接,最近删除的在前
+// @Tags shortLink
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param gid query string true "组id"
+// @Param current query int false "当前页"
+// @Param size query int false "每页大小"
+// @Success 200 {object} types.ListRecycledShortLinkResponse{}
+// @Router /api/short-link/admin/v1/shortlink/recycle/page [get]
+func (h *shortLinkHandler) ListRecycled(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	gid := c.Query("gid")
+	if gid == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("gid不能为空")).ToJSON(c)
+		return
+	}
+	current, err := strconv.Atoi(c.DefaultQuery("current", "1"))
+	if err != nil || current < 1 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("current 参数错误")).ToJSON(c)
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > maxRecyclePageSize {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("size 参数错误")).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	if err = h.iAuthz.AuthorizeRecycleGroup(ctx, username, gid, model.GroupRoleViewer); err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	total, err := h.iDao.CountRecycled(ctx, gid)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	list, err := h.iDao.ListRecycled(ctx, gid, current, size)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	retention := config.Get().RecycleBin.Retention()
+	res := types.ListRecycledShortLinkResponse{
+		Total:   total,
+		Size:    size,
+		Current: current,
+		Records: make([]*types.RecycledShortLinkRecord, 0, len(list)),
+	}
+	for _, sl := range list {
+		res.Records = append(res.Records, &types.RecycledShortLinkRecord{
+			Gid:       sl.Gid,
+			Uri:       sl.Uri,
+			ShortUrl:  makeFullShortURL(Domain, sl.Uri),
+			OriginUrl: sl.OriginUrl,
+			Describe:  sl.Description,
+			CreatedAt: sl.CreatedAt.Format("2006-01-02 15:04:05"),
+			DeletedAt: sl.DeletedAt.Time.Format("2006-01-02 15:04:05"),
+			ExpireAt:  sl.DeletedAt.Time.Add(retention).Format("2006-01-02 15:04:05"),
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// Restore 从回收站恢复短链接
+// @Summary 从回收站恢复短链接
+// @Description 从回收站恢复短链接,恢复后短链接重新可以访问;删除前所在的分组已被删除时恢复到默认分组
+// @Tags shortLink
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.RestoreShortLinkRequest true "短链接"
+// @Success 200 {object} types.RestoreShortLinkResponse{}
+// @Router /api/short-link/admin/v1/shortlink/recycle/restore [post]
+func (h *shortLinkHandler) Restore(c *gin.Context) {
+	req := new(types.RestoreShortLinkRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeRecycled(ctx, claims.UID, req.Uri, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "回收站中不存在该短链接")
+		return
+	}
+	gid, err := h.iDao.Restore(ctx, req.Uri)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(&types.RestoreShortLinkResponse{Gid: gid})).ToJSON(c)
+}
+
+// Purge 彻底删除回收站中的短链接
+// @Summary 彻底删除回收站中的短链接
+// @Description 彻底删除回收站中的短链接,删除后无法恢复,uri 可以被重新使用
+// @Tags shortLink
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param uri path string true "短链接"
+// @Router /api/short-link/admin/v1/shortlink/recycle/{uri} [delete]
+func (h *shortLinkHandler) Purge(c *gin.Context) {
+	uri := c.Param("uri")
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if _, err := h.iAuthz.AuthorizeRecycled(ctx, claims.UID, uri, model.GroupRoleEditor); err != nil {
+		responseAuthzError(c, err, "回收站中不存在该短链接")
+		return
+	}
+	if err := h.iDao.Purge(ctx, uri); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/elasticsearch"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// maxSearchPageSize 搜索每页的最大条数
+const maxSearchPageSize = 100
+
+// Search 搜索短链接
+// @Summary 搜索短链接
+// @Description 在当前用户的全部分组中,按 uri、原始链接、域名、描述与标签进行前缀与模糊搜索
+// @Tags shortLink
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param q query string true "搜索内容"
+// @Param current query int false "当前页"
+// @Param size query int false "每页大小"
+// @Success 200 {object} types.SearchShortLinkResponse{}
+// @Router /api/short-link/admin/v1/shortlink/search [get]
+func (h *shortLinkHandler) Search(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("搜索内容不能为空")).ToJSON(c)
+		return
+	}
+	current, err := strconv.Atoi(c.DefaultQuery("current", "1"))
+	if err != nil || current < 1 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("current 参数错误")).ToJSON(c)
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > maxSearchPageSize {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("size 参数错误")).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	// 搜索范围限定在当前用户可以访问的分组内
+	groups, _, err := accessibleGroups(ctx, h.iGroupDao, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := types.SearchShortLinkResponse{
+		Size:    size,
+		Current: current,
+		Records: make([]*types.SearchShortLinkRecord, 0),
+	}
+	if len(groups) == 0 {
+		serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+		return
+	}
+	gids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		gids = append(gids, group.Gid)
+	}
+	total, docs, err := elasticsearch.SearchShortLink(ctx, q, gids, (current-1)*size, size)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res.Total = total
+	for _, doc := range docs {
+		res.Records = append(res.Records, &types.SearchShortLinkRecord{
+			Gid:       doc.Gid,
+			Uri:       doc.Uri,
+			ShortUrl:  makeFullShortURL(Domain, doc.Uri),
+			OriginUrl: doc.OriginUrl,
+			Domain:    doc.Domain,
+			Describe:  doc.Description,
+			Tags:      doc.Tags,
+			CreatedAt: doc.CreatedAt,
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
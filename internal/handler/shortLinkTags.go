@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+)
+
+// maxLinkTags 单个短链接的标签数量上限
+const maxLinkTags = 20
+
+// SetTags 设置短链接的标签
+// @Summary 设置短链接的标签
+// @Description 覆盖短链接原有的标签,tagIds 为空时清空标签
+// @Tags shortLink
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.ShortLinkSetTagsReq true "短链接与标签"
+// @Router /api/short-link/admin/v1/shortlink/tags [put]
+func (h *shortLinkHandler) SetTags(c *gin.Context) {
+	req := new(types.ShortLinkSetTagsReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	tagIDs := uniqueTagIDs(req.TagIDs)
+	if len(tagIDs) > maxLinkTags {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签数量过多")).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	// 只允许给有编辑权限的分组下的短链接设置标签
+	sl, err := h.iAuthz.AuthorizeShortLink(ctx, username, req.Uri, model.GroupRoleEditor)
+	if err != nil {
+		responseAuthzError(c, err, "短链接不存在")
+		return
+	}
+	// 短链接的标签统一保存在分组创建人名下,共享分组的成员使用创建人的标签
+	group, err := h.iAuthz.AuthorizeGroup(ctx, username, sl.Gid, model.GroupRoleEditor)
+	if err != nil {
+		responseAuthzError(c, err, "分组不存在")
+		return
+	}
+	owner := group.CUsername
+	tags, err := h.iTagDao.GetByIDs(ctx, owner, tagIDs)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if len(tags) != len(tagIDs) {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签不存在")).ToJSON(c)
+		return
+	}
+	if err = h.iTagDao.SetLinkTags(ctx, owner, req.Uri, tagIDs); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// listByTags 分页查询分组内同时拥有全部指定标签的短链接,owner 为分组创建人
+// 标签关系按用户分表,无法与短链接表联表,因此先查出全部 uri 再在内存中排序分页
+func (h *shortLinkHandler) listByTags(ctx context.Context, owner, gid string, tagIDs []uint, orderTag string, page, size int) (int64, []*model.ShortLink, error) {
+	uris, err := h.iTagDao.URIsByTags(ctx, owner, tagIDs)
+	if err != nil {
+		return 0, nil, err
+	}
+	list, err := h.iDao.ListByURIs(ctx, gid, uris)
+	if err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case orderTag == types.OrderTagCreateTime:
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	case cache.IsRankOrderTag(orderTag):
+		matched := make([]string, 0, len(list))
+		for _, sl := range list {
+			matched = append(matched, sl.Uri)
+		}
+		statics, err := cache.ShortLinkStats().Get(ctx, matched)
+		if err != nil {
+			return 0, nil, err
+		}
+		// 与排行榜保持一致: 访问量从高到低,相同时按 uri 倒序
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := statics[list[i].Uri].Metric(orderTag), statics[list[j].Uri].Metric(orderTag)
+			if a != b {
+				return a > b
+			}
+			return list[i].Uri > list[j].Uri
+		})
+	}
+	total := int64(len(list))
+	start := (page - 1) * size
+	if start < 0 || start >= len(list) {
+		return total, []*model.ShortLink{}, nil
+	}
+	end := start + size
+	if end > len(list) {
+		end = len(list)
+	}
+	return total, list[start:end], nil
+}
+
+// parseTagIDs 解析以逗号分隔的标签 id
+func parseTagIDs(s string) ([]uint, error) {
+	ids := make([]uint, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("标签id格式错误: %s", part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return uniqueTagIDs(ids), nil
+}
+
+// uniqueTagIDs 去除重复的标签 id,保持原有顺序
+func uniqueTagIDs(ids []uint) []uint {
+	seen := make(map[uint]struct{}, len(ids))
+	res := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		res = append(res, id)
+	}
+	return res
+}
+
+// toTagItems 转换为响应中的标签
+func toTagItems(tags []*model.Tag) []*types.TagItem {
+	items := make([]*types.TagItem, 0, len(tags))
+	for _, tag := range tags {
+		items = append(items, &types.TagItem{ID: tag.ID, Name: tag.Name, Color: tag.Color})
+	}
+	return items
+}
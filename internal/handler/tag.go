@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"gorm.io/gorm"
+)
+
+var _ TagHandler = (*tagHandler)(nil)
+
+// TagHandler defining the handler interface
+type TagHandler interface {
+	Create(c *gin.Context)
+	List(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Stats(c *gin.Context)
+}
+
+type tagHandler struct {
+	iDao dao.TagDao
+}
+
+// NewTagHandler creating the handler interface
+func NewTagHandler() TagHandler {
+	return &tagHandler{
+		iDao: dao.NewTagDao(model.GetDB()),
+	}
+}
+
+// Create 创建标签
+// @Summary 创建标签
+// @Description 创建标签,同一用户下标签名称不能重复
+// @Tags tag
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.TagCreateReq true "标签信息"
+// @Success 200 {object} types.TagItem{}
+// @Router /api/short-link/admin/v1/tag [post]
+func (h *tagHandler) Create(c *gin.Context) {
+	req := new(types.TagCreateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	tag := &model.Tag{
+		Name:      req.Name,
+		Color:     req.Color,
+		CUsername: claims.UID,
+	}
+	ctx := middleware.WrapCtx(c)
+	if err := h.iDao.Create(ctx, tag); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签已经存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(&types.TagItem{ID: tag.ID, Name: tag.Name, Color: tag.Color})).ToJSON(c)
+}
+
+// List 列出用户的全部标签
+// @Summary 列出用户的全部标签
+// @Description 列出用户的全部标签及每个标签下的短链接数量
+// @Tags tag
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Success 200 {object} []types.TagListItem{}
+// @Router /api/short-link/admin/v1/tag [get]
+func (h *tagHandler) List(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	tags, err := h.iDao.ListByUsername(ctx, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	counts, err := h.iDao.CountLinks(ctx, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := make([]*types.TagListItem, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, &types.TagListItem{
+			ID:        tag.ID,
+			Name:      tag.Name,
+			Color:     tag.Color,
+			LinkCount: counts[tag.ID],
+			CreatedAt: tag.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// Update 更新标签
+// @Summary 更新标签
+// @Description 更新标签的名称与颜色
+// @Tags tag
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.TagUpdateReq true "标签信息"
+// @Router /api/short-link/admin/v1/tag [put]
+func (h *tagHandler) Update(c *gin.Context) {
+	req := new(types.TagUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	tag := &model.Tag{
+		ID:        req.ID,
+		Name:      req.Name,
+		Color:     req.Color,
+		CUsername: claims.UID,
+	}
+	ctx := middleware.WrapCtx(c)
+	if err := h.iDao.Update(ctx, tag); err != nil {
+		switch {
+		case errors.Is(err, dao.ErrTagNotFound):
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签不存在")).ToJSON(c)
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签已经存在")).ToJSON(c)
+		default:
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		}
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// Delete 删除标签
+// @Summary 删除标签
+// @Description 删除标签,同时移除所有短链接上的该标签
+// @Tags tag
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param id path int true "标签id"
+// @Router /api/short-link/admin/v1/tag/{id} [delete]
+func (h *tagHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签id格式错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if err = h.iDao.Delete(ctx, claims.UID, uint(id)); err != nil {
+		if errors.Is(err, dao.ErrTagNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签不存在")).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// Stats 标签汇总统计
+// @Summary 标签汇总统计
+// @Description 汇总标签下全部短链接的今日与累计访问统计
+// @Tags tag
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param id path int true "标签id"
+// @Success 200 {object} types.TagStatsRes{}
+// @Router /api/short-link/admin/v1/tag/{id}/stats [get]
+func (h *tagHandler) Stats(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签id格式错误")).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	username := claims.UID
+	ctx := middleware.WrapCtx(c)
+	tags, err := h.iDao.GetByIDs(ctx, username, []uint{uint(id)})
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if len(tags) == 0 {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("标签不存在")).ToJSON(c)
+		return
+	}
+	uris, err := h.iDao.URIsByTags(ctx, username, []uint{uint(id)})
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	sum, err := cache.ShortLinkStats().Sum(ctx, uris)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(&types.TagStatsRes{
+		ID:        tags[0].ID,
+		Name:      tags[0].Name,
+		LinkCount: int64(len(uris)),
+		TodayPV:   sum.TodayPV,
+		TotalPV:   sum.TotalPV,
+		TodayUV:   sum.TodayUV,
+		TotalUV:   sum.TotalUV,
+		TodayUIP:  sum.TodayUIP,
+		TotalUIP:  sum.TotalUIP,
+	})).ToJSON(c)
+}
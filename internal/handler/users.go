@@ -2,18 +2,29 @@ package handler
 
 import (
 	"SnapLink/internal/cache"
+	"SnapLink/internal/config"
 	"SnapLink/internal/dao"
 	"SnapLink/internal/ecode"
 	"SnapLink/internal/model"
+	"SnapLink/internal/token"
 	"SnapLink/internal/types"
 	"SnapLink/internal/utils"
 	"SnapLink/pkg/serialize"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/zhufuyi/sponge/pkg/gin/middleware"
-	"github.com/zhufuyi/sponge/pkg/jwt"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+	"math"
+	"net"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var phoneRegexp = `^\+[1-9]\d{1,14}$`
@@ -160,11 +171,15 @@ func (h *UsersHandler) Register(c *gin.Context) {
 		RealName: form.RealName,
 		Phone:    form.Phone,
 		Mail:     form.Mail,
+		Role:     model.RoleUser,
 	}
 
-	//6. 注册用户
+	//6. 注册用户,同时创建默认分组
 	err = h.iDao.Create(ctx, u)
 	if err != nil {
+		if responseContactExist(c, err) {
+			return
+		}
 		//布隆过滤器的漏网之鱼
 
 		if dao.ErrDuplicateEntry.Is(err) {
@@ -181,6 +196,10 @@ func (h *UsersHandler) Register(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
+	//8. 发送邮箱验证邮件,发送失败时用户可以重新发送
+	if err = sendVerifyMail(ctx, u); err != nil {
+		logger.Warn("发送邮箱验证邮件失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
 	//返回注册信息
 	serialize.NewResponse(200, serialize.WithData(types.RegisterRespond{
 		Username: u.Username,
@@ -192,12 +211,13 @@ func (h *UsersHandler) Register(c *gin.Context) {
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 用户登录
+// @Description 用户登录,用户名不存在与密码错误返回相同的错误;连续失败后需要等待一段时间才能重试,失败次数过多时账号或 ip 被临时锁定;
+// @Description 启用两步验证时返回 types.LoginChallengeRespond,需要继续调用两步验证登录接口
 // @Tags users
 // @Accept application/json
 // @Produce application/json
 // @Param Authorization header string false "token"
-// @Param username body string true "用户名"
+// @Param username body string true "用户名、邮箱或手机号"
 // @Param password body string true "密码"
 func (h *UsersHandler) Login(c *gin.Context) {
 	form := new(types.LoginRequest)
@@ -205,45 +225,273 @@ func (h *UsersHandler) Login(c *gin.Context) {
 		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	//1. 检测用户名是否存在
+	//1. 根据用户名、邮箱或手机号查找用户
 	ctx := middleware.WrapCtx(c)
-	has, err := h.iDao.HasUsername(ctx, form.Username)
+	ip := c.ClientIP()
+	user, err := h.getLoginUser(ctx, form.Username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	// 失败次数按用户名统计,使用邮箱或手机号登录时同样计入
+	account := form.Username
+	if user != nil {
+		account = user.Username
+	}
+	//2. 检查账号与 ip 是否需要等待
+	wait, err := cache.LoginGuard().Check(ctx, account, ip)
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	if !has {
-		serialize.NewResponseWithErrCode(ecode.UserNotExistError, serialize.WithErr(errors.New("username not exist"))).ToJSON(c)
+	if wait > 0 {
+		responseLoginLocked(c, wait)
 		return
 	}
-	//2. 检查用户是否登录
-	token := c.Request.Header.Get("Authorization")
-	if token != "" {
-		c.Get("uid")
-		//todo 如何更优雅的调用
+	//3. 检测密码是否正确,用户不存在时同样进行一次密码比较,避免通过响应时间判断用户是否存在
+	if user == nil {
+		_ = utils.Compare(dummyPasswordHash(), form.Password)
+		h.loginFailed(c, account, ip)
+		return
 	}
-	//2. 检测密码是否正确
-	user, err := h.iDao.GetByUsername(ctx, form.Username)
+	if err = utils.Compare(user.Password, form.Password); err != nil {
+		h.loginFailed(c, account, ip)
+		return
+	}
+	//4. 启用两步验证时返回挑战令牌,使用验证码完成登录
+	// 此时登录尚未完成,不能清除失败次数,否则知道密码即可绕过验证码的失败锁定
+	if user.TOTPEnabled {
+		challenge, err := newLoginChallenge(ctx, user.Username)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		serialize.NewResponse(200, serialize.WithData(challenge)).ToJSON(c)
+		return
+	}
+	if err = cache.LoginGuard().Succeed(ctx, user.Username); err != nil {
+		logger.Warn("清除登录失败次数失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
+	//5. 生成访问令牌与刷新令牌
+	pair, err := token.Issue(ctx, user.Username, user.GetRole())
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(errors.Wrap(err, "generate token error"))).ToJSON(c)
+		return
+	}
+	//6. 返回token
+	serialize.NewResponse(200, serialize.WithData(pair)).ToJSON(c)
+}
+
+// getLoginUser 根据登录账号查找用户,包含 @ 时按邮箱查找;
+// 用户名只能包含字母、数字与下划线,按用户名未找到且符合手机号格式时再按手机号查找
+func (h *UsersHandler) getLoginUser(ctx context.Context, account string) (*model.TUser, error) {
+	if strings.Contains(account, "@") {
+		return h.iDao.GetByContact(ctx, model.ContactMail, account)
+	}
+	user, err := h.iDao.GetByUsername(ctx, account)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+	if ok, _ := regexp.MatchString(phoneRegexp, utils.NormalizePhone(account)); !ok {
+		return nil, err
+	}
+	return h.iDao.GetByContact(ctx, model.ContactPhone, account)
+}
+
+// loginFailed 记录登录失败,账号或 ip 被锁定时记录审计日志
+func (h *UsersHandler) loginFailed(c *gin.Context, username, ip string) {
+	cfg := config.Get().Login
+	failure, err := cache.LoginGuard().Fail(middleware.WrapCtx(c), username, ip, cache.LoginPolicy{
+		Window:             cfg.Window(),
+		Lockout:            cfg.Lockout(),
+		MaxDelay:           cfg.Delay(),
+		MaxAccountFailures: cfg.AccountLimit(),
+		MaxIPFailures:      cfg.IPLimit(),
+		FreeFailures:       cfg.FreeAttempts(),
+	})
 	if err != nil {
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	err = utils.Compare(user.Password, form.Password)
+	if failure.AccountLocked {
+		logger.Warn("登录失败次数过多,账号已锁定", logger.String("audit", "login_lockout"),
+			logger.String("username", username), logger.String("ip", ip),
+			logger.Int64("failures", failure.AccountFailures), logger.String("lockout", cfg.Lockout().String()),
+			middleware.GCtxRequestIDField(c))
+	}
+	if failure.IPLocked {
+		logger.Warn("登录失败次数过多,ip 已锁定", logger.String("audit", "login_ip_lockout"),
+			logger.String("username", username), logger.String("ip", ip),
+			logger.Int64("failures", failure.IPFailures), logger.String("lockout", cfg.Lockout().String()),
+			middleware.GCtxRequestIDField(c))
+	}
+	serialize.NewResponseWithErrCode(ecode.LoginFailedError).ToJSON(c)
+}
+
+// responseContactExist 手机号或邮箱已被占用时返回冲突,其他错误返回 false
+func responseContactExist(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, dao.ErrPhoneExist):
+		serialize.NewResponseWithErrCode(ecode.PhoneExistError).ToJSON(c)
+	case errors.Is(err, dao.ErrMailExist):
+		serialize.NewResponseWithErrCode(ecode.MailExistError).ToJSON(c)
+	default:
+		return false
+	}
+	return true
+}
+
+func responseLoginLocked(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	serialize.NewResponseWithErrCode(ecode.LoginLockedError,
+		serialize.WithMsg(fmt.Sprintf("登录失败次数过多,请在 %d 秒后重试", seconds))).ToJSON(c)
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash 用户不存在时用于比较的密码哈希
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash = utils.Encrypt(uuid.NewString())
+	})
+	return dummyHash
+}
+
+// UnlockLogin 解除账号的登录锁定
+// @Summary 解除账号的登录锁定
+// @Description 管理员解除账号因登录失败次数过多产生的锁定,并清除失败次数;指定 ip 时同时解除该 ip 的锁定
+// @Tags users
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param username path string true "用户名"
+// @Param ip query string false "同时解除锁定的 ip"
+// @Router /api/short-link/admin/v1/user/lock/{username} [delete]
+func (h *UsersHandler) UnlockLogin(c *gin.Context) {
+	username := getTUserUsernameFromPath(c)
+	ip := c.Query("ip")
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("ip 格式错误")).ToJSON(c)
+			return
+		}
+		ip = parsed.String()
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	if err := cache.LoginGuard().Unlock(ctx, username); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if ip != "" {
+		if err := cache.LoginGuard().UnlockIP(ctx, ip); err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+	}
+	logger.Info("管理员解除账号登录锁定", logger.String("audit", "login_unlock"),
+		logger.String("username", username), logger.String("ip", ip), logger.String("operator", claims.UID),
+		middleware.GCtxRequestIDField(c))
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// maxUserListWindow 用户列表最多可以查询到的位置,每张分表需要查询 offset+size 条记录,深分页时内存与查询量随分表数量增加
+const maxUserListWindow = 1000
+
+// userSearchColumns 管理员查询用户时允许的查询字段,字段名会拼接到 sql 中,不能直接使用请求中的字段名
+var userSearchColumns = map[string]struct{}{
+	"username": {}, "real_name": {}, "phone": {}, "mail": {}, "role": {},
+}
+
+// ListUsers 管理员查询用户列表
+// @Summary 管理员查询用户列表
+// @Description 并发查询全部用户分表,合并后排序并分页,每页最多 100 条,最多查询前 1000 条,更多的用户需要通过查询条件缩小范围
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.ListUsersRequest true "查询条件"
+// @Success 200 {object} types.ListUsersRespond{}
+// @Router /api/short-link/admin/v1/users/list [post]
+func (h *UsersHandler) ListUsers(c *gin.Context) {
+	form := new(types.ListUsersRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if form.Size <= 0 || form.Size > 100 {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithMsg("size 的范围为 1 到 100")).ToJSON(c)
+		return
+	}
+	if form.Page < 0 || (form.Page+1)*form.Size > maxUserListWindow {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError,
+			serialize.WithMsg(fmt.Sprintf("最多查询前 %d 条,请使用查询条件缩小范围", maxUserListWindow))).ToJSON(c)
+		return
+	}
+	for _, column := range form.Columns {
+		if _, ok := userSearchColumns[column.Name]; !ok {
+			serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithMsg("不支持的查询字段: "+column.Name)).ToJSON(c)
+			return
+		}
+	}
+	users, total, err := h.iDao.GetByColumns(middleware.WrapCtx(c), &form.Params)
 	if err != nil {
-		serialize.NewResponseWithErrCode(ecode.PasswordVerifyError, serialize.WithErr(err)).ToJSON(c)
+		if errors.Is(err, dao.ErrInvalidSort) {
+			serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	items := make([]*types.UserListItem, 0, len(users))
+	for _, u := range users {
+		items = append(items, &types.UserListItem{
+			Username:     u.Username,
+			RealName:     u.RealName,
+			Phone:        u.Phone,
+			Mail:         u.Mail,
+			Role:         u.GetRole(),
+			MailVerified: u.MailVerified,
+			TOTPEnabled:  u.TOTPEnabled,
+			CreatedAt:    u.CreatedAt,
+		})
+	}
+	serialize.NewResponse(200, serialize.WithData(types.ListUsersRespond{Users: items, Total: total})).ToJSON(c)
+}
+
+// RefreshToken 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌与刷新令牌,旧的刷新令牌随即失效;已失效的刷新令牌被再次使用时,同一次登录签发的全部令牌失效
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} token.Pair{}
+func (h *UsersHandler) RefreshToken(c *gin.Context) {
+	form := new(types.RefreshTokenRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
-	//3. 生成token
-	token, err = jwt.GenerateToken(user.Username, "admin")
+	ctx := middleware.WrapCtx(c)
+	pair, err := token.Refresh(ctx, form.RefreshToken)
 	if err != nil {
-		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(errors.Wrap(err, "generate token error"))).ToJSON(c)
+		switch {
+		case errors.Is(err, token.ErrTokenReused):
+			logger.Warn("刷新令牌被重复使用", logger.Err(err), middleware.GCtxRequestIDField(c))
+			serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+		case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrTokenRevoked):
+			serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+		default:
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		}
 		return
 	}
-	//4. 返回token
-	serialize.NewResponse(200, serialize.WithData(gin.H{
-		"token": token,
-	})).ToJSON(c)
-	return
+	serialize.NewResponse(200, serialize.WithData(pair)).ToJSON(c)
 }
 
 // UpdateInfo 根据用户名更新用户信息
@@ -259,7 +507,7 @@ func (h *UsersHandler) Login(c *gin.Context) {
 // @Param mail body string true "邮箱"
 func (h *UsersHandler) UpdateInfo(c *gin.Context) {
 	//能到这步说明token已经验证通过
-	claims, _ := jwt.ParseToken(c.GetHeader("Authorization")[7:])
+	claims, _ := token.FromContext(c)
 
 	form := new(types.UpdateInfoRequest)
 	if err := c.ShouldBind(form); err != nil {
@@ -304,11 +552,37 @@ func (h *UsersHandler) UpdateInfo(c *gin.Context) {
 	if form.Password != "" {
 		user.Password = utils.Encrypt(form.Password)
 	}
+	// 修改邮箱后需要重新验证
+	mailChanged := false
+	if form.Mail != "" {
+		old, err := h.iDao.GetByUsername(ctx, claims.UID)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		mailChanged = old.Mail != form.Mail
+		user.MailVerified = old.MailVerified && !mailChanged
+	}
 	err := h.iDao.Update(ctx, user)
 	if err != nil {
+		if responseContactExist(c, err) {
+			return
+		}
 		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
 		return
 	}
+	// 修改密码后全部设备上的登录失效
+	if form.Password != "" {
+		if err = token.RevokeAll(ctx, claims.UID); err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+	}
+	if mailChanged {
+		if err = sendVerifyMail(ctx, user); err != nil {
+			logger.Warn("发送邮箱验证邮件失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+		}
+	}
 	response := types.UpdateInfoRespond{
 		Username: user.Username,
 		RealName: user.RealName,
@@ -333,11 +607,32 @@ func (h *UsersHandler) CheckLogin(c *gin.Context) {
 
 // Logout
 // @Summary 用户登出
-// @Description 用户登出
+// @Description 用户登出,吊销当前的访问令牌及同一次登录签发的刷新令牌
 // @Tags users
 // @Accept application/json
 // @Produce application/json
+// @Param Authorization header string true "token"
 func (h *UsersHandler) Logout(c *gin.Context) {
-	//todo 此处需要将token加入黑名单
+	claims, _ := token.FromContext(c)
+	if err := token.Revoke(middleware.WrapCtx(c), claims); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	c.JSON(200, "ok")
+}
+
+// LogoutAll
+// @Summary 在全部设备上登出
+// @Description 吊销用户在全部设备上登录签发的令牌
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+func (h *UsersHandler) LogoutAll(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	if err := token.RevokeAll(middleware.WrapCtx(c), claims.UID); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
 	c.JSON(200, "ok")
 }
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/config"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/mail"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/internal/utils"
+	"SnapLink/pkg/serialize"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// verifyMailExpire 邮箱验证链接的有效期
+	verifyMailExpire = 24 * time.Hour
+	// resetPasswordExpire 重置密码链接的有效期
+	resetPasswordExpire = 30 * time.Minute
+	// mailCooldown 同一用户两次发送同类邮件的最小间隔
+	mailCooldown = time.Minute
+)
+
+// sendVerifyMail 发送邮箱验证邮件,令牌中记录待验证的邮箱,邮箱修改后旧的验证链接失效
+func sendVerifyMail(ctx context.Context, user *model.TUser) error {
+	tok, err := token.NewOneTime(ctx, token.PurposeVerifyMail, user.Username, user.Username+"\n"+user.Mail, verifyMailExpire)
+	if err != nil {
+		return err
+	}
+	link := config.Get().Mail.LinkBaseURL + "/verify-mail?token=" + url.QueryEscape(tok)
+	sendMailAsync(&mail.Message{
+		To:      user.Mail,
+		Subject: "SnapLink 邮箱验证",
+		Body:    "您好 " + user.Username + ",\n\n请在 24 小时内打开以下链接完成邮箱验证:\n" + link + "\n\n如果这不是您的操作,请忽略本邮件。",
+	})
+	return nil
+}
+
+// sendMailAsync 在后台发送邮件,SMTP 较慢时不阻塞请求
+func sendMailAsync(msg *mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mail.Default().Send(ctx, msg); err != nil {
+			logger.Error("发送邮件失败", logger.Err(err), logger.String("subject", msg.Subject))
+		}
+	}()
+}
+
+// SendVerifyMail 重新发送邮箱验证邮件
+// @Summary 重新发送邮箱验证邮件
+// @Description 向当前用户的邮箱发送验证邮件,每分钟最多发送一次
+// @Tags users
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Router /api/short-link/admin/v1/user/mail/verification [post]
+func (h *UsersHandler) SendVerifyMail(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	user, err := h.iDao.GetByUsername(ctx, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if user.MailVerified {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("邮箱已经验证")).ToJSON(c)
+		return
+	}
+	ok, err := cache.OneTimeToken().Cooldown(ctx, token.PurposeVerifyMail, user.Username, mailCooldown)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.FlowLimitError, serialize.WithMsg("发送过于频繁,请稍后重试")).ToJSON(c)
+		return
+	}
+	if err = sendVerifyMail(ctx, user); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// VerifyMail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌验证邮箱,令牌只能使用一次
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.VerifyMailRequest true "令牌"
+// @Router /api/short-link/admin/v1/user/mail/verify [post]
+func (h *UsersHandler) VerifyMail(c *gin.Context) {
+	form := new(types.VerifyMailRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	value, err := token.ConsumeOneTime(ctx, token.PurposeVerifyMail, form.Token)
+	if err != nil {
+		responseOneTimeError(c, err)
+		return
+	}
+	username, address, _ := strings.Cut(value, "\n")
+	ok, err := h.iDao.SetMailVerified(ctx, username, address)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("邮箱已经修改,请重新验证")).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// RequestPasswordReset 申请重置密码
+// @Summary 申请重置密码
+// @Description 向用户的邮箱发送重置密码邮件;无论用户是否存在都返回成功,避免泄露用户是否存在
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.PasswordResetRequest true "用户名"
+// @Router /api/short-link/admin/v1/user/password/reset/request [post]
+func (h *UsersHandler) RequestPasswordReset(c *gin.Context) {
+	form := new(types.PasswordResetRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	ok, err := cache.OneTimeToken().Cooldown(ctx, token.PurposeResetPassword, form.Username, mailCooldown)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponse(200).ToJSON(c)
+		return
+	}
+	user, err := h.iDao.GetByUsername(ctx, form.Username)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		serialize.NewResponse(200).ToJSON(c)
+		return
+	}
+	if user.Mail == "" {
+		serialize.NewResponse(200).ToJSON(c)
+		return
+	}
+	tok, err := token.NewOneTime(ctx, token.PurposeResetPassword, user.Username, user.Username, resetPasswordExpire)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	link := config.Get().Mail.LinkBaseURL + "/reset-password?token=" + url.QueryEscape(tok)
+	sendMailAsync(&mail.Message{
+		To:      user.Mail,
+		Subject: "SnapLink 重置密码",
+		Body:    "您好 " + user.Username + ",\n\n请在 30 分钟内打开以下链接重置密码:\n" + link + "\n\n如果这不是您的操作,请忽略本邮件,您的密码不会被修改。",
+	})
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置密码邮件中的令牌设置新密码,令牌只能使用一次;重置后用户在全部设备上的登录失效
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.ResetPasswordRequest true "令牌与新密码"
+// @Router /api/short-link/admin/v1/user/password/reset [post]
+func (h *UsersHandler) ResetPassword(c *gin.Context) {
+	form := new(types.ResetPasswordRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !utils.InvalidCharCheck(form.Password) {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithErr(errors.New("password format error"))).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	username, err := token.ConsumeOneTime(ctx, token.PurposeResetPassword, form.Token)
+	if err != nil {
+		responseOneTimeError(c, err)
+		return
+	}
+	if err = h.changePassword(ctx, username, form.Password); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	logger.Info("用户通过邮件重置密码", logger.String("audit", "password_reset"),
+		logger.String("username", username), logger.String("ip", c.ClientIP()), middleware.GCtxRequestIDField(c))
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// changePassword 修改密码并吊销用户的全部登录令牌,同时解除登录锁定
+func (h *UsersHandler) changePassword(ctx context.Context, username, password string) error {
+	if err := h.iDao.Update(ctx, &model.TUser{Username: username, Password: utils.Encrypt(password)}); err != nil {
+		return err
+	}
+	if err := token.RevokeAll(ctx, username); err != nil {
+		return err
+	}
+	return cache.LoginGuard().Unlock(ctx, username)
+}
+
+func responseOneTimeError(c *gin.Context, err error) {
+	if errors.Is(err, token.ErrInvalidToken) {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("链接无效或已过期")).ToJSON(c)
+		return
+	}
+	serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+}
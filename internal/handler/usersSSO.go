@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/config"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/sso"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/internal/utils"
+	"SnapLink/pkg/serialize"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// ssoStateExpire 授权请求的有效期,需要在该时间内完成身份提供方的登录
+	ssoStateExpire = 10 * time.Minute
+	// ssoUsernameAttempts 自动创建账号时用户名冲突的重试次数
+	ssoUsernameAttempts = 5
+	// ssoStateCookie 保存 state 摘要的 cookie,回调时校验,保证回调与发起授权的是同一个浏览器
+	ssoStateCookie = "sso_state"
+)
+
+// ssoUsernameInvalidChar 用户名中不允许的字符,与注册时的校验一致
+var ssoUsernameInvalidChar = regexp.MustCompile(`[^\w]`)
+
+// SSOAuthorize 发起单点登录
+// @Summary 发起单点登录
+// @Description 返回身份提供方的授权地址,前端跳转到该地址登录,身份提供方回调前端后由前端调用单点登录回调接口
+// @Tags users
+// @Produce application/json
+// @Success 200 {object} types.SSOAuthorizeRespond{}
+// @Router /api/short-link/admin/v1/user/sso/authorize [get]
+func (h *UsersHandler) SSOAuthorize(c *gin.Context) {
+	h.ssoAuthorize(c, "")
+}
+
+// SSOLinkAuthorize 发起关联外部身份
+// @Summary 发起关联外部身份
+// @Description 返回身份提供方的授权地址,回调完成后身份关联到当前用户,之后可以通过单点登录登录当前用户
+// @Tags users
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Success 200 {object} types.SSOAuthorizeRespond{}
+// @Router /api/short-link/admin/v1/user/sso/link [get]
+func (h *UsersHandler) SSOLinkAuthorize(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	h.ssoAuthorize(c, claims.UID)
+}
+
+// ssoAuthorize 生成 state, nonce 与 PKCE 的 code_verifier 并返回授权地址,username 不为空时为关联身份
+func (h *UsersHandler) ssoAuthorize(c *gin.Context, username string) {
+	client := sso.Default()
+	if client == nil {
+		serialize.NewResponseWithErrCode(ecode.SSODisabledError).ToJSON(c)
+		return
+	}
+	state, err := randomHex(24)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	nonce, err := randomHex(24)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	s := &cache.SSOState{Nonce: nonce, Verifier: oauth2.GenerateVerifier(), Username: username}
+	authURL, err := client.AuthCodeURL(state, s.Nonce, s.Verifier)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.RemoteError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = cache.SSOStates().Save(middleware.WrapCtx(c), state, s, ssoStateExpire); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	setSSOStateCookie(c, ssoStateDigest(state), int(ssoStateExpire.Seconds()))
+	serialize.NewResponse(200, serialize.WithData(types.SSOAuthorizeRespond{AuthURL: authURL})).ToJSON(c)
+}
+
+// SSOCallback 单点登录回调
+// @Summary 单点登录回调
+// @Description 使用身份提供方返回的 code 与 state 完成登录,需要携带发起授权时设置的 sso_state cookie。身份未关联时,根据配置关联用户名相同的账号或自动创建账号;
+// @Description 启用两步验证时返回 types.LoginChallengeRespond;由关联外部身份发起时返回 types.SSOLinkRespond
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.SSOCallbackRequest true "code 与 state"
+// @Success 200 {object} token.Pair{}
+// @Router /api/short-link/admin/v1/user/sso/callback [post]
+func (h *UsersHandler) SSOCallback(c *gin.Context) {
+	client := sso.Default()
+	if client == nil {
+		serialize.NewResponseWithErrCode(ecode.SSODisabledError).ToJSON(c)
+		return
+	}
+	form := new(types.SSOCallbackRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	//1. 校验 state 由当前浏览器发起,防止攻击者将自己的授权结果交给受害者完成登录或关联
+	digest, _ := c.Cookie(ssoStateCookie)
+	setSSOStateCookie(c, "", -1)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(ssoStateDigest(form.State))) != 1 {
+		serialize.NewResponseWithErrCode(ecode.SSOLoginError, serialize.WithErr(errors.New("state is not bound to this browser"))).ToJSON(c)
+		return
+	}
+	//2. 取出授权请求,state 只能使用一次
+	ctx := middleware.WrapCtx(c)
+	state, ok, err := cache.SSOStates().Consume(ctx, form.State)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.SSOLoginError, serialize.WithErr(errors.New("state is invalid or expired"))).ToJSON(c)
+		return
+	}
+	//3. 换取令牌并校验 ID Token
+	identity, err := client.Exchange(ctx, form.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		if errors.Is(err, sso.ErrInvalidCode) || errors.Is(err, sso.ErrInvalidIDToken) {
+			logger.Warn("单点登录校验失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+			serialize.NewResponseWithErrCode(ecode.SSOLoginError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.RemoteError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	//4. 关联身份到发起关联的用户
+	if state.Username != "" {
+		h.ssoLink(c, identity, state.Username)
+		return
+	}
+	//5. 查找身份关联的账号,未关联时关联或创建账号
+	user, err := h.ssoUser(c, identity)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 身份关联的账号已被删除
+			serialize.NewResponseWithErrCode(ecode.UserNotExistError).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	//6. 启用两步验证时返回挑战令牌
+	if user.TOTPEnabled {
+		challenge, err := newLoginChallenge(ctx, user.Username)
+		if err != nil {
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			return
+		}
+		serialize.NewResponse(200, serialize.WithData(challenge)).ToJSON(c)
+		return
+	}
+	// 登录完成后才清除失败次数,启用两步验证时由两步验证登录清除
+	if err = cache.LoginGuard().Succeed(ctx, user.Username); err != nil {
+		logger.Warn("清除登录失败次数失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
+	pair, err := token.Issue(ctx, user.Username, user.GetRole())
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(errors.Wrap(err, "generate token error"))).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(pair)).ToJSON(c)
+}
+
+// ssoStateDigest state 的摘要,cookie 中不保存 state 本身
+func ssoStateDigest(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// setSSOStateCookie 设置 state 摘要的 cookie,只对单点登录的接口可见,maxAge 小于 0 时删除
+func setSSOStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, value, maxAge, path.Dir(c.Request.URL.Path), "", secure, true)
+}
+
+// ssoLink 将身份关联到 username,身份已关联其他账号时返回冲突
+func (h *UsersHandler) ssoLink(c *gin.Context, identity *sso.Identity, username string) {
+	ctx := middleware.WrapCtx(c)
+	err := linkIdentity(ctx, identity, username)
+	if err != nil {
+		if errors.Is(err, errIdentityLinked) {
+			serialize.NewResponseWithErrCode(ecode.SSOLinkedError).ToJSON(c)
+			return
+		}
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	logger.Info("关联外部身份", logger.String("audit", "sso_link"),
+		logger.String("username", username), logger.String("issuer", identity.Issuer),
+		logger.String("subject", identity.Subject), middleware.GCtxRequestIDField(c))
+	serialize.NewResponse(200, serialize.WithData(types.SSOLinkRespond{Username: username, Issuer: identity.Issuer})).ToJSON(c)
+}
+
+// ssoUser 身份关联的账号;未关联时,开启 LinkByUsername 则关联用户名相同且邮箱一致的账号,否则自动创建账号
+func (h *UsersHandler) ssoUser(c *gin.Context, identity *sso.Identity) (*model.TUser, error) {
+	ctx := middleware.WrapCtx(c)
+	linked, err := dao.UserIdentityDao().GetBySubject(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return h.iDao.GetByUsername(ctx, linked.Username)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if config.Get().Oidc.LinkByUsername && identity.Username != "" {
+		user, err := h.iDao.GetByUsername(ctx, identity.Username)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// 用户名可能由身份提供方的用户自行设置,只凭用户名关联会导致账号被接管,
+		// 还需要身份提供方验证过的邮箱与账号已验证的邮箱一致
+		if user != nil && ssoMailMatches(identity, user) {
+			if err = linkIdentity(ctx, identity, user.Username); err != nil {
+				return nil, err
+			}
+			logger.Info("按用户名关联外部身份", logger.String("audit", "sso_link"),
+				logger.String("username", user.Username), logger.String("issuer", identity.Issuer),
+				logger.String("subject", identity.Subject), middleware.GCtxRequestIDField(c))
+			return user, nil
+		}
+		if user != nil {
+			logger.Warn("用户名相同但邮箱不一致,不关联已有账号", logger.String("audit", "sso_link_rejected"),
+				logger.String("username", user.Username), logger.String("issuer", identity.Issuer),
+				logger.String("subject", identity.Subject), middleware.GCtxRequestIDField(c))
+		}
+	}
+
+	user, err := h.provisionSSOUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err = linkIdentity(ctx, identity, user.Username); err != nil {
+		return nil, err
+	}
+	logger.Info("单点登录自动创建账号", logger.String("audit", "sso_provision"),
+		logger.String("username", user.Username), logger.String("issuer", identity.Issuer),
+		logger.String("subject", identity.Subject), middleware.GCtxRequestIDField(c))
+	return user, nil
+}
+
+// ssoMailMatches 身份提供方验证过的邮箱与账号已验证的邮箱是否一致
+func ssoMailMatches(identity *sso.Identity, user *model.TUser) bool {
+	if !identity.EmailVerified || !user.MailVerified {
+		return false
+	}
+	mail := utils.NormalizeMail(identity.Email)
+	return mail != "" && mail == utils.NormalizeMail(user.Mail)
+}
+
+var errIdentityLinked = errors.New("identity is linked to another user")
+
+// linkIdentity 关联身份,已关联到 username 时直接返回,已关联其他账号时返回 errIdentityLinked
+func linkIdentity(ctx context.Context, identity *sso.Identity, username string) error {
+	err := dao.UserIdentityDao().Create(ctx, &model.UserIdentity{
+		Issuer:   identity.Issuer,
+		Subject:  identity.Subject,
+		Username: username,
+		Mail:     identity.Email,
+	})
+	if err == nil {
+		return nil
+	}
+	if !dao.ErrDuplicateEntry.Is(err) {
+		return err
+	}
+	linked, err := dao.UserIdentityDao().GetBySubject(ctx, identity.Issuer, identity.Subject)
+	if err != nil {
+		return err
+	}
+	if linked.Username != username {
+		return errIdentityLinked
+	}
+	return nil
+}
+
+// provisionSSOUser 根据身份创建账号,用户名冲突时添加随机后缀;密码随机生成,需要时通过邮箱重置
+func (h *UsersHandler) provisionSSOUser(ctx context.Context, identity *sso.Identity) (*model.TUser, error) {
+	base := ssoUsername(identity)
+	u := &model.TUser{
+		Username: base,
+		Password: utils.Encrypt(uuid.NewString()),
+		Role:     model.RoleUser,
+	}
+	// 只使用身份提供方验证过的邮箱
+	if identity.EmailVerified && utils.IsEmail(identity.Email) {
+		u.Mail = identity.Email
+		u.MailVerified = true
+	}
+	for i := 0; i < ssoUsernameAttempts; i++ {
+		if i > 0 {
+			suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return nil, errors.Wrap(err, "generate username suffix failed")
+			}
+			u.Username = fmt.Sprintf("%s_%04d", truncate(base, 6), suffix.Int64())
+		}
+		u.RealName = ssoRealName(identity, u.Username)
+		has, err := h.iDao.HasUsername(ctx, u.Username)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			continue
+		}
+		err = h.iDao.Create(ctx, u)
+		if err == nil {
+			if err = cache.BFCache().BFAdd(ctx, "username", u.Username); err != nil {
+				logger.Warn("用户名加入布隆过滤器失败", logger.Err(err), logger.String("username", u.Username))
+			}
+			return u, nil
+		}
+		// 邮箱已被其他账号使用时不保存邮箱,用户可以之后关联已有账号
+		if errors.Is(err, dao.ErrMailExist) {
+			u.Mail, u.MailVerified = "", false
+			continue
+		}
+		if !dao.ErrDuplicateEntry.Is(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.Errorf("no available username for sso user, base: %s", base)
+}
+
+// ssoUsername 由身份生成用户名,只保留字母、数字与下划线,长度与注册时的限制一致
+func ssoUsername(identity *sso.Identity) string {
+	name := identity.Username
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	name = truncate(ssoUsernameInvalidChar.ReplaceAllString(name, "_"), 11)
+	if len(name) < 3 {
+		return "sso"
+	}
+	return name
+}
+
+// ssoRealName 由身份生成真实姓名,没有姓名时使用用户名
+func ssoRealName(identity *sso.Identity, username string) string {
+	name := []rune(strings.TrimSpace(identity.Name))
+	if len(name) < 2 {
+		return username
+	}
+	if len(name) > 20 {
+		name = name[:20]
+	}
+	return string(name)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "generate random string failed")
+	}
+	return hex.EncodeToString(buf), nil
+}
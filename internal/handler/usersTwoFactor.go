@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/config"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/internal/types"
+	"SnapLink/pkg/serialize"
+	"SnapLink/pkg/totp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+const (
+	// twoFactorIssuer 验证器应用中显示的服务名称
+	twoFactorIssuer = "SnapLink"
+	// twoFactorChallengeExpire 登录挑战令牌的有效期
+	twoFactorChallengeExpire = 5 * time.Minute
+	// twoFactorMaxAttempts 每个挑战令牌最多尝试的次数
+	twoFactorMaxAttempts = 5
+	// twoFactorEnrollExpire 未确认的密钥的有效期
+	twoFactorEnrollExpire = 10 * time.Minute
+	// twoFactorSkew 允许的时钟误差步数
+	twoFactorSkew = 1
+	// recoveryCodeCount 恢复码数量
+	recoveryCodeCount = 10
+)
+
+// newLoginChallenge 签发两步验证的挑战令牌
+func newLoginChallenge(ctx context.Context, username string) (*types.LoginChallengeRespond, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.Wrap(err, "generate 2fa challenge failed")
+	}
+	id := hex.EncodeToString(buf)
+	if err := cache.TwoFactor().CreateChallenge(ctx, id, username, twoFactorChallengeExpire); err != nil {
+		return nil, err
+	}
+	return &types.LoginChallengeRespond{
+		TwoFactorRequired: true,
+		ChallengeToken:    id,
+		ExpiresIn:         int64(twoFactorChallengeExpire.Seconds()),
+	}, nil
+}
+
+// LoginTwoFactor 两步验证登录
+// @Summary 两步验证登录
+// @Description 使用登录返回的挑战令牌与验证码或恢复码完成登录,挑战令牌最多尝试 5 次
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param data body types.TwoFactorLoginRequest true "挑战令牌与验证码"
+// @Success 200 {object} token.Pair{}
+// @Router /api/short-link/admin/v1/user/login/2fa [post]
+func (h *UsersHandler) LoginTwoFactor(c *gin.Context) {
+	form := new(types.TwoFactorLoginRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	ctx := middleware.WrapCtx(c)
+	username, ok, err := cache.TwoFactor().AttemptChallenge(ctx, form.ChallengeToken, twoFactorMaxAttempts)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+		return
+	}
+	ip := c.ClientIP()
+	wait, err := cache.LoginGuard().Check(ctx, username, ip)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if wait > 0 {
+		responseLoginLocked(c, wait)
+		return
+	}
+	user, err := h.iDao.GetByUsername(ctx, username)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	ok, err = h.verifyTwoFactor(ctx, user, form.Code)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		h.loginFailed(c, username, ip)
+		return
+	}
+	if err = cache.TwoFactor().DeleteChallenge(ctx, form.ChallengeToken); err != nil {
+		logger.Warn("删除两步验证挑战令牌失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
+	if err = cache.LoginGuard().Succeed(ctx, username); err != nil {
+		logger.Warn("清除登录失败次数失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+	}
+	pair, err := token.Issue(ctx, user.Username, user.GetRole())
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(errors.Wrap(err, "generate token error"))).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(pair)).ToJSON(c)
+}
+
+// EnrollTwoFactor 开始启用两步验证
+// @Summary 开始启用两步验证
+// @Description 生成两步验证密钥,用户在验证器应用中添加后使用验证码确认,10 分钟内未确认时失效
+// @Tags users
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Success 200 {object} types.TwoFactorEnrollRespond{}
+// @Router /api/short-link/admin/v1/user/2fa/enroll [post]
+func (h *UsersHandler) EnrollTwoFactor(c *gin.Context) {
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	user, err := h.iDao.GetByUsername(ctx, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if user.TOTPEnabled {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("已经启用两步验证")).ToJSON(c)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = cache.TwoFactor().SavePendingSecret(ctx, user.Username, secret, twoFactorEnrollExpire); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	res := &types.TwoFactorEnrollRespond{Secret: secret, URI: totp.URI(twoFactorIssuer, user.Username, secret)}
+	serialize.NewResponse(200, serialize.WithData(res)).ToJSON(c)
+}
+
+// ActivateTwoFactor 确认启用两步验证
+// @Summary 确认启用两步验证
+// @Description 使用验证器应用中的验证码确认启用两步验证,返回的恢复码只显示一次
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} types.RecoveryCodesRespond{}
+// @Router /api/short-link/admin/v1/user/2fa/activate [post]
+func (h *UsersHandler) ActivateTwoFactor(c *gin.Context) {
+	form := new(types.TwoFactorCodeRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	secret, err := cache.TwoFactor().PendingSecret(ctx, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if secret == "" {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("两步验证密钥已过期,请重新开始")).ToJSON(c)
+		return
+	}
+	ok, err := validateTOTP(ctx, claims.UID, secret, form.Code)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("验证码错误")).ToJSON(c)
+		return
+	}
+	codes, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = h.iDao.UpdateTwoFactor(ctx, claims.UID, true, secret, hashed); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	_ = cache.TwoFactor().DeletePendingSecret(ctx, claims.UID)
+	logger.Info("用户启用两步验证", logger.String("audit", "2fa_enabled"),
+		logger.String("username", claims.UID), middleware.GCtxRequestIDField(c))
+	serialize.NewResponse(200, serialize.WithData(&types.RecoveryCodesRespond{RecoveryCodes: codes})).ToJSON(c)
+}
+
+// DisableTwoFactor 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 使用验证码或恢复码关闭两步验证,必须启用两步验证的角色不能关闭
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.TwoFactorCodeRequest true "验证码"
+// @Router /api/short-link/admin/v1/user/2fa [delete]
+func (h *UsersHandler) DisableTwoFactor(c *gin.Context) {
+	form := new(types.TwoFactorCodeRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	user, ok := h.checkTwoFactorCode(c, form.Code)
+	if !ok {
+		return
+	}
+	if config.Get().Login.TwoFactorRequired(user.GetRole()) {
+		serialize.NewResponseWithErrCode(ecode.AccessForbiddenError, serialize.WithMsg("当前角色必须启用两步验证")).ToJSON(c)
+		return
+	}
+	if err := h.iDao.UpdateTwoFactor(middleware.WrapCtx(c), user.Username, false, "", ""); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	logger.Info("用户关闭两步验证", logger.String("audit", "2fa_disabled"),
+		logger.String("username", user.Username), middleware.GCtxRequestIDField(c))
+	serialize.NewResponse(200).ToJSON(c)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 使用验证码或恢复码重新生成恢复码,原有的恢复码全部失效
+// @Tags users
+// @Accept application/json
+// @Produce application/json
+// @Param Authorization header string true "token"
+// @Param data body types.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} types.RecoveryCodesRespond{}
+// @Router /api/short-link/admin/v1/user/2fa/recovery-codes [post]
+func (h *UsersHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	form := new(types.TwoFactorCodeRequest)
+	if err := c.ShouldBindJSON(form); err != nil {
+		serialize.NewResponseWithErrCode(ecode.RequestParamError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	user, ok := h.checkTwoFactorCode(c, form.Code)
+	if !ok {
+		return
+	}
+	codes, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	if err = h.iDao.UpdateTwoFactor(middleware.WrapCtx(c), user.Username, true, user.TOTPSecret, hashed); err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return
+	}
+	serialize.NewResponse(200, serialize.WithData(&types.RecoveryCodesRespond{RecoveryCodes: codes})).ToJSON(c)
+}
+
+// checkTwoFactorCode 校验当前用户的验证码,校验失败时已经写入响应
+func (h *UsersHandler) checkTwoFactorCode(c *gin.Context, code string) (*model.TUser, bool) {
+	claims, _ := token.FromContext(c)
+	ctx := middleware.WrapCtx(c)
+	user, err := h.iDao.GetByUsername(ctx, claims.UID)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return nil, false
+	}
+	if !user.TOTPEnabled {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("未启用两步验证")).ToJSON(c)
+		return nil, false
+	}
+	ok, err := h.verifyTwoFactor(ctx, user, code)
+	if err != nil {
+		serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+		return nil, false
+	}
+	if !ok {
+		serialize.NewResponseWithErrCode(ecode.ClientError, serialize.WithMsg("验证码错误")).ToJSON(c)
+		return nil, false
+	}
+	return user, true
+}
+
+// verifyTwoFactor 校验 6 位验证码或恢复码,恢复码使用后失效
+func (h *UsersHandler) verifyTwoFactor(ctx context.Context, user *model.TUser, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return validateTOTP(ctx, user.Username, user.TOTPSecret, code)
+	}
+	if user.RecoveryCodes == "" {
+		return false, nil
+	}
+	hashed := hashRecoveryCode(code)
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, hash := range hashes {
+		if hash != hashed {
+			continue
+		}
+		remaining := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		// 以原有的恢复码为条件更新,并发使用同一个恢复码时只有一次成功
+		return h.iDao.ReplaceRecoveryCodes(ctx, user.Username, user.RecoveryCodes, remaining)
+	}
+	return false, nil
+}
+
+// validateTOTP 校验 6 位验证码,同一验证码只能使用一次
+func validateTOTP(ctx context.Context, username, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, code, time.Now(), twoFactorSkew)
+	if !ok {
+		return false, nil
+	}
+	return cache.TwoFactor().UseStep(ctx, username, step, time.Duration(totp.Period*(2*twoFactorSkew+2))*time.Second)
+}
+
+// generateRecoveryCodes 生成恢复码,返回明文与以逗号分隔的 sha256
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 5)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", errors.Wrap(err, "generate recovery code failed")
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// hashRecoveryCode 恢复码不区分大小写,忽略分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package initial
+
+import (
+	"context"
+	"os"
+
+	"SnapLink/internal/cache"
+	"SnapLink/internal/config"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/model"
+	"SnapLink/internal/utils"
+
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// BootstrapAdmin 根据配置创建初始管理员,账号已存在时只将其设为管理员,不修改密码
+func BootstrapAdmin() {
+	cfg := config.Get().Admin
+	if cfg.Username == "" {
+		return
+	}
+	ctx := context.Background()
+	_, err := dao.UserDao().GetByUsername(ctx, cfg.Username)
+	switch {
+	case err == nil:
+		if err = dao.UserDao().UpdateRole(ctx, cfg.Username, model.RoleAdmin); err != nil {
+			panic(errors.Wrap(err, "set admin role failed"))
+		}
+		logger.Info("初始管理员已存在", logger.String("username", cfg.Username))
+		return
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		panic(errors.Wrap(err, "get admin failed"))
+	}
+
+	password := cfg.Password
+	if env := os.Getenv(cfg.PasswordEnv); cfg.PasswordEnv != "" && env != "" {
+		password = env
+	}
+	if len(password) < 6 {
+		panic("admin password is not configured or shorter than 6 characters")
+	}
+	u := &model.TUser{
+		Username: cfg.Username,
+		Password: utils.Encrypt(password),
+		RealName: cfg.Username,
+		Phone:    cfg.Phone,
+		Mail:     cfg.Mail,
+		Role:     model.RoleAdmin,
+	}
+	if err = dao.UserDao().Create(ctx, u); err != nil {
+		panic(errors.Wrap(err, "create admin failed"))
+	}
+	if err = cache.BFCache().BFAdd(ctx, "username", u.Username); err != nil {
+		panic(errors.Wrap(err, "add admin to bloom filter failed"))
+	}
+	logger.Info("已创建初始管理员", logger.String("username", cfg.Username))
+}
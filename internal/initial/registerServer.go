@@ -44,6 +44,18 @@ func RegisterServers() []app.IServer {
 	// creating cacheAsideService
 	cacheAsideService := service.NewCacheASideService()
 	servers = append(servers, cacheAsideService)
+
+	// creating recycleBinService
+	recycleBinService := service.NewRecycleBinService()
+	servers = append(servers, recycleBinService)
+
+	// creating groupDeleteService
+	groupDeleteService := service.NewGroupDeleteService()
+	servers = append(servers, groupDeleteService)
+
+	// creating groupStatsService
+	groupStatsService := service.NewGroupStatsService()
+	servers = append(servers, groupStatsService)
 	return servers
 }
 
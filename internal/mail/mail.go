@@ -0,0 +1,121 @@
+// Package mail 邮件发送
+// 生产环境使用 SMTP 发送,本地开发时将邮件写入文件或日志,不需要邮件服务器
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+// Message 邮件内容,Body 为纯文本
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送接口
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+var (
+	defaultSender Sender = NewFileSender("")
+	mu            sync.RWMutex
+)
+
+// Init 设置默认的邮件发送方式
+func Init(s Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultSender = s
+}
+
+// Default 默认的邮件发送方式,未初始化时写入日志
+func Default() Sender {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultSender
+}
+
+type smtpSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender 通过 SMTP 发送邮件,服务器支持时使用 STARTTLS,不支持 465 端口的隐式 TLS
+func NewSMTPSender(host string, port int, username, password, from string) Sender {
+	s := &smtpSender{addr: host + ":" + strconv.Itoa(port), from: from}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *smtpSender) Send(_ context.Context, msg *Message) error {
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, encode(s.from, msg)); err != nil {
+		return errors.Wrap(err, "send mail failed, to: "+msg.To)
+	}
+	return nil
+}
+
+type fileSender struct {
+	dir string
+}
+
+// NewFileSender 将邮件写入 dir 目录下的 .eml 文件,dir 为空时写入日志
+func NewFileSender(dir string) Sender {
+	return &fileSender{dir: dir}
+}
+
+func (s *fileSender) Send(_ context.Context, msg *Message) error {
+	if s.dir == "" {
+		logger.Info("邮件", logger.String("to", msg.To), logger.String("subject", msg.Subject), logger.String("body", msg.Body))
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return errors.Wrap(err, "create mail dir failed")
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To))
+	if err := os.WriteFile(filepath.Join(s.dir, name), encode("", msg), 0o644); err != nil {
+		return errors.Wrap(err, "write mail failed, to: "+msg.To)
+	}
+	return nil
+}
+
+// encode 生成 RFC 5322 格式的邮件
+func encode(from string, msg *Message) []byte {
+	var b strings.Builder
+	if from != "" {
+		b.WriteString("From: " + from + "\r\n")
+	}
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: =?UTF-8?B?" + base64Encode(msg.Subject) + "?=\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	body := base64Encode(msg.Body)
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return []byte(b.String())
+}
+
+func base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
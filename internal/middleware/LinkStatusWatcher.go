@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"SnapLink/internal/cache"
 	"SnapLink/internal/message_queue/rabbitmq"
 	"SnapLink/internal/model"
+	"SnapLink/pkg/userAgent"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -10,6 +12,9 @@ import (
 	"time"
 )
 
+// regionHeaders 反向代理或 CDN 写入的访问者地区,按顺序取第一个非空值
+var regionHeaders = []string{"X-Client-Region", "CF-IPCountry"}
+
 var weekDay = map[string]int{
 	"Monday":    0,
 	"Tuesday":   1,
@@ -27,9 +32,11 @@ func Watcher() gin.HandlerFunc {
 	}
 	return func(c *gin.Context) {
 		c.Next()
-		// 此处是用于监控短链接的访问情况，其成功访问的状态码为302
-		if c.Writer.Status() == 302 {
-			info := c.MustGet("info").(*model.Redirect)
+		// 此处是用于监控短链接的访问情况，跳转状态码由短链接的设置决定,任意 3xx 均视为成功访问
+		status := c.Writer.Status()
+		value, ok := c.Get("info")
+		if ok && status >= 300 && status < 400 {
+			info := value.(*model.Redirect)
 			header := c.Request.Header
 			ip := c.RemoteIP()
 			uid, err := c.Cookie("uid")
@@ -38,10 +45,30 @@ func Watcher() gin.HandlerFunc {
 
 				c.SetCookie("uid", uid, 3600, "/", "", false, false)
 			}
-			err = publisher.Publish("accessLog", rabbitmq.NewAccessLogMessage(*info, header, c.GetString("request_id"), ip, uid, time.Now().Format("2006-01-02 15:04:05")))
+			now := time.Now()
+			err = publisher.Publish("accessLog", rabbitmq.NewAccessLogMessage(*info, header, c.GetString("request_id"), ip, uid, now.Format("2006-01-02 15:04:05")))
 			if err != nil {
 				logger.Err(err)
 			}
+			// 维护短链接的累计与当日访问统计,用于列表展示与排序
+			if err = cache.ShortLinkStats().Record(c.Request.Context(), info.Gid, info.Uri, uid, ip); err != nil {
+				logger.Warn("记录短链接访问统计失败", logger.Err(err), logger.String("uri", info.Uri))
+			}
+			// 维护分组按小时与按天的访问统计,定期落库用于分组统计查询
+			device := userAgent.AutoParse(c.Request.UserAgent()).Device
+			if err = cache.GroupStats().Record(c.Request.Context(), info.Gid, info.Uri, uid, ip, accessRegion(c), device, now); err != nil {
+				logger.Warn("记录分组访问统计失败", logger.Err(err), logger.String("gid", info.Gid), logger.String("uri", info.Uri))
+			}
+		}
+	}
+}
+
+// accessRegion 访问者所在地区,未部署在提供地区信息的代理之后时为空
+func accessRegion(c *gin.Context) string {
+	for _, header := range regionHeaders {
+		if region := c.GetHeader(header); region != "" {
+			return region
 		}
 	}
+	return ""
 }
@@ -1 +1,64 @@
 package middleware
+
+import (
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/token"
+	"SnapLink/pkg/serialize"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+// Auth 校验登录签发的访问令牌或个人访问令牌,令牌被吊销或所在的令牌族失效时拒绝访问
+// scopes 为接口允许个人访问令牌调用时需要的权限范围,为空时只接受登录签发的访问令牌
+func Auth(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorization := c.GetHeader("Authorization")
+		if len(authorization) < 8 || !strings.EqualFold(authorization[:7], "Bearer ") {
+			serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+			c.Abort()
+			return
+		}
+		tokenString := authorization[7:]
+		var claims *token.Claims
+		var err error
+		if token.IsPAT(tokenString) {
+			claims, err = token.ParsePAT(middleware.WrapCtx(c), tokenString, c.ClientIP())
+		} else {
+			claims, err = token.Parse(middleware.WrapCtx(c), tokenString, token.TypeAccess)
+		}
+		if err != nil {
+			if !errors.Is(err, token.ErrInvalidToken) && !errors.Is(err, token.ErrTokenRevoked) {
+				logger.Error("校验访问令牌失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+				serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+				c.Abort()
+				return
+			}
+			serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+			c.Abort()
+			return
+		}
+		if claims.Type == token.TypePAT && !hasScopes(claims, scopes) {
+			serialize.NewResponseWithErrCode(ecode.AccessForbiddenError, serialize.WithMsg("令牌没有访问该接口的权限")).ToJSON(c)
+			c.Abort()
+			return
+		}
+		token.SetClaims(c, claims)
+		c.Next()
+	}
+}
+
+func hasScopes(claims *token.Claims, scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !claims.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
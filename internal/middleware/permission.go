@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"SnapLink/internal/config"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/ecode"
+	"SnapLink/internal/model"
+	"SnapLink/internal/token"
+	"SnapLink/pkg/serialize"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhufuyi/sponge/pkg/gin/middleware"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+// RequirePermission 要求当前用户拥有权限,必须在 Auth 之后使用
+// 角色以数据库中的为准,令牌中的角色可能已经过时,修改角色后立即生效
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := token.FromContext(c)
+		if !ok {
+			serialize.NewResponseWithErrCode(ecode.TokenInvalidError).ToJSON(c)
+			c.Abort()
+			return
+		}
+		user, err := dao.UserDao().GetByUsername(middleware.WrapCtx(c), claims.UID)
+		if err != nil {
+			logger.Error("查询用户角色失败", logger.Err(err), middleware.GCtxRequestIDField(c))
+			serialize.NewResponseWithErrCode(ecode.ServiceError, serialize.WithErr(err)).ToJSON(c)
+			c.Abort()
+			return
+		}
+		if !model.RoleHasPermission(user.GetRole(), permission) {
+			serialize.NewResponseWithErrCode(ecode.AccessForbiddenError).ToJSON(c)
+			c.Abort()
+			return
+		}
+		// 特权角色必须启用两步验证后才能使用角色的权限
+		if config.Get().Login.TwoFactorRequired(user.GetRole()) && !user.TOTPEnabled {
+			serialize.NewResponseWithErrCode(ecode.AccessForbiddenError, serialize.WithMsg("请先启用两步验证")).ToJSON(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 短链接的创建类型
+const (
+	CreatedTypeAPI     = 0
+	CreatedTypeConsole = 1
+)
+
+// AccessToken 个人访问令牌,供 API 客户端使用
+// 按创建人分表,令牌明文中携带分表序号,鉴权时只需要访问一张分表;数据库中只保存令牌的 sha256
+type AccessToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"-"`
+	Name       string     `gorm:"column:name;type:varchar(64);NOT NULL;comment:'令牌名称'" json:"name"`
+	CUsername  string     `gorm:"column:c_username;type:varchar(50);NOT NULL;index:idx_username;comment:'创建人'" json:"-"`
+	Prefix     string     `gorm:"column:prefix;type:varchar(16);NOT NULL;comment:'令牌明文的前缀,用于辨认令牌'" json:"prefix"`
+	TokenHash  string     `gorm:"column:token_hash;type:char(64);NOT NULL;uniqueIndex:idx_token_hash;comment:'令牌的 sha256'" json:"-"`
+	Scopes     string     `gorm:"column:scopes;type:varchar(255);NOT NULL;comment:'权限范围,以逗号分隔'" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at;comment:'过期时间,为空时永不过期'" json:"expiresAt"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at;comment:'最近使用时间'" json:"lastUsedAt"`
+	LastUsedIP string     `gorm:"column:last_used_ip;type:varchar(64);NOT NULL;default:'';comment:'最近使用的 ip'" json:"lastUsedIp"`
+}
+
+// TName 根据创建人进行分表
+func (t AccessToken) TName() string {
+	return AccessTokenTName(t.Shard())
+}
+
+// Shard 令牌所在分表的序号
+func (t AccessToken) Shard() int {
+	return int(hash(t.CUsername) % AccessTokenShardingNum)
+}
+
+// AccessTokenTName 分表序号对应的表名
+func AccessTokenTName(shard int) string {
+	return fmt.Sprintf("%s-%d", AccessTokenPrefix, shard)
+}
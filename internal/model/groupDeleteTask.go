@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 删除分组时对分组内短链接的处理策略
+const (
+	GroupDeleteStrategyMove    = "move"
+	GroupDeleteStrategyDisable = "disable"
+	GroupDeleteStrategyDelete  = "delete"
+)
+
+// 分组删除任务的状态
+const (
+	GroupDeleteTaskRunning = "running"
+	GroupDeleteTaskDone    = "done"
+)
+
+// GroupDeleteTask 分组删除任务
+// 分组删除后,由后台任务按游标分批处理分组内的短链接,Cursor 记录已处理到的短链接 id,任务中断后可以继续执行
+type GroupDeleteTask struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Gid       string    `gorm:"column:gid;NOT NULL;comment:'被删除的分组 id';uniqueIndex:idx_gid" json:"gid"`
+	CUsername string    `gorm:"column:c_username;type:varchar(50);NOT NULL;comment:'分组创建人'" json:"-"`
+	Strategy  string    `gorm:"column:strategy;type:varchar(20);NOT NULL;comment:'短链接处理策略'" json:"strategy"`
+	TargetGid string    `gorm:"column:target_gid;comment:'短链接移动到的分组 id'" json:"targetGid"`
+	Status    string    `gorm:"column:status;type:varchar(20);NOT NULL;comment:'任务状态';index:idx_status" json:"status"`
+	Cursor    uint      `gorm:"column:cursor;NOT NULL;default:0;comment:'已处理到的短链接 id'" json:"-"`
+	Processed int       `gorm:"column:processed;NOT NULL;default:0;comment:'已处理的短链接数量'" json:"processed"`
+	LastError string    `gorm:"column:last_error;type:varchar(255);comment:'最近一次失败的原因'" json:"lastError"`
+}
+
+// TName 根据分组创建人进行分表,与 ShortLinkGroup 分表规则一致
+func (t GroupDeleteTask) TName() string {
+	id := hash(t.CUsername)
+	return fmt.Sprintf("%s-%d", GroupDeleteTaskPrefix, id%GroupDeleteTaskShardingNum)
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 分组成员的角色,分组的创建人即为 owner,不记录在成员表中
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleEditor = "editor"
+	GroupRoleViewer = "viewer"
+)
+
+// 分组成员的状态
+const (
+	GroupMemberPending  = "pending"
+	GroupMemberAccepted = "accepted"
+)
+
+// groupRoleLevels 角色的权限等级,等级高的角色拥有等级低的角色的全部权限
+var groupRoleLevels = map[string]int{
+	GroupRoleViewer: 1,
+	GroupRoleEditor: 2,
+	GroupRoleOwner:  3,
+}
+
+// GroupRoleCovers 判断角色 role 是否拥有 required 角色的权限
+func GroupRoleCovers(role, required string) bool {
+	level, ok := groupRoleLevels[role]
+	return ok && level >= groupRoleLevels[required]
+}
+
+// GroupMember 共享分组的成员
+// 按成员用户名分表,成员查询自己加入的分组与鉴权时只需要访问一张分表
+type GroupMember struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+	Gid       string    `gorm:"column:gid;NOT NULL;comment:'分组 id';uniqueIndex:idx_gid_username" json:"gid"`
+	Username  string    `gorm:"column:username;type:varchar(50);NOT NULL;comment:'成员用户名';uniqueIndex:idx_gid_username;index:idx_username" json:"username"`
+	Role      string    `gorm:"column:role;type:varchar(20);NOT NULL;comment:'成员角色'" json:"role"`
+	Status    string    `gorm:"column:status;type:varchar(20);NOT NULL;comment:'邀请状态'" json:"status"`
+	Inviter   string    `gorm:"column:inviter;type:varchar(50);NOT NULL;comment:'邀请人'" json:"inviter"`
+}
+
+// TName 根据成员用户名进行分表
+func (m GroupMember) TName() string {
+	id := hash(m.Username)
+	return fmt.Sprintf("%s-%d", GroupMemberPrefix, id%GroupMemberShardingNum)
+}
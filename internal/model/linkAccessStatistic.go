@@ -36,18 +36,26 @@ type LinkAccessStatisticDay struct {
 }
 
 // LinkAccessStatisticBasic 用于存储基础数据,不存储详细数据
+// 每行为分组内一个短链接一小时或一整天的统计,uri 为空的行为整个分组的统计,hour 为 StatisticWholeDay 的行为整天的统计
+// 整天与整个分组的 uv/uip 单独去重计数,不能由小时或短链接的数据累加得到
 type LinkAccessStatisticBasic struct {
 	gorm.Model `json:"-"`
-	URI        string `gorm:"column:uri;type:varchar(255);comment:'访问链接';uniqueIndex:idx_query;index:idx_uri_date" json:"uri"`
-	Pv         int64  `gorm:"column:pv;type:bigint(20)" json:"pv"`
-	Uv         int64  `gorm:"column:uv;type:bigint(20)" json:"uv"`
-	Uip        int64  `gorm:"column:uip;type:bigint(20)" json:"uip"`
-	Gid        string `gorm:"column:gid;comment:'组id';not null;index:idx_gid_uri" json:"gid"`
-	Date       string `gorm:"column:date;type:date;index:idx_uri_date" json:"date"`
-	Hour       int    `gorm:"column:hour;" json:"hour"`
+	URI        string         `gorm:"column:uri;type:varchar(255);comment:'访问链接';uniqueIndex:idx_query,priority:2" json:"uri"`
+	Pv         int64          `gorm:"column:pv;type:bigint(20)" json:"pv"`
+	Uv         int64          `gorm:"column:uv;type:bigint(20)" json:"uv"`
+	Uip        int64          `gorm:"column:uip;type:bigint(20)" json:"uip"`
+	Gid        string         `gorm:"column:gid;type:varchar(50);comment:'组id';not null;uniqueIndex:idx_query,priority:1" json:"gid"`
+	Date       string         `gorm:"column:date;type:date;uniqueIndex:idx_query,priority:3" json:"date"`
+	Hour       int            `gorm:"column:hour;uniqueIndex:idx_query,priority:4" json:"hour"`
+	Regions    datatypes.JSON `gorm:"column:regions;type:json" json:"regions"`
+	Devices    datatypes.JSON `gorm:"column:devices;type:json" json:"devices"`
 }
 
+// StatisticWholeDay LinkAccessStatisticBasic 中整天统计的 hour
+const StatisticWholeDay = -1
+
+// TName 根据分组进行分表
 func (l LinkAccessStatisticBasic) TName() string {
 	id := hash(l.Gid)
-	return fmt.Sprintf("link_access_statistic_basic_%d", id%LinkAccessStatisticShardingNum)
+	return fmt.Sprintf("%s-%d", LinkAccessStatisticBasicPrefix, id%LinkAccessStatisticBasicShardingNum)
 }
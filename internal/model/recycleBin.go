@@ -0,0 +1,23 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecycleBin 回收站中的短链接
+// 短链接删除后 redirect 记录随之删除,短链接本身只做软删除;
+// 回收站记录按 uri 分表,在回收期间保留 uri,防止被其他短链接占用
+type RecycleBin struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index:idx_created_at"` // 删除时间
+	Uri       string    `gorm:"type:varchar(10);column:uri;comment:'短链接';not null;uniqueIndex:idx_uri"`
+	Gid       string    `gorm:"column:gid;comment:'删除时所在的组id';not null"`
+	CUsername string    `gorm:"column:c_username;type:varchar(50);comment:'删除时所在分组的创建人';not null;default:''"`
+}
+
+// TName 根据 uri 进行分表,与 Redirect 分表规则一致
+func (r RecycleBin) TName() string {
+	id := hash(r.Uri)
+	return fmt.Sprintf("%s-%d", RecycleBinPrefix, id%RecycleBinShardingNum)
+}
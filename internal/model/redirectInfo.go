@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"time"
 )
 
 type Redirect struct {
@@ -11,8 +12,44 @@ type Redirect struct {
 	OriginalURL string `gorm:"type:nvarchar(255);column:original_URL;comment:'原始链接';not null;" json:"originalURL,omitempty"`
 	VaildDate   string `gorm:"-" json:"vaildDate,omitempty"`
 	VaildType   int    `gorm:"-" json:"vaildType,omitempty"`
+	// 以下字段与短链接保持一致,跳转时只需要查询 redirect
+	RedirectCode int    `gorm:"column:redirect_code;comment:'跳转状态码';default:302" json:"redirectCode,omitempty"`
+	UtmTemplate  string `gorm:"column:utm_template;type:varchar(255);comment:'追加到原始链接的 utm 参数';default:''" json:"utmTemplate,omitempty"`
+	QueryMode    string `gorm:"column:query_mode;type:varchar(20);comment:'查询参数的处理方式';default:'drop'" json:"queryMode,omitempty"`
+	// 短链接的启用状态与有效期,禁用或过期的短链接不再跳转
+	// 使用 disabled 而不是 enable:gorm 创建记录时会用默认值替换零值,enable 默认为 1 时无法写入 0
+	Disabled int        `gorm:"column:disabled;type:tinyint(1);comment:'是否禁用';not null;default:0" json:"disabled,omitempty"`
+	ExpireAt *time.Time `gorm:"column:expire_at;comment:'过期时间,为空表示永久有效'" json:"expireAt,omitempty"`
 }
 
+// NewRedirect 根据短链接生成对应的跳转信息
+func NewRedirect(sl *ShortLink) *Redirect {
+	r := &Redirect{
+		Uri:          sl.Uri,
+		Gid:          sl.Gid,
+		OriginalURL:  sl.OriginUrl,
+		RedirectCode: sl.RedirectCode,
+		UtmTemplate:  sl.UtmTemplate,
+		QueryMode:    sl.QueryMode,
+	}
+	if sl.Enable == 0 {
+		r.Disabled = 1
+	}
+	if sl.ValidDateType > 0 {
+		validTime := sl.ValidTime
+		r.ExpireAt = &validTime
+	}
+	return r
+}
+
+// Expired 短链接在 now 时是否已过期
+func (r *Redirect) Expired(now time.Time) bool {
+	return r.ExpireAt != nil && !now.Before(*r.ExpireAt)
+}
+
+// RedirectColumns 更新跳转信息时写入的字段,utm_template、disabled 与 expire_at 可能被清空,需要显式指定
+var RedirectColumns = []string{"gid", "original_URL", "redirect_code", "utm_template", "query_mode", "disabled", "expire_at"}
+
 func (r Redirect) TName() string {
 	id := hash(r.Uri)
 	return fmt.Sprintf("%s-%d", RedirectPrefix, id%RedirectShardingNum)
@@ -13,6 +13,26 @@ const (
 	LinkAccessRecordShardingNum = 16
 	// LinkAccessStatisticShardingNum 访问统计表分表数量
 	LinkAccessStatisticShardingNum = 16
+	// LinkAccessStatisticBasicShardingNum 分组访问统计表分表数量
+	LinkAccessStatisticBasicShardingNum = 16
+	// TagShardingNum 标签表分表数量
+	TagShardingNum = 16
+	// LinkTagShardingNum 短链接标签关系表分表数量,必须与 TagShardingNum 一致
+	LinkTagShardingNum = TagShardingNum
+	// RecycleBinShardingNum 回收站表分表数量
+	RecycleBinShardingNum = 16
+	// ShortLinkHistoryShardingNum 短链接修改历史表分表数量
+	ShortLinkHistoryShardingNum = 16
+	// GroupDeleteTaskShardingNum 分组删除任务表分表数量
+	GroupDeleteTaskShardingNum = 16
+	// GroupMemberShardingNum 分组成员表分表数量
+	GroupMemberShardingNum = 16
+	// AccessTokenShardingNum 个人访问令牌表分表数量,令牌明文中以两位数字记录分表序号,不能超过 100
+	AccessTokenShardingNum = 16
+	// UserIdentityShardingNum 外部身份关联表分表数量
+	UserIdentityShardingNum = 16
+	// UserContactShardingNum 手机号与邮箱唯一索引表分表数量
+	UserContactShardingNum = 16
 )
 
 const (
@@ -28,4 +48,24 @@ const (
 	LinkAccessRecordPrefix = "link_access_record"
 	//LinkAccessStatisticPrefix LinkAccessStatistic表前缀
 	LinkAccessStatisticPrefix = "link_access_statistic"
+	//LinkAccessStatisticBasicPrefix LinkAccessStatisticBasic表前缀
+	LinkAccessStatisticBasicPrefix = "link_access_statistic_basic"
+	//TagPrefix Tag表前缀
+	TagPrefix = "tag"
+	//LinkTagPrefix LinkTag表前缀
+	LinkTagPrefix = "link_tag"
+	//RecycleBinPrefix RecycleBin表前缀
+	RecycleBinPrefix = "recycle_bin"
+	//ShortLinkHistoryPrefix ShortLinkHistory表前缀
+	ShortLinkHistoryPrefix = "short_link_history"
+	//GroupDeleteTaskPrefix GroupDeleteTask表前缀
+	GroupDeleteTaskPrefix = "group_delete_task"
+	//GroupMemberPrefix GroupMember表前缀
+	GroupMemberPrefix = "group_member"
+	//AccessTokenPrefix AccessToken表前缀
+	AccessTokenPrefix = "access_token"
+	//UserIdentityPrefix UserIdentity表前缀
+	UserIdentityPrefix = "user_identity"
+	//UserContactPrefix UserContact表前缀
+	UserContactPrefix = "user_contact"
 )
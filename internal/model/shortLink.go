@@ -21,8 +21,21 @@ type ShortLink struct {
 	Enable        int            `gorm:"column:enable;type:tinyint(1);comment:'是否启用';default:1" json:"enable"`
 	Favicon       string         `gorm:"column:favicon;comment:'网站图标';default:''"`
 	Uri           string         `gorm:"type:nvarchar(255);column:uri;comment:'生成短链接的uri';not null;index:idx_gid_uri;index:uri_deleted" json:"uri"`
+	RedirectCode  int            `gorm:"column:redirect_code;comment:'跳转状态码';default:302" json:"redirect_code"`
+	UtmTemplate   string         `gorm:"column:utm_template;type:varchar(255);comment:'追加到原始链接的 utm 参数';default:''" json:"utm_template"`
+	QueryMode     string         `gorm:"column:query_mode;type:varchar(20);comment:'查询参数的处理方式';default:'drop'" json:"query_mode"`
 }
 
+// DefaultRedirectCode 默认的跳转状态码
+const DefaultRedirectCode = 302
+
+// 访问短链接时携带的查询参数的处理方式
+const (
+	QueryModeDrop     = "drop"     // 丢弃
+	QueryModeAppend   = "append"   // 追加到原始链接,不覆盖原始链接中的同名参数
+	QueryModeOverride = "override" // 追加到原始链接,并覆盖原始链接中的同名参数
+)
+
 // TName 对应的分表表名
 func (s ShortLink) TName() string {
 	id := hash(s.Gid)
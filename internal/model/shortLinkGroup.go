@@ -15,8 +15,25 @@ type ShortLinkGroup struct {
 	Gid       string         `gorm:"column:gid;NOT NULL;comment:'分组 id';index:idx" json:"gid"`
 	Name      string         `gorm:"column:name;type:varchar(50);NOT NULL;comment:'分组名'" json:"name"`
 	CUsername string         `gorm:"column:c_username;type:varchar(50);NOT NULL;comment:'创建人';index:idx" json:"cUser"`
+	IsDefault bool           `gorm:"column:is_default;NOT NULL;default:false;comment:'是否为默认分组'" json:"isDefault"`
+	ParentGid string         `gorm:"column:parent_gid;type:varchar(50);NOT NULL;default:'';comment:'父分组 id,为空时为顶层分组'" json:"parentGid"`
+	Settings  GroupSettings  `gorm:"column:settings;type:text;serializer:json;comment:'分组默认设置'" json:"settings"`
 }
 
+// GroupSettings 分组的默认设置,新建短链接时未指定的字段继承分组的设置
+type GroupSettings struct {
+	ExpireDays   int    `json:"expireDays"`   // 新建短链接的有效天数,0 为永不过期
+	RedirectCode int    `json:"redirectCode"` // 跳转状态码,0 为 DefaultRedirectCode
+	UtmTemplate  string `json:"utmTemplate"`  // 跳转时追加到原始链接的 utm 参数,如 utm_source=wechat&utm_medium=social
+	QueryMode    string `json:"queryMode"`    // 访问短链接时携带的查询参数的处理方式,为空时为 QueryModeDrop
+}
+
+// DefaultGroupName 默认分组的名称
+const DefaultGroupName = "默认分组"
+
+// MaxGroupDepth 分组嵌套的最大层数,顶层分组为第 1 层
+const MaxGroupDepth = 5
+
 // TName 根据创建人进行分表
 func (s ShortLinkGroup) TName() string {
 	id := hash(s.CUsername)
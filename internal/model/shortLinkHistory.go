@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 短链接修改历史的操作类型
+const (
+	HistoryActionUpdate   = "update"
+	HistoryActionRollback = "rollback"
+)
+
+// ShortLinkSnapshot 短链接可修改字段的快照
+type ShortLinkSnapshot struct {
+	Gid           string `json:"gid"`
+	OriginUrl     string `json:"originUrl"`
+	Description   string `json:"describe"`
+	ValidDateType int    `json:"validDateType"`
+	ValidDate     string `json:"validDate"`
+	Enable        int    `json:"enable"`
+	RedirectCode  int    `json:"redirectCode"`
+	UtmTemplate   string `json:"utmTemplate"`
+	QueryMode     string `json:"queryMode"`
+}
+
+// NewShortLinkSnapshot 生成短链接当前状态的快照
+func NewShortLinkSnapshot(sl *ShortLink) ShortLinkSnapshot {
+	snapshot := ShortLinkSnapshot{
+		Gid:           sl.Gid,
+		OriginUrl:     sl.OriginUrl,
+		Description:   sl.Description,
+		ValidDateType: sl.ValidDateType,
+		Enable:        sl.Enable,
+		RedirectCode:  sl.RedirectCode,
+		UtmTemplate:   sl.UtmTemplate,
+		QueryMode:     sl.QueryMode,
+	}
+	if sl.ValidDateType > 0 {
+		snapshot.ValidDate = sl.ValidTime.Format("2006-01-02 15:04:05")
+	}
+	return snapshot
+}
+
+// ShortLinkHistory 短链接的修改历史
+// 按 uri 分表,短链接移动分组后历史记录仍然可以查到
+type ShortLinkHistory struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time         `json:"-"`
+	Uri       string            `gorm:"type:varchar(10);column:uri;comment:'短链接';not null;index:idx_uri" json:"uri"`
+	Actor     string            `gorm:"column:actor;type:varchar(50);comment:'操作人';not null" json:"actor"`
+	Action    string            `gorm:"column:action;type:varchar(16);comment:'操作类型';not null" json:"action"`
+	Before    ShortLinkSnapshot `gorm:"column:before_value;type:text;serializer:json;comment:'修改前'" json:"before"`
+	After     ShortLinkSnapshot `gorm:"column:after_value;type:text;serializer:json;comment:'修改后'" json:"after"`
+}
+
+// TName 根据 uri 进行分表
+func (h ShortLinkHistory) TName() string {
+	id := hash(h.Uri)
+	return fmt.Sprintf("%s-%d", ShortLinkHistoryPrefix, id%ShortLinkHistoryShardingNum)
+}
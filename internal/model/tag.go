@@ -0,0 +1,40 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tag 用户的标签目录
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	Name      string    `gorm:"column:name;type:varchar(32);NOT NULL;uniqueIndex:idx_username_name;comment:'标签名'" json:"name"`
+	Color     string    `gorm:"column:color;type:varchar(16);NOT NULL;default:'';comment:'标签颜色'" json:"color"`
+	CUsername string    `gorm:"column:c_username;type:varchar(50);NOT NULL;uniqueIndex:idx_username_name;comment:'创建人'" json:"-"`
+}
+
+// TName 根据创建人进行分表
+func (t Tag) TName() string {
+	id := hash(t.CUsername)
+	return fmt.Sprintf("%s-%d", TagPrefix, id%TagShardingNum)
+}
+
+// LinkTag 短链接与标签的多对多关系
+// 与标签同样按创建人分表,并以 uri 关联短链接,因此短链接移动分组时不受影响;
+// 短链接进入回收站时保留关系并标记 recycled,恢复后标签随之恢复
+type LinkTag struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TagID     uint   `gorm:"column:tag_id;NOT NULL;uniqueIndex:idx_tag_uri;comment:'标签 id'"`
+	Uri       string `gorm:"column:uri;type:varchar(10);NOT NULL;uniqueIndex:idx_tag_uri;index:idx_username_uri;comment:'短链接'"`
+	CUsername string `gorm:"column:c_username;type:varchar(50);NOT NULL;index:idx_username_uri;comment:'创建人'"`
+	Recycled  int    `gorm:"column:recycled;type:tinyint(1);NOT NULL;default:0;comment:'短链接是否在回收站中'"`
+}
+
+// TName 根据创建人进行分表,与 Tag 分表规则一致
+func (l LinkTag) TName() string {
+	id := hash(l.CUsername)
+	return fmt.Sprintf("%s-%d", LinkTagPrefix, id%LinkTagShardingNum)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 联系方式类型
+const (
+	ContactPhone = "phone"
+	ContactMail  = "mail"
+)
+
+// UserContact 手机号与邮箱的全局唯一索引
+// 用户表按用户名分表,分表内的唯一索引无法阻止不同分表中出现相同的手机号或邮箱;
+// 该表按归一化后的联系方式分表,同一联系方式只会落在一张分表中,与用户记录在同一个事务中写入
+type UserContact struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Kind      string    `gorm:"column:kind;type:varchar(10);NOT NULL;uniqueIndex:idx_kind_value,priority:1;comment:'类型,phone 或 mail'" json:"kind"`
+	Value     string    `gorm:"column:value;type:varchar(255);NOT NULL;uniqueIndex:idx_kind_value,priority:2;comment:'归一化后的手机号或邮箱'" json:"value"`
+	Username  string    `gorm:"column:username;type:varchar(50);NOT NULL;index:idx_username;comment:'所属用户'" json:"username"`
+}
+
+// TName 根据联系方式进行分表
+func (c UserContact) TName() string {
+	return fmt.Sprintf("%s-%d", UserContactPrefix, hash(c.Kind+":"+c.Value)%UserContactShardingNum)
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// UserIdentity 外部身份与本地账号的关联,一个账号可以关联多个身份
+// 按身份分表,单点登录时只需要访问一张分表
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Issuer    string    `gorm:"column:issuer;type:varchar(255);NOT NULL;uniqueIndex:idx_issuer_subject,priority:1;comment:'身份提供方'" json:"issuer"`
+	Subject   string    `gorm:"column:subject;type:varchar(255);NOT NULL;uniqueIndex:idx_issuer_subject,priority:2;comment:'身份提供方中的用户标识'" json:"subject"`
+	Username  string    `gorm:"column:username;type:varchar(50);NOT NULL;index:idx_username;comment:'关联的用户名'" json:"username"`
+	Mail      string    `gorm:"column:mail;type:varchar(255);NOT NULL;default:'';comment:'关联时身份提供方返回的邮箱'" json:"mail"`
+}
+
+// TName 根据身份进行分表
+func (u UserIdentity) TName() string {
+	return fmt.Sprintf("%s-%d", UserIdentityPrefix, hash(u.Issuer+"|"+u.Subject)%UserIdentityShardingNum)
+}
@@ -10,8 +10,52 @@ type TUser struct {
 	Username   string `gorm:"column:username;type:nvarchar(20);comment:'用户名';uniqueIndex" json:"username"`
 	Password   string `gorm:"column:password;type:varchar(80);comment:'密码'" json:"password"`
 	RealName   string `gorm:"column:real_name;type:nvarchar(20);comment:'真实姓名'" json:"realName"`
-	Phone      string `gorm:"column:phone;type:varchar(20);comment:'手机号';uniqueIndex" json:"phone"`
-	Mail       string `gorm:"column:mail;type:varchar(50);comment:'邮箱';uniqueIndex" json:"mail"`
+	Phone      string `gorm:"column:phone;type:varchar(20);comment:'手机号'" json:"phone"` // 手机号与邮箱的全局唯一性由 UserContact 保证,允许为空
+	Mail       string `gorm:"column:mail;type:varchar(50);comment:'邮箱'" json:"mail"`
+	Role       string `gorm:"column:role;type:varchar(20);NOT NULL;default:'user';comment:'角色'" json:"role"`
+	// 修改邮箱后需要重新验证
+	MailVerified bool `gorm:"column:mail_verified;NOT NULL;default:false;comment:'邮箱是否已验证'" json:"mailVerified"`
+	// 两步验证,恢复码只保存 sha256,以逗号分隔,使用后移除
+	TOTPEnabled   bool   `gorm:"column:totp_enabled;NOT NULL;default:false;comment:'是否启用两步验证'" json:"totpEnabled"`
+	TOTPSecret    string `gorm:"column:totp_secret;type:varchar(64);NOT NULL;default:'';comment:'两步验证密钥'" json:"-"`
+	RecoveryCodes string `gorm:"column:recovery_codes;type:text;comment:'两步验证恢复码'" json:"-"`
+}
+
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// 权限
+const (
+	PermissionSystemFix = "system:fix" // 调用紧急修复接口
+	PermissionUserRead  = "user:read"  // 查看任意用户未脱敏的信息
+	PermissionUserLock  = "user:lock"  // 解除账号的登录锁定
+)
+
+// rolePermissions 角色拥有的权限
+var rolePermissions = map[string][]string{
+	RoleAdmin: {PermissionSystemFix, PermissionUserRead, PermissionUserLock},
+	RoleUser:  {},
+}
+
+// RoleHasPermission 判断角色是否拥有权限,未知角色没有任何权限
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRole 用户的角色,未设置时为普通用户
+func (u *TUser) GetRole() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
 }
 
 // TName 基于 Username 进行分库分表
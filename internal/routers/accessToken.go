@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"SnapLink/internal/handler"
+	"SnapLink/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	apiV1RouterFns = append(apiV1RouterFns, func(group *gin.RouterGroup) {
+		accessTokenRouter(group, handler.NewAccessTokenHandler())
+	})
+}
+
+func accessTokenRouter(group *gin.RouterGroup, h handler.AccessTokenHandler) {
+	group = group.Group("/")
+	// 只能通过登录签发的访问令牌管理个人访问令牌
+	group.Use(middleware.Auth())
+
+	group.POST("/user/tokens", h.Create)
+	group.GET("/user/tokens", h.List)
+	group.DELETE("/user/tokens/:id", h.Revoke)
+}
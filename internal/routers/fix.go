@@ -2,13 +2,14 @@ package routers
 
 import (
 	"SnapLink/internal/handler"
-	middleware2 "SnapLink/internal/middleware"
+	"SnapLink/internal/middleware"
+	"SnapLink/internal/model"
 	"github.com/gin-gonic/gin"
-	"github.com/zhufuyi/sponge/pkg/gin/middleware"
 )
 
 type FixHandler interface {
 	RebulidBF(c *gin.Context)
+	RebuildSearchIndex(c *gin.Context)
 }
 
 func init() {
@@ -20,7 +21,9 @@ func init() {
 // fixRouter 修复是在生产环境中出现服务异常时的紧急修复接口
 func fixRouter(group *gin.RouterGroup, h FixHandler) {
 	group = group.Group("/")
-	group.Use(middleware.Auth())
+	group.Use(middleware.Auth(), middleware.RequirePermission(model.PermissionSystemFix))
 	//重建布隆过滤器
-	group.GET("/fix/rebuildbf", middleware2.Sentinel("/fix/rebuildbf"), h.RebulidBF)
+	group.GET("/fix/rebuildbf", middleware.Sentinel("/fix/rebuildbf"), h.RebulidBF)
+	//重建全文检索索引
+	group.GET("/fix/rebuildsearch", middleware.Sentinel("/fix/rebuildsearch"), h.RebuildSearchIndex)
 }
@@ -2,6 +2,8 @@ package routers
 
 import (
 	"SnapLink/internal/handler"
+	"SnapLink/internal/middleware"
+	"SnapLink/internal/token"
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,6 +12,7 @@ type LinkAccessStatisticHandler interface {
 	GetRecords(c *gin.Context)
 	RefreshStatistic(c *gin.Context)
 	GetStatisticByDay(c *gin.Context)
+	GetGroupStatistic(c *gin.Context)
 }
 
 func init() {
@@ -22,7 +25,7 @@ func linkAccessStatisticRouter(group *gin.RouterGroup, h LinkAccessStatisticHand
 	//获取基础访问统计(PV,UV,UIP)
 	group.GET("/stats", h.GetStatistic)
 	//获取分组短链接监控
-	//group.GET("/stats/group", h.GetStatistic)
+	group.GET("/stats/group", middleware.Auth(token.ScopeStatsRead), h.GetGroupStatistic)
 	//获取单次访问详情
 	group.GET("/stats/access-record", h.GetRecords)
 	//立刻更新最新的访问统计数据
@@ -2,10 +2,13 @@ package routers
 
 import (
 	"net/http"
-	"time"
+	"os"
 
 	"SnapLink/docs"
 	"SnapLink/internal/config"
+	"SnapLink/internal/mail"
+	"SnapLink/internal/sso"
+	"SnapLink/internal/token"
 
 	"github.com/zhufuyi/sponge/pkg/errcode"
 	"github.com/zhufuyi/sponge/pkg/gin/handlerfunc"
@@ -13,7 +16,6 @@ import (
 	"github.com/zhufuyi/sponge/pkg/gin/middleware/metrics"
 	"github.com/zhufuyi/sponge/pkg/gin/prof"
 	"github.com/zhufuyi/sponge/pkg/gin/validator"
-	"github.com/zhufuyi/sponge/pkg/jwt"
 	"github.com/zhufuyi/sponge/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -44,11 +46,10 @@ func NewRouter() *gin.Engine {
 		middleware.WithIgnoreRoutes("/metrics"), // ignore path
 	))
 
-	// init jwt middleware
-	jwt.Init(
-		jwt.WithExpire(time.Hour*24),
-		jwt.WithSigningKey("zaq12222wsxmko0"),
-	)
+	// init token, 访问令牌短期有效,通过刷新令牌续期
+	initToken()
+	initMail()
+	initSSO()
 
 	// metrics middleware
 	if config.Get().App.EnableMetrics {
@@ -85,6 +86,8 @@ func NewRouter() *gin.Engine {
 	r.GET("/ping", handlerfunc.Ping)
 	r.GET("/codes", handlerfunc.ListCodes)
 	r.GET("/config", gin.WrapF(errcode.ShowConfig([]byte(config.Show()))))
+	// 令牌公钥,供其他服务校验访问令牌
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) { c.JSON(http.StatusOK, token.JWKS()) })
 
 	// register swagger routes, generate code via swag init
 	docs.SwaggerInfo.BasePath = ""
@@ -107,3 +110,84 @@ func registerRouters(r *gin.Engine, groupPath string, routerFns []func(*gin.Rout
 		fn(rg)
 	}
 }
+
+// initToken 根据配置加载令牌签名密钥,配置错误时无法启动
+func initToken() {
+	cfg := config.Get().Jwt
+	keys := make([]*token.Key, 0, len(cfg.Keys))
+	for _, item := range cfg.Keys {
+		key, err := loadTokenKey(item)
+		if err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+	}
+	err := token.Init(
+		token.WithAccessExpire(cfg.AccessExpireDuration()),
+		token.WithRefreshExpire(cfg.RefreshExpireDuration()),
+		token.WithKeys(cfg.ActiveKid, keys...),
+		token.WithOneTimeSecret(fromEnv(config.Get().Mail.TokenSecretEnv, config.Get().Mail.TokenSecret)),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func loadTokenKey(item config.JwtKey) (*token.Key, error) {
+	if item.Alg == token.AlgHS256 {
+		return token.NewHMACKey(item.Kid, fromEnv(item.SecretEnv, item.Secret))
+	}
+	var privatePEM, publicPEM []byte
+	var err error
+	if item.PrivateKeyFile != "" {
+		if privatePEM, err = os.ReadFile(item.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if item.PublicKeyFile != "" {
+		if publicPEM, err = os.ReadFile(item.PublicKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	return token.NewAsymmetricKey(item.Kid, item.Alg, privatePEM, publicPEM)
+}
+
+// initMail 根据配置设置邮件发送方式
+func initMail() {
+	cfg := config.Get().Mail
+	switch cfg.Driver {
+	case "smtp":
+		mail.Init(mail.NewSMTPSender(cfg.Host, cfg.Port, cfg.Username, fromEnv(cfg.PasswordEnv, cfg.Password), cfg.From))
+	case "file":
+		mail.Init(mail.NewFileSender(cfg.Dir))
+	default:
+		mail.Init(mail.NewFileSender(""))
+	}
+}
+
+// initSSO 启用单点登录时初始化 OIDC 客户端
+func initSSO() {
+	cfg := config.Get().Oidc
+	if !cfg.Enable {
+		sso.Init(nil)
+		return
+	}
+	sso.Init(sso.NewClient(sso.Config{
+		Issuer:        cfg.Issuer,
+		ClientID:      cfg.ClientID,
+		ClientSecret:  fromEnv(cfg.ClientSecretEnv, cfg.ClientSecret),
+		RedirectURL:   cfg.RedirectURL,
+		Scopes:        cfg.GetScopes(),
+		UsernameClaim: cfg.GetUsernameClaim(),
+	}))
+}
+
+// fromEnv 环境变量 env 存在时返回环境变量的值,否则返回 value
+func fromEnv(env, value string) string {
+	if env != "" {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return value
+}
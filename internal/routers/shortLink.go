@@ -2,10 +2,10 @@ package routers
 
 import (
 	"SnapLink/internal/handler"
-	middleware2 "SnapLink/internal/middleware"
+	"SnapLink/internal/middleware"
+	"SnapLink/internal/token"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
-	"github.com/zhufuyi/sponge/pkg/gin/middleware"
 	"github.com/zhufuyi/sponge/pkg/logger"
 )
 
@@ -21,15 +21,34 @@ func init() {
 
 func shortLinkRouter(group *gin.RouterGroup, h handler.ShortLinkHandler) {
 	group = group.Group("/")
-	group.Use(middleware.Auth())
+	// 个人访问令牌需要 links:read 或 links:write 权限
+	read := middleware.Auth(token.ScopeLinksRead)
+	write := middleware.Auth(token.ScopeLinksWrite)
 	//创建短链接
-	group.POST("/shortlink", middleware2.Sentinel("POST /shortlink"), h.Create)
+	group.POST("/shortlink", write, middleware.Sentinel("POST /shortlink"), h.Create)
 	//批量创建短链接
-	group.POST("/shortlink/batch", h.CreateBatch)
+	group.POST("/shortlink/batch", write, h.CreateBatch)
 	//更新短链接
-	group.PUT("/shortlink", h.Update)
+	group.PUT("/shortlink", write, h.Update)
 	//分页查询短链接
-	group.GET("/shortlink/page", h.List)
+	group.GET("/shortlink/page", read, h.List)
 	//删除短链接
-	group.DELETE("/shortlink/:uri", h.Delete)
+	group.DELETE("/shortlink/:uri", write, h.Delete)
+	//导出短链接
+	group.GET("/shortlink/export", read, h.Export)
+	//导入短链接
+	group.POST("/shortlink/import", write, h.Import)
+	//搜索短链接
+	group.GET("/shortlink/search", read, h.Search)
+	//设置短链接标签
+	group.PUT("/shortlink/tags", write, h.SetTags)
+	//回收站
+	group.GET("/shortlink/recycle/page", read, h.ListRecycled)
+	group.POST("/shortlink/recycle/restore", write, h.Restore)
+	group.DELETE("/shortlink/recycle/:uri", write, h.Purge)
+	//批量操作
+	group.POST("/shortlink/bulk", write, h.Bulk)
+	//修改历史与回滚
+	group.GET("/shortlink/:uri/history", read, h.History)
+	group.POST("/shortlink/:uri/history/:id/rollback", write, h.Rollback)
 }
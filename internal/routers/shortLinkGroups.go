@@ -2,8 +2,8 @@ package routers
 
 import (
 	"SnapLink/internal/handler"
+	"SnapLink/internal/middleware"
 	"github.com/gin-gonic/gin"
-	"github.com/zhufuyi/sponge/pkg/gin/middleware"
 )
 
 func init() {
@@ -20,5 +20,21 @@ func ShortLinkGroupRouter(group *gin.RouterGroup, h handler.ShortLinkGroupHandle
 	group.GET("/group", h.List)
 	group.PUT("/group", h.UpdateByGID)
 	group.DELETE("/group", h.DelByGID)
+	group.GET("/group/delete-task", h.DeleteTask)
 	group.POST("/group/sort", h.UpdateSortOrder)
+	// 分组的父子关系
+	group.PUT("/group/parent", h.MoveGroup)
+	group.GET("/group/tree", h.Tree)
+	group.GET("/group/tree/stats", h.TreeStatistic)
+	// 分组默认设置
+	group.GET("/group/settings", h.GetSettings)
+	group.PUT("/group/settings", h.UpdateSettings)
+	// 分组成员与邀请
+	group.POST("/group/member", h.InviteMember)
+	group.GET("/group/member", h.ListMembers)
+	group.PUT("/group/member", h.UpdateMember)
+	group.DELETE("/group/member", h.RemoveMember)
+	group.GET("/group/invitation", h.ListInvitations)
+	group.POST("/group/invitation/accept", h.AcceptInvitation)
+	group.POST("/group/invitation/decline", h.DeclineInvitation)
 }
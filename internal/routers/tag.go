@@ -0,0 +1,25 @@
+package routers
+
+import (
+	"SnapLink/internal/handler"
+	"SnapLink/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	apiV1RouterFns = append(apiV1RouterFns, func(group *gin.RouterGroup) {
+		tagRouter(group, handler.NewTagHandler())
+	})
+}
+
+func tagRouter(group *gin.RouterGroup, h handler.TagHandler) {
+	group = group.Group("/")
+	group.Use(middleware.Auth())
+
+	group.POST("/tag", h.Create)
+	group.GET("/tag", h.List)
+	group.PUT("/tag", h.Update)
+	group.DELETE("/tag/:id", h.Delete)
+	//标签汇总统计
+	group.GET("/tag/:id/stats", h.Stats)
+}
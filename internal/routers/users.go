@@ -2,8 +2,9 @@ package routers
 
 import (
 	"SnapLink/internal/handler"
+	"SnapLink/internal/middleware"
+	"SnapLink/internal/model"
 	"github.com/gin-gonic/gin"
-	"github.com/zhufuyi/sponge/pkg/gin/middleware"
 )
 
 func init() {
@@ -22,6 +23,22 @@ type UsersHandler interface {
 	Login(c *gin.Context)
 	CheckLogin(c *gin.Context)
 	Logout(c *gin.Context)
+	LogoutAll(c *gin.Context)
+	RefreshToken(c *gin.Context)
+	UnlockLogin(c *gin.Context)
+	SendVerifyMail(c *gin.Context)
+	VerifyMail(c *gin.Context)
+	RequestPasswordReset(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	LoginTwoFactor(c *gin.Context)
+	EnrollTwoFactor(c *gin.Context)
+	ActivateTwoFactor(c *gin.Context)
+	DisableTwoFactor(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	SSOAuthorize(c *gin.Context)
+	SSOLinkAuthorize(c *gin.Context)
+	SSOCallback(c *gin.Context)
+	ListUsers(c *gin.Context)
 }
 
 func usersRouter(group *gin.RouterGroup, h UsersHandler) {
@@ -32,6 +49,19 @@ func usersRouter(group *gin.RouterGroup, h UsersHandler) {
 	group.POST("/user", h.Register)
 	//用户登录
 	group.POST("/user/login", h.Login)
+	//两步验证登录
+	group.POST("/user/login/2fa", h.LoginTwoFactor)
+	//单点登录
+	group.GET("/user/sso/authorize", h.SSOAuthorize)
+	group.POST("/user/sso/callback", h.SSOCallback)
+	//刷新令牌
+	group.POST("/user/token/refresh", h.RefreshToken)
+	//验证邮箱
+	group.POST("/user/mail/verify", h.VerifyMail)
+	//申请重置密码
+	group.POST("/user/password/reset/request", h.RequestPasswordReset)
+	//重置密码
+	group.POST("/user/password/reset", h.ResetPassword)
 
 	//检查用户是否登录
 	group.GET("/user/check-login", h.CheckLogin)
@@ -43,11 +73,32 @@ func usersRouter(group *gin.RouterGroup, h UsersHandler) {
 	needAuth.GET("/user/:username", h.GetByUsername)
 
 	//根据用户名查找用户无脱敏信息
-	needAuth.GET("/actual/user/:username", h.GetByUsernameDesensitization)
+	needAuth.GET("/actual/user/:username", middleware.RequirePermission(model.PermissionUserRead), h.GetByUsernameDesensitization)
+
+	//管理员查询用户列表
+	needAuth.POST("/users/list", middleware.RequirePermission(model.PermissionUserRead), h.ListUsers)
 
 	//修改用户
 	needAuth.PUT("/user", h.UpdateInfo)
 
+	//重新发送邮箱验证邮件
+	needAuth.POST("/user/mail/verification", h.SendVerifyMail)
+
+	//两步验证
+	needAuth.POST("/user/2fa/enroll", h.EnrollTwoFactor)
+	needAuth.POST("/user/2fa/activate", h.ActivateTwoFactor)
+	needAuth.DELETE("/user/2fa", h.DisableTwoFactor)
+	needAuth.POST("/user/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+
+	//关联外部身份
+	needAuth.GET("/user/sso/link", h.SSOLinkAuthorize)
+
 	//用户登出
 	needAuth.DELETE("/user/logout", h.Logout)
+
+	//在全部设备上登出
+	needAuth.DELETE("/user/logout/all", h.LogoutAll)
+
+	//解除账号的登录锁定
+	needAuth.DELETE("/user/lock/:username", middleware.RequirePermission(model.PermissionUserLock), h.UnlockLogin)
 }
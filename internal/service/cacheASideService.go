@@ -2,6 +2,7 @@ package service
 
 import (
 	"SnapLink/internal/cache"
+	"SnapLink/internal/elasticsearch"
 	"SnapLink/internal/message_queue/rabbitmq"
 	"SnapLink/internal/model"
 	"context"
@@ -45,6 +46,10 @@ var (
 				return cache.Redirect().Del(ctx, m["uri"].(string))
 			case insertAction, deleteAction:
 				{
+					// 删除进入回收站或从回收站恢复时,清除跳转缓存(包括空值缓存)
+					if err := cache.Redirect().Del(ctx, m["uri"].(string)); err != nil {
+						return err
+					}
 					//处理对应的 gid 数目更新
 					return cache.ShortLinkGroupCountCache().Del(ctx, m["gid"].(string))
 				}
@@ -55,11 +60,29 @@ var (
 		model.SLGroupPrefix: func(ctx context.Context, action string, m map[string]any) error {
 			switch action {
 			case insertAction, updateAction:
+				if err := cache.GroupInfo().Del(ctx, m["gid"].(string)); err != nil {
+					return err
+				}
 				return cache.SLGroup().Del(ctx, m["c_username"].(string))
 			default:
 				return nil
 			}
 		},
+		// 短链接的变更同步到 ES 中,用于全文检索
+		model.ShortLinkPrefix: func(ctx context.Context, action string, m map[string]any) error {
+			return syncShortLinkDocument(ctx, m["uri"].(string))
+		},
+		// 短链接的标签变更后,同步更新 ES 中的标签
+		model.LinkTagPrefix: func(ctx context.Context, action string, m map[string]any) error {
+			return syncShortLinkDocument(ctx, m["uri"].(string))
+		},
+		// 标签删除时其关系也会被删除,由 link_tag 的事件处理,此处只需处理改名
+		model.TagPrefix: func(ctx context.Context, action string, m map[string]any) error {
+			if action != updateAction {
+				return nil
+			}
+			return syncTaggedShortLinkDocuments(ctx, m["c_username"].(string), uint(m["id"].(float64)))
+		},
 	}
 )
 
@@ -83,6 +106,10 @@ func NewCacheASideService() app.IServer {
 }
 
 func (s *CacheASideService) Start() error {
+	// 索引不存在时创建,失败不影响缓存的更新
+	if err := elasticsearch.CreateShortLinkIndex(s.ctx); err != nil {
+		logger.Warn("create short link index failed", logger.Err(err))
+	}
 
 	for i := 0; i < consumerNumber; i++ {
 		ch, err := s.subscriber.Subscribe(s.ctx, "saas")
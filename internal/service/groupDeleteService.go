@@ -0,0 +1,201 @@
+package service
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/model"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/app"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+var _ app.IServer = (*groupDeleteService)(nil)
+
+// errLockLost 任务锁已过期并被其他实例获取,停止处理
+var errLockLost = errors.New("group delete task lock lost")
+
+const (
+	// groupDeleteInterval 检查未完成的分组删除任务的间隔
+	groupDeleteInterval = 10 * time.Second
+	// groupDeleteBatchSize 每批处理的短链接数量,每批处理完成后保存一次进度
+	groupDeleteBatchSize = 200
+	// groupDeleteLockPrefix 分组删除任务的锁前缀,防止多个实例同时处理同一个任务
+	groupDeleteLockPrefix = "group_delete:"
+	// groupDeleteLockTTL 任务锁的过期时间,每批处理完成后续期,实例崩溃后由过期释放
+	groupDeleteLockTTL = time.Minute
+)
+
+// groupDeleteService 处理被删除分组中的短链接
+type groupDeleteService struct {
+	groupDao dao.ShortLinkGroupDao
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewGroupDeleteService 新增分组删除服务
+func NewGroupDeleteService() app.IServer {
+	s := &groupDeleteService{
+		groupDao: dao.NewShortLinkGroupDao(model.GetDB()),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+func (s *groupDeleteService) Start() error {
+	go func() {
+		ticker := time.NewTicker(groupDeleteInterval)
+		defer ticker.Stop()
+		for {
+			s.runAll()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// runAll 执行全部未完成的任务,失败的任务等待下一次执行
+func (s *groupDeleteService) runAll() {
+	tasks, err := s.groupDao.ListRunningDeleteTasks(s.ctx)
+	if err != nil {
+		logger.Error("查询分组删除任务失败", logger.Err(err))
+		return
+	}
+	for _, task := range tasks {
+		if s.ctx.Err() != nil {
+			return
+		}
+		lockName := groupDeleteLockPrefix + task.Gid
+		lockOwner := uuid.NewString()
+		locked, err := cache.TaskLock().Acquire(s.ctx, lockName, lockOwner, groupDeleteLockTTL)
+		if err != nil || !locked {
+			continue
+		}
+		if err = s.run(task, lockName, lockOwner); errors.Is(err, errLockLost) {
+			// 任务已由其他实例接手,不能再写入进度
+			logger.Warn("分组删除任务锁已失效", logger.String("gid", task.Gid), logger.Int("processed", task.Processed))
+			continue
+		}
+		if err != nil {
+			task.LastError = err.Error()
+			if len(task.LastError) > 255 {
+				task.LastError = task.LastError[:255]
+			}
+			if err := s.groupDao.SaveDeleteTaskProgress(s.ctx, task); err != nil {
+				logger.Warn("保存分组删除任务失败", logger.Err(err), logger.String("gid", task.Gid))
+			}
+			logger.Error("处理分组删除任务失败", logger.Err(err), logger.String("gid", task.Gid), logger.Int("processed", task.Processed))
+		}
+		// 只释放自己持有的锁,处理时间过长导致锁过期后可能已被其他实例获取
+		if err = cache.TaskLock().Release(s.ctx, lockName, lockOwner); err != nil {
+			logger.Warn("释放分组删除任务锁失败", logger.Err(err), logger.String("gid", task.Gid))
+		}
+	}
+}
+
+// run 从上次的进度开始分批处理分组内的短链接
+func (s *groupDeleteService) run(task *model.GroupDeleteTask, lockName, lockOwner string) error {
+	linkDao := dao.ShortLinkDao()
+	for {
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		links, err := linkDao.Scan(s.ctx, task.Gid, task.Cursor, groupDeleteBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			break
+		}
+		if err = s.apply(task, links); err != nil {
+			return err
+		}
+		uris := make([]string, 0, len(links))
+		for _, sl := range links {
+			uris = append(uris, sl.Uri)
+		}
+		if err = cache.Redirect().MDel(s.ctx, uris...); err != nil {
+			logger.Warn("批量清除跳转缓存失败", logger.Err(err), logger.String("gid", task.Gid))
+		}
+		// 先续期再保存进度,锁已失效时不覆盖其他实例保存的进度
+		renewed, err := cache.TaskLock().Refresh(s.ctx, lockName, lockOwner, groupDeleteLockTTL)
+		if err != nil {
+			return err
+		}
+		if !renewed {
+			return errLockLost
+		}
+		task.Cursor = links[len(links)-1].ID
+		task.Processed += len(links)
+		task.LastError = ""
+		if err = s.groupDao.SaveDeleteTaskProgress(s.ctx, task); err != nil {
+			return err
+		}
+	}
+	// 短链接数量发生了变化,清除分组的计数缓存
+	if err := cache.ShortLinkGroupCountCache().Del(s.ctx, task.Gid); err != nil {
+		logger.Warn("删除缓存失败", logger.Err(err), logger.String("gid", task.Gid))
+	}
+	if task.TargetGid != "" {
+		if err := cache.ShortLinkGroupCountCache().Del(s.ctx, task.TargetGid); err != nil {
+			logger.Warn("删除缓存失败", logger.Err(err), logger.String("gid", task.TargetGid))
+		}
+	}
+	task.Status = model.GroupDeleteTaskDone
+	if err := s.groupDao.SaveDeleteTaskProgress(s.ctx, task); err != nil {
+		return err
+	}
+	logger.Info("分组删除任务完成", logger.String("gid", task.Gid), logger.String("strategy", task.Strategy), logger.Int("processed", task.Processed))
+	return nil
+}
+
+// apply 按任务的策略处理一批短链接,重复执行时结果不变
+func (s *groupDeleteService) apply(task *model.GroupDeleteTask, links []*model.ShortLink) error {
+	var failed map[string]error
+	switch task.Strategy {
+	case model.GroupDeleteStrategyDelete:
+		// 分组已被删除,无法再查询创建人,直接使用任务中记录的创建人
+		failed = dao.ShortLinkDao().BulkDelete(s.ctx, links, task.CUsername)
+	case model.GroupDeleteStrategyMove, model.GroupDeleteStrategyDisable:
+		changes := make([]*dao.ShortLinkChange, 0, len(links))
+		for _, sl := range links {
+			after := *sl
+			if task.Strategy == model.GroupDeleteStrategyMove {
+				after.Gid = task.TargetGid
+			} else {
+				if sl.Enable == 0 {
+					continue
+				}
+				after.Enable = 0
+			}
+			changes = append(changes, &dao.ShortLinkChange{Before: sl, After: &after})
+		}
+		// 同上,原分组已被删除,使用任务中记录的创建人
+		failed = dao.ShortLinkDao().BulkUpdate(s.ctx, changes, task.CUsername, task.CUsername)
+	default:
+		return errors.Errorf("unknown strategy: %s", task.Strategy)
+	}
+	// 只要有失败就不推进游标,下一次从本批次开始重新处理
+	for uri, err := range failed {
+		if err != nil {
+			return errors.Wrapf(err, "uri: %s", uri)
+		}
+	}
+	return nil
+}
+
+func (s *groupDeleteService) Stop() error {
+	s.cancel()
+	return nil
+}
+
+func (s *groupDeleteService) String() string {
+	return "groupDeleteService"
+}
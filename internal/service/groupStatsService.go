@@ -0,0 +1,96 @@
+package service
+
+import (
+	"SnapLink/internal/cache"
+	"SnapLink/internal/dao"
+	"context"
+	"time"
+
+	"github.com/zhufuyi/sponge/pkg/app"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+var _ app.IServer = (*groupStatsService)(nil)
+
+const (
+	// groupStatsFlushInterval 分组访问统计落库的间隔
+	groupStatsFlushInterval = time.Minute
+	// groupStatsFlushBatchSize 每批落库的统计桶数量
+	groupStatsFlushBatchSize = 500
+)
+
+// groupStatsService 定期将缓存中的分组访问统计写入数据库
+type groupStatsService struct {
+	statsDao dao.IGroupStatsDao
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewGroupStatsService 新增分组访问统计落库服务
+func NewGroupStatsService() app.IServer {
+	s := &groupStatsService{
+		statsDao: dao.GroupStatsDao(),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+func (s *groupStatsService) Start() error {
+	go func() {
+		ticker := time.NewTicker(groupStatsFlushInterval)
+		defer ticker.Stop()
+		for {
+			s.flush()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// flush 写入全部待落库的统计桶,失败的统计桶放回等待下一次落库
+func (s *groupStatsService) flush() {
+	for s.ctx.Err() == nil {
+		buckets, err := cache.GroupStats().PopDirty(s.ctx, groupStatsFlushBatchSize)
+		if err != nil {
+			logger.Error("获取待落库的分组访问统计失败", logger.Err(err))
+			return
+		}
+		if len(buckets) == 0 {
+			return
+		}
+		if err = s.save(buckets); err != nil {
+			logger.Error("分组访问统计落库失败", logger.Err(err), logger.Int("buckets", len(buckets)))
+			if err = cache.GroupStats().Requeue(context.Background(), buckets); err != nil {
+				logger.Error("分组访问统计放回待落库集合失败", logger.Err(err))
+			}
+			return
+		}
+		if len(buckets) < groupStatsFlushBatchSize {
+			return
+		}
+	}
+}
+
+func (s *groupStatsService) save(buckets []string) error {
+	stats, err := cache.GroupStats().Load(s.ctx, buckets)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	return s.statsDao.Save(s.ctx, stats)
+}
+
+func (s *groupStatsService) Stop() error {
+	s.cancel()
+	return nil
+}
+
+func (s *groupStatsService) String() string {
+	return "groupStatsService"
+}
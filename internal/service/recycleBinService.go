@@ -0,0 +1,67 @@
+package service
+
+import (
+	"SnapLink/internal/config"
+	"SnapLink/internal/dao"
+	"context"
+	"time"
+
+	"github.com/zhufuyi/sponge/pkg/app"
+	"github.com/zhufuyi/sponge/pkg/logger"
+)
+
+var _ app.IServer = (*recycleBinService)(nil)
+
+// recycleBinService 定期彻底删除回收站中过期的短链接
+type recycleBinService struct {
+	retention time.Duration
+	interval  time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewRecycleBinService 新增回收站清理服务
+func NewRecycleBinService() app.IServer {
+	s := new(recycleBinService)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.retention = config.Get().RecycleBin.Retention()
+	s.interval = config.Get().RecycleBin.Interval()
+	return s
+}
+
+func (s *recycleBinService) Start() error {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.purge()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// purge 清理一次过期的短链接,失败时等待下一次清理
+func (s *recycleBinService) purge() {
+	purged, err := dao.ShortLinkDao().PurgeExpired(s.ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		logger.Error("清理回收站失败", logger.Err(err), logger.Int("purged", purged))
+		return
+	}
+	if purged > 0 {
+		logger.Info("清理回收站完成", logger.Int("purged", purged))
+	}
+}
+
+func (s *recycleBinService) Stop() error {
+	s.cancel()
+	return nil
+}
+
+func (s *recycleBinService) String() string {
+	return "recycleBinService"
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"SnapLink/internal/custom_err"
+	"SnapLink/internal/dao"
+	"SnapLink/internal/elasticsearch"
+	"SnapLink/internal/model"
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// syncShortLinkDocument 将短链接的最新状态同步到 ES
+// 多个消费者并发处理 binlog,同一短链接的事件可能乱序到达(例如移动分组时的先删后插),
+// 因此不直接使用事件中的数据,而是以数据库中的当前状态为准
+func syncShortLinkDocument(ctx context.Context, uri string) error {
+	sl, err := dao.ShortLinkDao().GetByURI(ctx, uri)
+	if err != nil {
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			return elasticsearch.DeleteShortLink(ctx, uri)
+		}
+		return err
+	}
+	group, err := dao.NewShortLinkGroupDao(model.GetDB()).GetByGid(ctx, sl.Gid)
+	if err != nil {
+		// 分组已经被删除的短链接不再建立索引,与重建索引时的处理一致
+		if errors.Is(err, custom_err.ErrRecordNotFound) {
+			return elasticsearch.DeleteShortLink(ctx, uri)
+		}
+		return err
+	}
+	linkTags, err := dao.NewTagDao(model.GetDB()).GetLinkTags(ctx, group.CUsername, []string{uri})
+	if err != nil {
+		return err
+	}
+	tags := make([]string, 0, len(linkTags[uri]))
+	for _, tag := range linkTags[uri] {
+		tags = append(tags, tag.Name)
+	}
+	return elasticsearch.IndexShortLink(ctx, elasticsearch.NewShortLinkDocument(sl, group.CUsername, tags))
+}
+
+// syncTaggedShortLinkDocuments 标签改名后,同步所有带有该标签的短链接
+func syncTaggedShortLinkDocuments(ctx context.Context, username string, tagID uint) error {
+	uris, err := dao.NewTagDao(model.GetDB()).URIsByTags(ctx, username, []uint{tagID})
+	if err != nil {
+		return err
+	}
+	for _, uri := range uris {
+		if err = syncShortLinkDocument(ctx, uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Package sso OIDC 单点登录,使用授权码模式与 PKCE
+// 身份提供方的配置在首次使用时获取,身份提供方不可用时不影响服务启动
+package sso
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrInvalidCode 授权码无效或已被使用
+	ErrInvalidCode = errors.New("invalid authorization code")
+	// ErrInvalidIDToken ID Token 校验失败
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+// Config 客户端配置
+type Config struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+}
+
+// Identity 身份提供方返回的用户身份
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Username      string // UsernameClaim 对应的声明,可能为空
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Client OIDC 客户端
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewClient 新建客户端,不会立即访问身份提供方
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+var (
+	defaultClient *Client
+	mu            sync.RWMutex
+)
+
+// Init 设置默认客户端,c 为 nil 时表示未启用单点登录
+func Init(c *Client) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultClient = c
+}
+
+// Default 默认客户端,未启用单点登录时返回 nil
+func Default() *Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultClient
+}
+
+// discover 获取身份提供方的配置,只缓存成功的结果
+func (c *Client) discover() (*oidc.Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return c.provider, nil
+	}
+	// provider 会在之后获取签名公钥时继续使用该 context,不能使用请求的 context
+	ctx := oidc.ClientContext(context.Background(), c.httpClient)
+	provider, err := oidc.NewProvider(ctx, c.cfg.Issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "discover oidc provider failed, issuer: "+c.cfg.Issuer)
+	}
+	c.provider = provider
+	return provider, nil
+}
+
+func (c *Client) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		RedirectURL:  c.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       c.cfg.Scopes,
+	}
+}
+
+// AuthCodeURL 身份提供方的授权地址,verifier 为 PKCE 的 code_verifier
+func (c *Client) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	provider, err := c.discover()
+	if err != nil {
+		return "", err
+	}
+	return c.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange 使用授权码换取令牌,校验 ID Token 与 nonce 后返回用户身份
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	provider, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	ctx = oidc.ClientContext(ctx, c.httpClient)
+	tok, err := c.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return nil, errors.Wrap(ErrInvalidCode, err.Error())
+		}
+		return nil, errors.Wrap(err, "exchange authorization code failed")
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidIDToken, "id_token is missing in token response")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: c.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidIDToken, err.Error())
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.Wrap(ErrInvalidIDToken, "nonce mismatch")
+	}
+
+	claims := map[string]interface{}{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, errors.Wrap(ErrInvalidIDToken, err.Error())
+	}
+	identity := &Identity{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	identity.Username, _ = claims[c.cfg.UsernameClaim].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Name, _ = claims["name"].(string)
+	return identity, nil
+}
@@ -0,0 +1,122 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key 令牌签名密钥,没有私钥的非对称密钥只用于校验
+type Key struct {
+	Kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey 新建 HS256 密钥
+func NewHMACKey(kid, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, errors.Errorf("empty secret, kid: %s", kid)
+	}
+	return &Key{Kid: kid, method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}, nil
+}
+
+// NewAsymmetricKey 根据 PEM 格式的私钥或公钥新建 RS256, EdDSA 密钥,privatePEM 为空时只用于校验
+func NewAsymmetricKey(kid, alg string, privatePEM, publicPEM []byte) (*Key, error) {
+	key := &Key{Kid: kid}
+	var err error
+	switch alg {
+	case AlgRS256:
+		key.method = jwt.SigningMethodRS256
+		if len(privatePEM) > 0 {
+			var private *rsa.PrivateKey
+			if private, err = jwt.ParseRSAPrivateKeyFromPEM(privatePEM); err != nil {
+				return nil, errors.Wrap(err, "parse rsa private key failed, kid: "+kid)
+			}
+			key.signKey, key.verifyKey = private, private.Public()
+		}
+		if len(publicPEM) > 0 {
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, errors.Wrap(err, "parse rsa public key failed, kid: "+kid)
+			}
+		}
+	case AlgEdDSA:
+		key.method = jwt.SigningMethodEdDSA
+		if len(privatePEM) > 0 {
+			var private crypto.PrivateKey
+			if private, err = jwt.ParseEdPrivateKeyFromPEM(privatePEM); err != nil {
+				return nil, errors.Wrap(err, "parse ed25519 private key failed, kid: "+kid)
+			}
+			key.signKey, key.verifyKey = private, private.(ed25519.PrivateKey).Public()
+		}
+		if len(publicPEM) > 0 {
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, errors.Wrap(err, "parse ed25519 public key failed, kid: "+kid)
+			}
+		}
+	default:
+		return nil, errors.Errorf("unsupported alg %s, kid: %s", alg, kid)
+	}
+	if key.verifyKey == nil {
+		return nil, errors.Errorf("neither private key nor public key is configured, kid: %s", kid)
+	}
+	return key, nil
+}
+
+// canSign 密钥是否可以用于签发令牌
+func (k *Key) canSign() bool {
+	return k.signKey != nil
+}
+
+// JWK 公钥的 JWK 格式,供其他服务校验令牌
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS 全部非对称密钥的公钥,HS256 密钥不对外公开
+func JWKS() map[string][]*JWK {
+	keys := make([]*JWK, 0, len(opt.keys))
+	for _, key := range opt.keys {
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, &JWK{
+				Kty: "RSA",
+				Kid: key.Kid,
+				Alg: AlgRS256,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, &JWK{
+				Kty: "OKP",
+				Kid: key.Kid,
+				Alg: AlgEdDSA,
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return map[string][]*JWK{"keys": keys}
+}
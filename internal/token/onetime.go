@@ -0,0 +1,59 @@
+package token
+
+import (
+	"SnapLink/internal/cache"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 一次性令牌的用途
+const (
+	PurposeVerifyMail    = "verify_mail"
+	PurposeResetPassword = "reset_password"
+)
+
+// NewOneTime 签发一次性令牌,value 为令牌使用时返回的内容
+// 令牌格式为 随机 id.签名,签名错误的令牌不会访问 Redis
+func NewOneTime(ctx context.Context, purpose, username, value string, ttl time.Duration) (string, error) {
+	if len(opt.oneTimeSecret) == 0 {
+		return "", errors.New("one-time token secret is not configured")
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "generate one-time token failed")
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	if err := cache.OneTimeToken().Save(ctx, purpose, username, id, value, ttl); err != nil {
+		return "", err
+	}
+	return id + "." + signOneTime(purpose, id), nil
+}
+
+// ConsumeOneTime 使用一次性令牌,返回签发时保存的内容;令牌无效、已过期或已被使用时返回 ErrInvalidToken
+func ConsumeOneTime(ctx context.Context, purpose, tokenString string) (string, error) {
+	id, signature, ok := strings.Cut(tokenString, ".")
+	if !ok || len(opt.oneTimeSecret) == 0 || !hmac.Equal([]byte(signature), []byte(signOneTime(purpose, id))) {
+		return "", ErrInvalidToken
+	}
+	value, ok, err := cache.OneTimeToken().Consume(ctx, purpose, id)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	return value, nil
+}
+
+func signOneTime(purpose, id string) string {
+	mac := hmac.New(sha256.New, opt.oneTimeSecret)
+	mac.Write([]byte(purpose + ":" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
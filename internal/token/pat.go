@@ -0,0 +1,123 @@
+package token
+
+import (
+	"SnapLink/internal/dao"
+	"SnapLink/internal/model"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zhufuyi/sponge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌的格式为 PATPrefix + 两位分表序号 + 随机串
+const (
+	PATPrefix = "snp_"
+	// TypePAT 个人访问令牌的令牌类型
+	TypePAT = "pat"
+	// patTouchInterval 更新最近使用时间的最小间隔,避免每次请求都写数据库
+	patTouchInterval = time.Minute
+)
+
+// 个人访问令牌的权限范围,登录签发的访问令牌不受权限范围限制
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+)
+
+// Scopes 全部权限范围
+var Scopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead}
+
+// ValidScope 是否为支持的权限范围
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPAT 令牌是否为个人访问令牌
+func IsPAT(tokenString string) bool {
+	return strings.HasPrefix(tokenString, PATPrefix)
+}
+
+// HasScope 令牌是否拥有权限范围,只有个人访问令牌受权限范围限制
+func (c *Claims) HasScope(scope string) bool {
+	if c.Type != TypePAT {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPAT 生成个人访问令牌,返回令牌明文与待保存的令牌,明文只在创建时返回给用户
+func NewPAT(username, name string, scopes []string, expiresAt *time.Time) (string, *model.AccessToken, error) {
+	t := &model.AccessToken{
+		Name:      name,
+		CUsername: username,
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, errors.Wrap(err, "generate personal access token failed")
+	}
+	plain := fmt.Sprintf("%s%02d%s", PATPrefix, t.Shard(), base64.RawURLEncoding.EncodeToString(buf))
+	t.Prefix = plain[:len(PATPrefix)+8]
+	t.TokenHash = hashPAT(plain)
+	return plain, t, nil
+}
+
+// ParsePAT 校验个人访问令牌并记录使用情况,ip 为请求方地址
+func ParsePAT(ctx context.Context, tokenString, ip string) (*Claims, error) {
+	if !IsPAT(tokenString) || len(tokenString) < len(PATPrefix)+3 {
+		return nil, ErrInvalidToken
+	}
+	shard, err := strconv.Atoi(tokenString[len(PATPrefix) : len(PATPrefix)+2])
+	if err != nil || shard < 0 || shard >= model.AccessTokenShardingNum {
+		return nil, ErrInvalidToken
+	}
+	t, err := dao.AccessTokenDao().GetByHash(ctx, shard, hashPAT(tokenString))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= patTouchInterval || t.LastUsedIP != ip {
+		// 使用记录更新失败不影响本次请求
+		if err = dao.AccessTokenDao().Touch(ctx, t, now, ip); err != nil {
+			logger.Warn("更新个人访问令牌使用记录失败", logger.Err(err), logger.Uint("id", t.ID))
+		}
+	}
+	claims := &Claims{UID: t.CUsername, Type: TypePAT}
+	claims.ID = strconv.FormatUint(uint64(t.ID), 10)
+	if t.Scopes != "" {
+		claims.Scopes = strings.Split(t.Scopes, ",")
+	}
+	return claims, nil
+}
+
+func hashPAT(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])
+}
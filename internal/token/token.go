@@ -0,0 +1,250 @@
+// Package token 登录令牌的签发、解析与吊销
+// 登录时签发短期的访问令牌与长期的刷新令牌,两者属于同一个令牌族;刷新令牌每次使用后轮换,
+// 已经轮换过的刷新令牌被再次使用时视为泄露,整个令牌族失效
+package token
+
+import (
+	"SnapLink/internal/cache"
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// 令牌类型
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	// ErrInvalidToken 令牌格式错误、签名错误、已过期或类型不符
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked 令牌已被吊销
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrTokenReused 已经轮换过的刷新令牌被再次使用,令牌族已失效
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// Claims 令牌中的声明,ID 为令牌 id
+type Claims struct {
+	UID    string `json:"uid"`
+	Role   string `json:"role"`
+	Type   string `json:"typ"`
+	Family string `json:"fam"`
+	// Scopes 个人访问令牌的权限范围,不会出现在 JWT 中
+	Scopes []string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// Pair 登录与刷新时返回的令牌
+type Pair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 访问令牌的有效期,单位(秒)
+}
+
+type options struct {
+	keys          map[string]*Key
+	active        *Key
+	accessExpire  time.Duration
+	refreshExpire time.Duration
+	oneTimeSecret []byte
+}
+
+var opt = &options{
+	accessExpire:  15 * time.Minute,
+	refreshExpire: 7 * 24 * time.Hour,
+}
+
+// Option 令牌配置
+type Option func(*options)
+
+// WithKeys 用于校验令牌的全部密钥,activeKid 为签发令牌使用的密钥
+func WithKeys(activeKid string, keys ...*Key) Option {
+	return func(o *options) {
+		o.keys = make(map[string]*Key, len(keys))
+		for _, key := range keys {
+			o.keys[key.Kid] = key
+		}
+		o.active = o.keys[activeKid]
+	}
+}
+
+// WithAccessExpire 访问令牌的有效期
+func WithAccessExpire(d time.Duration) Option {
+	return func(o *options) {
+		o.accessExpire = d
+	}
+}
+
+// WithRefreshExpire 刷新令牌的有效期,也是令牌族的有效期
+func WithRefreshExpire(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshExpire = d
+	}
+}
+
+// WithOneTimeSecret 一次性令牌的签名密钥
+func WithOneTimeSecret(secret string) Option {
+	return func(o *options) {
+		o.oneTimeSecret = []byte(secret)
+	}
+}
+
+// Init 初始化令牌配置,签发令牌使用的密钥不存在或没有私钥时返回错误
+func Init(opts ...Option) error {
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.active == nil || !opt.active.canSign() {
+		return errors.New("active signing key is not configured")
+	}
+	return nil
+}
+
+// Issue 登录时签发一对新的令牌
+func Issue(ctx context.Context, uid, role string) (*Pair, error) {
+	family := uuid.NewString()
+	pair, refreshID, err := issuePair(uid, role, family)
+	if err != nil {
+		return nil, err
+	}
+	if err = cache.Token().CreateFamily(ctx, uid, family, refreshID, opt.refreshExpire); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh 使用刷新令牌换取一对新的令牌,旧的刷新令牌随即失效
+func Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	claims, err := Parse(ctx, refreshToken, TypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	pair, refreshID, err := issuePair(claims.UID, claims.Role, claims.Family)
+	if err != nil {
+		return nil, err
+	}
+	result, err := cache.Token().RotateRefresh(ctx, claims.UID, claims.Family, claims.ID, refreshID, opt.refreshExpire)
+	if err != nil {
+		return nil, err
+	}
+	switch result {
+	case cache.RefreshReused:
+		return nil, ErrTokenReused
+	case cache.RefreshNotFound:
+		return nil, ErrTokenRevoked
+	}
+	return pair, nil
+}
+
+// Parse 解析并校验令牌,typ 为期望的令牌类型
+func Parse(ctx context.Context, tokenString, typ string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(tokenString, claims, verifyKey,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+	if claims.Type != typ || claims.ID == "" || claims.Family == "" {
+		return nil, ErrInvalidToken
+	}
+	active, err := cache.Token().IsActive(ctx, claims.ID, claims.Family)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// Revoke 吊销访问令牌及其所在的令牌族,用于登出
+func Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ExpiresAt != nil {
+		if err := cache.Token().Revoke(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+			return err
+		}
+	}
+	return cache.Token().RevokeFamily(ctx, claims.UID, claims.Family)
+}
+
+// RevokeAll 吊销用户的全部令牌,用于在全部设备上登出
+func RevokeAll(ctx context.Context, uid string) error {
+	return cache.Token().RevokeAllFamilies(ctx, uid)
+}
+
+// issuePair 签发一对令牌,返回刷新令牌的 id
+func issuePair(uid, role, family string) (*Pair, string, error) {
+	now := time.Now()
+	access, err := sign(&Claims{UID: uid, Role: role, Type: TypeAccess, Family: family}, now, opt.accessExpire)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshClaims := &Claims{UID: uid, Role: role, Type: TypeRefresh, Family: family}
+	refresh, err := sign(refreshClaims, now, opt.refreshExpire)
+	if err != nil {
+		return nil, "", err
+	}
+	return &Pair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(opt.accessExpire.Seconds()),
+	}, refreshClaims.ID, nil
+}
+
+func sign(claims *Claims, now time.Time, expire time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   claims.UID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expire)),
+	}
+	t := jwt.NewWithClaims(opt.active.method, claims)
+	t.Header["kid"] = opt.active.Kid
+	signed, err := t.SignedString(opt.active.signKey)
+	if err != nil {
+		return "", errors.Wrap(err, "sign token failed")
+	}
+	return signed, nil
+}
+
+// verifyKey 根据令牌头部的 kid 查找校验密钥,签名算法必须与密钥一致
+// 没有 kid 的令牌为支持多密钥之前签发的,使用当前签发令牌的密钥校验
+func verifyKey(t *jwt.Token) (interface{}, error) {
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		kid = opt.active.Kid
+	}
+	key, ok := opt.keys[kid]
+	if !ok {
+		return nil, errors.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, errors.Errorf("alg %s does not match key %q", t.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+const claimsKey = "token_claims"
+
+// SetClaims 保存通过校验的访问令牌,由鉴权中间件调用
+func SetClaims(c *gin.Context, claims *Claims) {
+	c.Set(claimsKey, claims)
+	c.Set("uid", claims.UID)
+}
+
+// FromContext 获取鉴权中间件保存的访问令牌,仅在需要登录的接口中可用
+func FromContext(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
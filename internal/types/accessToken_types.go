@@ -0,0 +1,27 @@
+package types
+
+// AccessTokenCreateReq 创建个人访问令牌请求参数
+type AccessTokenCreateReq struct {
+	Name   string   `json:"name" binding:"required,max=64"`
+	Scopes []string `json:"scopes" binding:"required,min=1"` // links:read, links:write, stats:read
+	// 有效天数,为 0 时永不过期
+	ExpiresInDays int `json:"expiresInDays" binding:"min=0,max=365"`
+}
+
+// AccessTokenCreateRes 创建个人访问令牌的结果,令牌明文只在创建时返回一次
+type AccessTokenCreateRes struct {
+	AccessTokenItem
+	Token string `json:"token"`
+}
+
+// AccessTokenItem 个人访问令牌列表项
+type AccessTokenItem struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"createTime"`
+	ExpiresAt  string   `json:"expiresAt"`  // 为空时永不过期
+	LastUsedAt string   `json:"lastUsedAt"` // 为空时从未使用
+	LastUsedIP string   `json:"lastUsedIp"`
+}
@@ -0,0 +1,64 @@
+package types
+
+// GroupStatisticResponse 分组访问统计
+// 汇总的 uv 与 uip 为每天去重数之和,同一访客在不同日期的访问会被重复计数
+type GroupStatisticResponse struct {
+	Gid        string                     `json:"gid"`
+	StartDate  string                     `json:"startDate"`
+	EndDate    string                     `json:"endDate"`
+	Pv         int64                      `json:"pv"`
+	Uv         int64                      `json:"uv"`
+	Uip        int64                      `json:"uip"`
+	Daily      []*GroupStatisticPeriod    `json:"daily"`
+	Hourly     []*GroupStatisticPeriod    `json:"hourly"`
+	TopLinks   []*GroupStatisticLink      `json:"topLinks"`
+	TopRegions []*GroupStatisticDimension `json:"topRegions"`
+	TopDevices []*GroupStatisticDimension `json:"topDevices"`
+}
+
+// GroupStatisticPeriod 一天或一小时的访问统计,按天统计时 hour 为 -1
+type GroupStatisticPeriod struct {
+	Date string `json:"date"`
+	Hour int    `json:"hour"`
+	Pv   int64  `json:"pv"`
+	Uv   int64  `json:"uv"`
+	Uip  int64  `json:"uip"`
+}
+
+// GroupStatisticLink 分组内短链接的访问统计
+type GroupStatisticLink struct {
+	Uri      string `json:"uri"`
+	ShortUrl string `json:"shortUrl"`
+	Pv       int64  `json:"pv"`
+	Uv       int64  `json:"uv"`
+	Uip      int64  `json:"uip"`
+}
+
+// GroupStatisticDimension 地区或设备的访问量
+type GroupStatisticDimension struct {
+	Name string `json:"name"`
+	Pv   int64  `json:"pv"`
+}
+
+// GroupTreeStatisticResponse 分组及其全部子分组的汇总访问统计
+// uv 与 uip 为各分组每天去重数之和
+type GroupTreeStatisticResponse struct {
+	Gid       string                  `json:"gid"`
+	StartDate string                  `json:"startDate"`
+	EndDate   string                  `json:"endDate"`
+	Pv        int64                   `json:"pv"`
+	Uv        int64                   `json:"uv"`
+	Uip       int64                   `json:"uip"`
+	Daily     []*GroupStatisticPeriod `json:"daily"`
+	Groups    []*GroupStatisticGroup  `json:"groups"`
+}
+
+// GroupStatisticGroup 子树中单个分组的汇总访问统计
+type GroupStatisticGroup struct {
+	Gid       string `json:"gid"`
+	Name      string `json:"name"`
+	ParentGid string `json:"parentGid"`
+	Pv        int64  `json:"pv"`
+	Uv        int64  `json:"uv"`
+	Uip       int64  `json:"uip"`
+}
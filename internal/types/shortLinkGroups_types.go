@@ -8,8 +8,10 @@ import (
 // 命名规则： Handler+Action+Res/Req
 
 // ShortLinkGroupCreateReq 创建短链接分组请求参数
+// parentGid 为空时创建顶层分组
 type ShortLinkGroupCreateReq struct {
-	Name string `json:"name" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	ParentGid string `json:"parentGid"`
 }
 
 // ShortLinkGroupUpdateByGIDReq 更新短链接分组请求参数
@@ -24,6 +26,20 @@ type ShortLinkGroupUpdateSortOrderReq struct {
 	SortOrder int    `json:"sort_order" binding:"required"`
 }
 
+// ShortLinkGroupMoveReq 移动分组请求参数,parentGid 为空时移动到顶层
+type ShortLinkGroupMoveReq struct {
+	Gid       string `json:"gid" binding:"required"`
+	ParentGid string `json:"parentGid"`
+}
+
+// ShortLinkGroupDeleteReq 删除短链接分组请求参数
+// strategy 为分组内短链接的处理方式: move 移动到 targetGid 分组(为空时为默认分组), disable 全部停用, delete 全部删除
+type ShortLinkGroupDeleteReq struct {
+	Gid       string `form:"gid" binding:"required"`
+	Strategy  string `form:"strategy" binding:"required,oneof=move disable delete"`
+	TargetGid string `form:"targetGid"`
+}
+
 // ShortLinkGroupListItem 分组信息(过滤后)
 type ShortLinkGroupListItem struct {
 	ID        uint      `json:"id,omitempty"`
@@ -32,6 +48,9 @@ type ShortLinkGroupListItem struct {
 	SortOrder int       `json:"sort_order,omitempty"`
 	Gid       string    `json:"gid,omitempty"`
 	Name      string    `json:"name,omitempty"`
+	IsDefault bool      `json:"isDefault"`
+	ParentGid string    `json:"parentGid"`
+	Role      string    `json:"role"` // 当前用户在分组中的角色
 	Count     int       `json:"count"`
 }
 
@@ -43,6 +62,7 @@ type ShortLinkGroupListRes struct {
 func NewShortLinkGroupListItem(data map[string]any) *ShortLinkGroupListItem {
 	group := data["group"].(*model.ShortLinkGroup)
 	count := data["count"].(int64)
+	role, _ := data["role"].(string)
 	res := &ShortLinkGroupListItem{
 		ID:        group.ID,
 		CreatedAt: group.CreatedAt,
@@ -50,7 +70,64 @@ func NewShortLinkGroupListItem(data map[string]any) *ShortLinkGroupListItem {
 		SortOrder: group.SortOrder,
 		Gid:       group.Gid,
 		Name:      group.Name,
+		IsDefault: group.IsDefault,
+		ParentGid: group.ParentGid,
+		Role:      role,
 		Count:     int(count),
 	}
 	return res
 }
+
+// ShortLinkGroupTreeNode 分组树中的节点
+// count 为分组自身的短链接数量,totalCount 包括全部子分组
+type ShortLinkGroupTreeNode struct {
+	Gid        string                    `json:"gid"`
+	Name       string                    `json:"name"`
+	ParentGid  string                    `json:"parentGid"`
+	IsDefault  bool                      `json:"isDefault"`
+	Role       string                    `json:"role"`
+	Depth      int                       `json:"depth"`
+	Count      int64                     `json:"count"`
+	TotalCount int64                     `json:"totalCount"`
+	Children   []*ShortLinkGroupTreeNode `json:"children"`
+}
+
+// GroupMemberReq 邀请成员与修改成员角色的请求参数,分组创建人即为 owner,不能邀请
+type GroupMemberReq struct {
+	Gid      string `json:"gid" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+// GroupInvitationReq 接受或拒绝邀请的请求参数
+type GroupInvitationReq struct {
+	Gid string `json:"gid" binding:"required"`
+}
+
+// GroupInvitationItem 待接受的邀请
+type GroupInvitationItem struct {
+	Gid       string    `json:"gid"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Role      string    `json:"role"`
+	Inviter   string    `json:"inviter"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GroupSettingsReq 修改分组默认设置的请求参数
+// applyToExisting 为 true 时同时将设置应用到分组内已有的短链接
+type GroupSettingsReq struct {
+	Gid             string `json:"gid" binding:"required"`
+	ExpireDays      int    `json:"expireDays" binding:"min=0"`
+	RedirectCode    int    `json:"redirectCode" binding:"omitempty,oneof=301 302 307 308"`
+	UtmTemplate     string `json:"utmTemplate"`
+	QueryMode       string `json:"queryMode" binding:"omitempty,oneof=drop append override"`
+	ApplyToExisting bool   `json:"applyToExisting"`
+}
+
+// GroupSettingsRes 修改分组默认设置的响应
+type GroupSettingsRes struct {
+	Settings model.GroupSettings `json:"settings"`
+	Applied  int                 `json:"applied"` // 应用到已有短链接的数量
+	Failed   int                 `json:"failed"`
+}
@@ -1,15 +1,21 @@
 package types
 
+import "SnapLink/internal/model"
+
 // CreateShortLinkRequest 创建短链接请求参数
 type CreateShortLinkRequest struct {
 	OriginUrl string `json:"originUrl" binding:"required"`
-	Gid       string `json:"gid" binding:"required"`
+	Gid       string `json:"gid"` // 为空时使用默认分组
 	// 0 为 api 创建,1 为控制台创建
 	CreatedType int    `json:"createdType"`
 	ValidDate   string `json:"validDate"`
-	// 0 为 永不过期,1 为指定时间过期
-	ValidDateType int    `json:"validDateType"`
+	// 0 为 永不过期,1 为指定时间过期,为空时继承分组的有效期设置
+	ValidDateType *int   `json:"validDateType" binding:"omitempty,oneof=0 1"`
 	Description   string `json:"describe" binding:"required"`
+	// 以下字段为空时继承分组的默认设置
+	RedirectCode int    `json:"redirectCode" binding:"omitempty,oneof=301 302 307 308"`
+	UtmTemplate  string `json:"utmTemplate"`
+	QueryMode    string `json:"queryMode" binding:"omitempty,oneof=drop append override"`
 }
 
 type UpdateShortLinkRequest struct {
@@ -19,24 +25,32 @@ type UpdateShortLinkRequest struct {
 	ValidDate     string `json:"validDate"`
 	ValidDateType int    `json:"validDateType"`
 	Description   string `json:"describe"`
+	Enable        *int   `json:"enable" binding:"omitempty,oneof=0 1"` // 为空时不修改
 }
 
 // ShortLinkRecord 短链接详情
 type ShortLinkRecord struct {
-	CreatedAt     string `json:"createTime"`
-	OriginUrl     string `json:"originUrl"`
-	ShortUrl      string `json:"shortUrl"`
-	ValidDateType int    `json:"validDateType"`
-	ValidDate     string `json:"validDate"`
-	Describe      string `json:"describe"`
-	TodayPV       int    `json:"todayPV"`
-	TotalPV       int    `json:"totalPV"`
-	TodayUV       int    `json:"todayUV"`
-	TotalUV       int    `json:"totalUV"`
-	TodayUIP      int    `json:"todayUIP"`
-	TotalUIP      int    `json:"totalUIP"`
+	CreatedAt     string     `json:"createTime"`
+	OriginUrl     string     `json:"originUrl"`
+	ShortUrl      string     `json:"shortUrl"`
+	ValidDateType int        `json:"validDateType"`
+	ValidDate     string     `json:"validDate"`
+	Describe      string     `json:"describe"`
+	RedirectCode  int        `json:"redirectCode"`
+	UtmTemplate   string     `json:"utmTemplate"`
+	QueryMode     string     `json:"queryMode"`
+	TodayPV       int        `json:"todayPV"`
+	TotalPV       int        `json:"totalPV"`
+	TodayUV       int        `json:"todayUV"`
+	TotalUV       int        `json:"totalUV"`
+	TodayUIP      int        `json:"todayUIP"`
+	TotalUIP      int        `json:"totalUIP"`
+	Tags          []*TagItem `json:"tags"`
 }
 
+// OrderTagCreateTime 按创建时间倒序排列,其余基于访问统计的排序方式见 cache.OrderTagTodayPV 等
+const OrderTagCreateTime = "createTime"
+
 // ListShortLinkResponse 短链接列表响应
 type ListShortLinkResponse struct {
 	Total    int64              `json:"total"`
@@ -45,3 +59,129 @@ type ListShortLinkResponse struct {
 	OrderTag string             `json:"orderTag"`
 	Records  []*ShortLinkRecord `json:"records"`
 }
+
+// ImportShortLinkResponse 导入短链接响应
+type ImportShortLinkResponse struct {
+	Total     int                 `json:"total"`
+	Imported  int                 `json:"imported"`
+	Conflicts []*ImportItemResult `json:"conflicts"`
+	Failures  []*ImportItemResult `json:"failures"`
+}
+
+// ImportItemResult 导入失败的记录
+type ImportItemResult struct {
+	Row    int    `json:"row"`
+	Code   string `json:"code"`
+	Url    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// SearchShortLinkRecord 短链接搜索结果
+type SearchShortLinkRecord struct {
+	Gid       string   `json:"gid"`
+	Uri       string   `json:"uri"`
+	ShortUrl  string   `json:"shortUrl"`
+	OriginUrl string   `json:"originUrl"`
+	Domain    string   `json:"domain"`
+	Describe  string   `json:"describe"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"createTime"`
+}
+
+// SearchShortLinkResponse 短链接搜索响应
+type SearchShortLinkResponse struct {
+	Total   int64                    `json:"total"`
+	Size    int                      `json:"size"`
+	Current int                      `json:"current"`
+	Records []*SearchShortLinkRecord `json:"records"`
+}
+
+// RecycledShortLinkRecord 回收站中的短链接
+type RecycledShortLinkRecord struct {
+	Gid       string `json:"gid"`
+	Uri       string `json:"uri"`
+	ShortUrl  string `json:"shortUrl"`
+	OriginUrl string `json:"originUrl"`
+	Describe  string `json:"describe"`
+	CreatedAt string `json:"createTime"`
+	DeletedAt string `json:"deleteTime"`
+	ExpireAt  string `json:"expireTime"` // 超过该时间后将被彻底删除
+}
+
+// ListRecycledShortLinkResponse 回收站列表响应
+type ListRecycledShortLinkResponse struct {
+	Total   int64                      `json:"total"`
+	Size    int                        `json:"size"`
+	Current int                        `json:"current"`
+	Records []*RecycledShortLinkRecord `json:"records"`
+}
+
+// RestoreShortLinkRequest 恢复短链接请求参数
+type RestoreShortLinkRequest struct {
+	Uri string `json:"uri" binding:"required"`
+}
+
+// RestoreShortLinkResponse 恢复短链接的结果
+type RestoreShortLinkResponse struct {
+	Gid string `json:"gid"` // 恢复到的分组,删除前所在的分组已被删除时为创建人的默认分组
+}
+
+// ShortLinkHistoryRecord 短链接的一次修改
+type ShortLinkHistoryRecord struct {
+	ID        uint                    `json:"id"`
+	Actor     string                  `json:"actor"`
+	Action    string                  `json:"action"`
+	Before    model.ShortLinkSnapshot `json:"before"`
+	After     model.ShortLinkSnapshot `json:"after"`
+	CreatedAt string                  `json:"createTime"`
+}
+
+// ListShortLinkHistoryResponse 短链接修改历史响应
+type ListShortLinkHistoryResponse struct {
+	Total   int64                     `json:"total"`
+	Size    int                       `json:"size"`
+	Current int                       `json:"current"`
+	Records []*ShortLinkHistoryRecord `json:"records"`
+}
+
+// 批量操作的类型
+const (
+	BulkActionEnable  = "enable"
+	BulkActionDisable = "disable"
+	BulkActionExpire  = "expire"
+	BulkActionMove    = "move"
+	BulkActionDelete  = "delete"
+)
+
+// BulkShortLinkFilter 批量操作的筛选条件,与 uris 二选一
+type BulkShortLinkFilter struct {
+	Gid         string `json:"gid" binding:"required"`
+	TagIDs      []uint `json:"tagIds"`      // 同时拥有全部标签的短链接
+	CreatedFrom string `json:"createdFrom"` // 创建时间下限(包含),格式 2006-01-02 15:04:05
+	CreatedTo   string `json:"createdTo"`   // 创建时间上限(不包含),格式 2006-01-02 15:04:05
+}
+
+// BulkShortLinkRequest 批量操作请求参数
+type BulkShortLinkRequest struct {
+	Action        string               `json:"action" binding:"required,oneof=enable disable expire move delete"`
+	Uris          []string             `json:"uris"`
+	Filter        *BulkShortLinkFilter `json:"filter"`
+	ValidDateType int                  `json:"validDateType"` // action 为 expire 时有效
+	ValidDate     string               `json:"validDate"`     // action 为 expire 时有效
+	TargetGid     string               `json:"targetGid"`     // action 为 move 时有效
+}
+
+// BulkItemResult 批量操作中单个短链接的结果
+type BulkItemResult struct {
+	Uri     string `json:"uri"`
+	Success bool   `json:"success"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// BulkShortLinkResponse 批量操作响应
+type BulkShortLinkResponse struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []*BulkItemResult `json:"results"`
+}
@@ -0,0 +1,49 @@
+package types
+
+// TagCreateReq 创建标签请求参数
+type TagCreateReq struct {
+	Name  string `json:"name" binding:"required,max=32"`
+	Color string `json:"color" binding:"max=16"`
+}
+
+// TagUpdateReq 更新标签请求参数
+type TagUpdateReq struct {
+	ID    uint   `json:"id" binding:"required"`
+	Name  string `json:"name" binding:"required,max=32"`
+	Color string `json:"color" binding:"max=16"`
+}
+
+// TagItem 短链接上的标签
+type TagItem struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// TagListItem 标签列表项
+type TagListItem struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	LinkCount int64  `json:"linkCount"`
+	CreatedAt string `json:"createTime"`
+}
+
+// TagStatsRes 标签下全部短链接的汇总统计,uv 与 uip 为去重后的数量
+type TagStatsRes struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	LinkCount int64  `json:"linkCount"`
+	TodayPV   int64  `json:"todayPV"`
+	TotalPV   int64  `json:"totalPV"`
+	TodayUV   int64  `json:"todayUV"`
+	TotalUV   int64  `json:"totalUV"`
+	TodayUIP  int64  `json:"todayUIP"`
+	TotalUIP  int64  `json:"totalUIP"`
+}
+
+// ShortLinkSetTagsReq 设置短链接标签请求参数,tagIds 为空时清空短链接的标签
+type ShortLinkSetTagsReq struct {
+	Uri    string `json:"uri" binding:"required"`
+	TagIDs []uint `json:"tagIds"`
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"github.com/zhufuyi/sponge/pkg/ggorm/query"
+)
+
 // GetByUsernameDesensitizationRespond 脱敏数据返回
 type GetByUsernameDesensitizationRespond struct {
 	Username string `json:"username"`
@@ -25,12 +31,17 @@ type RegisterRespond struct {
 	Mail     string `json:"mail"`
 }
 
-// LoginRequest 用户登录请求
+// LoginRequest 用户登录请求,username 可以是用户名、邮箱或手机号
 type LoginRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=11"`
+	Username string `json:"username" binding:"required,min=3,max=50"`
 	Password string `json:"password" binding:"required,min=6,max=15"`
 }
 
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
 // UpdateInfoRequest 用户修改请求
 type UpdateInfoRequest struct {
 	Password string `json:"password" binding:""`
@@ -46,3 +57,90 @@ type UpdateInfoRespond struct {
 	Phone    string `json:"phone"`
 	Mail     string `json:"mail"`
 }
+
+// VerifyMailRequest 验证邮箱请求
+type VerifyMailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordResetRequest 申请重置密码请求
+type PasswordResetRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=11"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=15"`
+}
+
+// LoginChallengeRespond 启用两步验证的用户登录时返回的挑战令牌,使用挑战令牌与验证码完成登录
+type LoginChallengeRespond struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int64  `json:"expiresIn"` // 挑战令牌的有效期,单位(秒)
+}
+
+// SSOAuthorizeRespond 身份提供方的授权地址,前端跳转到该地址完成登录
+type SSOAuthorizeRespond struct {
+	AuthURL string `json:"authUrl"`
+}
+
+// SSOCallbackRequest 身份提供方回调前端时携带的参数
+type SSOCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// SSOLinkRespond 关联身份的结果
+type SSOLinkRespond struct {
+	Username string `json:"username"`
+	Issuer   string `json:"issuer"`
+}
+
+// TwoFactorLoginRequest 两步验证登录请求,code 为验证器应用中的 6 位验证码或恢复码
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollRespond 两步验证密钥,uri 用于生成验证器应用扫描的二维码
+type TwoFactorEnrollRespond struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorCodeRequest 两步验证码,可以是 6 位验证码或恢复码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesRespond 两步验证恢复码,只在生成时返回一次
+type RecoveryCodesRespond struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// ListUsersRequest 管理员查询用户列表请求,跨全部分表查询
+// 查询条件支持 username, real_name, phone, mail, role 字段,排序支持 username, real_name, role, created_at, updated_at 字段;
+// page 从 0 开始,(page+1)*size 不能超过 1000
+type ListUsersRequest struct {
+	query.Params
+}
+
+// UserListItem 用户列表中的用户信息
+type UserListItem struct {
+	Username     string    `json:"username"`
+	RealName     string    `json:"realName"`
+	Phone        string    `json:"phone"`
+	Mail         string    `json:"mail"`
+	Role         string    `json:"role"`
+	MailVerified bool      `json:"mailVerified"`
+	TOTPEnabled  bool      `json:"totpEnabled"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ListUsersRespond 用户列表
+type ListUsersRespond struct {
+	Users []*UserListItem `json:"users"`
+	Total int64           `json:"total"`
+}
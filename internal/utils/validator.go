@@ -1,6 +1,9 @@
 package utils
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 const (
 	phoneReg       = `^1[3456789]\d{9}$`
@@ -40,3 +43,17 @@ func InvalidCharCheck(str string) bool {
 	}
 	return true
 }
+
+// NormalizeMail 邮箱归一化,去除首尾空白并转为小写,用于判断邮箱是否重复
+func NormalizeMail(mail string) string {
+	return strings.ToLower(strings.TrimSpace(mail))
+}
+
+// NormalizePhone 手机号归一化为 e164 格式,去除空白与连字符,不带国家码的大陆手机号补充 +86,用于判断手机号是否重复
+func NormalizePhone(phone string) string {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+	if IsPhone(phone) {
+		return "+86" + phone
+	}
+	return phone
+}
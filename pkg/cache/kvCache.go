@@ -25,6 +25,7 @@ type IKVCache interface {
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
 	SetCacheWithNotFound(ctx context.Context, key string, ttl time.Duration) error
 	Del(ctx context.Context, key string) error
+	MDel(ctx context.Context, keys ...string) error
 }
 
 // kvCache define a cache struct
@@ -122,3 +123,22 @@ func (c *kvCache) Del(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+// MDel 批量删除数据键值,用于批量操作后的缓存失效
+func (c *kvCache) MDel(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	fullKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = c.keyGen(key)
+		if c.localCache != nil {
+			c.localCache.Del(key)
+		}
+		fullKeys = append(fullKeys, key)
+	}
+	if err := c.client.Del(ctx, fullKeys...).Err(); err != nil {
+		return errors.Wrap(ErrKVCacheDelFailed, err.Error())
+	}
+	return nil
+}
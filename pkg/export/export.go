@@ -0,0 +1,158 @@
+// Package export 提供流式导出使用的行写入器,目前支持 CSV 与 NDJSON 两种格式
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// FormatCSV 逗号分隔,首行为表头
+	FormatCSV = "csv"
+	// FormatNDJSON 每行一个 JSON 对象
+	FormatNDJSON = "ndjson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	ErrColumnMismatch    = errors.New("values do not match columns")
+)
+
+// Writer 行写入器
+// 写入器内部带有缓冲,调用方需要在合适的时机调用 Flush 将数据真正写出,以实现边查边写的流式导出
+type Writer interface {
+	// WriteHeader 设置列名,必须在 Write 之前调用一次
+	WriteHeader(columns []string) error
+	// Write 写入一行数据,values 与列名一一对应
+	Write(values []any) error
+	// Flush 将缓冲区中的数据写出
+	Flush() error
+	// ContentType 对应的 HTTP Content-Type
+	ContentType() string
+	// Extension 导出文件的扩展名
+	Extension() string
+}
+
+// NewWriter 根据格式创建写入器
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns int
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	c.columns = len(columns)
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Write(values []any) error {
+	if len(values) != c.columns {
+		return ErrColumnMismatch
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatValue(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (c *csvWriter) Extension() string {
+	return FormatCSV
+}
+
+// formatValue 将单元格转换为字符串
+// 以 = + - @ 开头的字符串会被表格软件当作公式执行,此处加上单引号前缀来防御 CSV 注入
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if val != "" && strings.ContainsRune("=+-@", rune(val[0])) {
+			return "'" + val
+		}
+		return val
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns [][]byte
+}
+
+func (n *ndjsonWriter) WriteHeader(columns []string) error {
+	// 列名在每一行中重复使用,提前编码
+	n.columns = make([][]byte, len(columns))
+	for i, column := range columns {
+		b, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		n.columns[i] = b
+	}
+	return nil
+}
+
+// Write 手工拼接 JSON 对象,以保证字段顺序与列名顺序一致
+func (n *ndjsonWriter) Write(values []any) error {
+	if len(values) != len(n.columns) {
+		return ErrColumnMismatch
+	}
+	n.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.Format("2006-01-02 15:04:05")
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		n.w.Write(n.columns[i])
+		n.w.WriteByte(':')
+		n.w.Write(b)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.w.Flush()
+}
+
+func (n *ndjsonWriter) ContentType() string {
+	return "application/x-ndjson; charset=utf-8"
+}
+
+func (n *ndjsonWriter) Extension() string {
+	return FormatNDJSON
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCSVWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter("CSV", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteHeader([]string{"uri", "describe", "pv", "createdAt"}); err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	if err = w.Write([]any{"abc", "=HYPERLINK(\"x\")", 12, createdAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]any{"def", "a,b", 0, createdAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "uri,describe,pv,createdAt\n" +
+		"abc,\"'=HYPERLINK(\"\"x\"\")\",12,2024-05-01 08:30:00\n" +
+		"def,\"a,b\",0,2024-05-01 08:30:00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("csv output = %q, want %q", got, want)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(FormatNDJSON, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteHeader([]string{"uri", "pv", "enable"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]any{"abc", 3, true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]any{"d\"e", 0, false}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"uri\":\"abc\",\"pv\":3,\"enable\":true}\n" +
+		"{\"uri\":\"d\\\"e\",\"pv\":0,\"enable\":false}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("ndjson output = %q, want %q", got, want)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter("xlsx", new(bytes.Buffer)); err == nil {
+		t.Error("NewWriter(xlsx) should fail")
+	}
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		w, _ := NewWriter(format, new(bytes.Buffer))
+		_ = w.WriteHeader([]string{"a", "b"})
+		if err := w.Write([]any{"only one"}); err != ErrColumnMismatch {
+			t.Errorf("%s: Write() error = %v, want %v", format, err, ErrColumnMismatch)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvReader struct {
+	r *csv.Reader
+	// defaultColumns 文件没有表头时使用的列顺序
+	defaultColumns []string
+	fields         []int
+	row            int
+}
+
+func newCSVReader(r io.Reader, defaultColumns []string) *csvReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr, defaultColumns: defaultColumns}
+}
+
+func (c *csvReader) Next() (*Record, error) {
+	for {
+		values, err := c.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 1 && values[0] == "" {
+			continue
+		}
+		if c.fields == nil {
+			// 首行能识别出短链接和原始链接两列时视为表头
+			fields := fieldsOf(values)
+			if hasField(fields, fieldCode) && hasField(fields, fieldURL) {
+				c.fields = fields
+				continue
+			}
+			c.fields = fieldsOf(c.defaultColumns)
+		}
+		c.row++
+		return newRecord(c.row, c.fields, values)
+	}
+}
+
+func hasField(fields []int, field int) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Package importer 解析其他短链接服务导出的数据,用于迁移时保留原有的短链接
+// 目前支持:
+//   - csv: 通用 CSV,表头为 code,url,title,created_at
+//   - yourls-csv: YOURLS 导出的 CSV,表头为 keyword,url,title,timestamp,ip,clicks
+//   - yourls-sql: YOURLS 数据库(yourls_url 表)的 SQL 转储
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV       = "csv"
+	FormatYourlsCSV = "yourls-csv"
+	FormatYourlsSQL = "yourls-sql"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported import format")
+
+// Record 一条待导入的短链接
+type Record struct {
+	// Row 记录在源数据中的序号,从 1 开始,用于向用户报告问题所在的位置
+	Row       int
+	Code      string
+	URL       string
+	Title     string
+	CreatedAt time.Time
+}
+
+// Reader 逐条读取待导入的记录,读取完毕时返回 io.EOF
+type Reader interface {
+	Next() (*Record, error)
+}
+
+// NewReader 根据格式创建读取器
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return newCSVReader(r, []string{"code", "url", "title", "created_at"}), nil
+	case FormatYourlsCSV:
+		return newCSVReader(r, []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}), nil
+	case FormatYourlsSQL:
+		return newSQLReader(r), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// ReadAll 读取全部记录
+func ReadAll(r Reader) ([]*Record, error) {
+	var records []*Record
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// 不同来源对同一字段的命名不同,统一映射到 Record 的字段上
+const (
+	fieldCode = iota + 1
+	fieldURL
+	fieldTitle
+	fieldCreatedAt
+)
+
+var fieldAlias = map[string]int{
+	"code":       fieldCode,
+	"keyword":    fieldCode,
+	"url":        fieldURL,
+	"title":      fieldTitle,
+	"created_at": fieldCreatedAt,
+	"timestamp":  fieldCreatedAt,
+}
+
+// fieldsOf 将列名转换为字段,无法识别的列为 0,会被忽略
+func fieldsOf(columns []string) []int {
+	fields := make([]int, len(columns))
+	for i, column := range columns {
+		fields[i] = fieldAlias[strings.ToLower(strings.Trim(strings.TrimSpace(column), "`\""))]
+	}
+	return fields
+}
+
+// newRecord 按字段填充记录
+func newRecord(row int, fields []int, values []string) (*Record, error) {
+	record := &Record{Row: row}
+	for i, value := range values {
+		if i >= len(fields) {
+			break
+		}
+		value = strings.TrimSpace(value)
+		switch fields[i] {
+		case fieldCode:
+			record.Code = value
+		case fieldURL:
+			record.URL = value
+		case fieldTitle:
+			record.Title = value
+		case fieldCreatedAt:
+			t, err := parseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+			record.CreatedAt = t
+		}
+	}
+	return record, nil
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTime 解析创建时间,同时兼容 unix 时间戳,空值返回零值
+func parseTime(value string) (time.Time, error) {
+	if value == "" || strings.EqualFold(value, "NULL") || strings.HasPrefix(value, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	created := time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local)
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   []Record
+	}{
+		{
+			name:   "generic csv with header",
+			format: FormatCSV,
+			input: "url,code,created_at,title\n" +
+				"https://example.com/a,abc,2023-04-05 06:07:08,\"Hello, world\"\n" +
+				"\n" +
+				"https://example.com/b,def,,\n",
+			want: []Record{
+				{Row: 1, Code: "abc", URL: "https://example.com/a", Title: "Hello, world", CreatedAt: created},
+				{Row: 2, Code: "def", URL: "https://example.com/b"},
+			},
+		},
+		{
+			name:   "generic csv without header",
+			format: FormatCSV,
+			input:  "abc,https://example.com/a,title,1680645428\n",
+			want: []Record{
+				{Row: 1, Code: "abc", URL: "https://example.com/a", Title: "title", CreatedAt: time.Unix(1680645428, 0)},
+			},
+		},
+		{
+			name:   "yourls csv",
+			format: FormatYourlsCSV,
+			input: "keyword,url,title,timestamp,ip,clicks\n" +
+				"abc,https://example.com/a,A,2023-04-05 06:07:08,127.0.0.1,12\n",
+			want: []Record{
+				{Row: 1, Code: "abc", URL: "https://example.com/a", Title: "A", CreatedAt: created},
+			},
+		},
+		{
+			name:   "yourls sql",
+			format: FormatYourlsSQL,
+			input: "-- MySQL dump\n" +
+				"/*!40101 SET NAMES utf8 */;\n" +
+				"CREATE TABLE `yourls_url` (`keyword` varchar(100) NOT NULL);\n" +
+				"INSERT INTO `yourls_options` VALUES (1,'version','1.9');\n" +
+				"INSERT INTO `yourls_url` VALUES ('abc','https://example.com/a?x=1;y=2','It''s \\'quoted\\'','2023-04-05 06:07:08','127.0.0.1',12)," +
+				"('def','https://example.com/b',NULL,'0000-00-00 00:00:00','::1',0);\n" +
+				"insert into yourls_url (`url`, `keyword`) values ('https://example.com/c', 'ghi')",
+			want: []Record{
+				{Row: 1, Code: "abc", URL: "https://example.com/a?x=1;y=2", Title: "It's 'quoted'", CreatedAt: created},
+				{Row: 2, Code: "def", URL: "https://example.com/b"},
+				{Row: 3, Code: "ghi", URL: "https://example.com/c"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ReadAll() got %d records, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if *got[i] != tt.want[i] {
+					t.Errorf("record %d = %+v, want %+v", i, *got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{"unsupported format", "xml", ""},
+		{"bad time", FormatCSV, "code,url,created_at\nabc,https://example.com,yesterday\n"},
+		{"unterminated string", FormatYourlsSQL, "INSERT INTO yourls_url VALUES ('abc"},
+		{"bad tuple", FormatYourlsSQL, "INSERT INTO yourls_url VALUES ('abc' 'def');"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(tt.format, strings.NewReader(tt.input))
+			if err == nil {
+				_, err = ReadAll(r)
+			}
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
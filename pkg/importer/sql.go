@@ -0,0 +1,324 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// yourlsColumns yourls_url 表的列顺序,INSERT 语句未指定列名时使用
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// sqlReader 从 SQL 转储中读取 yourls_url 表的 INSERT 语句
+// 只解析 INSERT INTO ... [(列名)] VALUES (...),(...) 形式的语句,其余语句会被跳过
+type sqlReader struct {
+	r       *bufio.Reader
+	pending []*Record
+	row     int
+}
+
+func newSQLReader(r io.Reader) *sqlReader {
+	return &sqlReader{r: bufio.NewReader(r)}
+}
+
+func (s *sqlReader) Next() (*Record, error) {
+	for len(s.pending) == 0 {
+		stmt, err := s.readStatement()
+		if err != nil {
+			return nil, err
+		}
+		if s.pending, err = s.parseInsert(stmt); err != nil {
+			return nil, err
+		}
+	}
+	record := s.pending[0]
+	s.pending = s.pending[1:]
+	return record, nil
+}
+
+// readStatement 读取一条以分号结尾的语句,跳过注释,引号内的分号不作为结尾
+func (s *sqlReader) readStatement() (string, error) {
+	var buf bytes.Buffer
+	var quote byte
+	for {
+		ch, err := s.r.ReadByte()
+		if err == io.EOF {
+			if quote != 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+				return stmt, nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+		if quote != 0 {
+			buf.WriteByte(ch)
+			switch ch {
+			case '\\':
+				next, err := s.r.ReadByte()
+				if err != nil {
+					return "", io.ErrUnexpectedEOF
+				}
+				buf.WriteByte(next)
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case '\'', '"', '`':
+			quote = ch
+		case ';':
+			if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+				return stmt, nil
+			}
+			continue
+		case '-':
+			if next, _ := s.r.Peek(1); len(next) == 1 && next[0] == '-' {
+				if _, err = s.r.ReadString('\n'); err != nil && err != io.EOF {
+					return "", err
+				}
+				buf.WriteByte('\n')
+				continue
+			}
+		case '#':
+			if _, err = s.r.ReadString('\n'); err != nil && err != io.EOF {
+				return "", err
+			}
+			buf.WriteByte('\n')
+			continue
+		case '/':
+			if next, _ := s.r.Peek(1); len(next) == 1 && next[0] == '*' {
+				if err = s.skipBlockComment(); err != nil {
+					return "", err
+				}
+				buf.WriteByte(' ')
+				continue
+			}
+		}
+		buf.WriteByte(ch)
+	}
+}
+
+func (s *sqlReader) skipBlockComment() error {
+	var prev byte
+	for {
+		ch, err := s.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if prev == '*' && ch == '/' {
+			return nil
+		}
+		prev = ch
+	}
+}
+
+// parseInsert 解析 INSERT 语句,非 yourls_url 表的语句返回空
+func (s *sqlReader) parseInsert(stmt string) ([]*Record, error) {
+	t := &sqlTokenizer{src: stmt}
+	if !t.keyword("INSERT") {
+		return nil, nil
+	}
+	t.keyword("IGNORE")
+	if !t.keyword("INTO") {
+		return nil, nil
+	}
+	table, ok := t.ident()
+	if !ok {
+		return nil, t.errorf("table name expected")
+	}
+	// 兼容 db.table 写法
+	for t.punct('.') {
+		if table, ok = t.ident(); !ok {
+			return nil, t.errorf("table name expected")
+		}
+	}
+	if !strings.HasSuffix(strings.ToLower(table), "url") {
+		return nil, nil
+	}
+	columns := yourlsColumns
+	if t.punct('(') {
+		columns = nil
+		for {
+			column, ok := t.ident()
+			if !ok {
+				return nil, t.errorf("column name expected")
+			}
+			columns = append(columns, column)
+			if t.punct(')') {
+				break
+			}
+			if !t.punct(',') {
+				return nil, t.errorf("',' or ')' expected")
+			}
+		}
+	}
+	if !t.keyword("VALUES") && !t.keyword("VALUE") {
+		return nil, t.errorf("VALUES expected")
+	}
+	fields := fieldsOf(columns)
+	var records []*Record
+	for {
+		if !t.punct('(') {
+			return nil, t.errorf("'(' expected")
+		}
+		var values []string
+		for {
+			value, err := t.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if t.punct(')') {
+				break
+			}
+			if !t.punct(',') {
+				return nil, t.errorf("',' or ')' expected")
+			}
+		}
+		s.row++
+		record, err := newRecord(s.row, fields, values)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		if !t.punct(',') {
+			break
+		}
+	}
+	return records, nil
+}
+
+// sqlTokenizer 一个只满足 INSERT 语句解析需要的词法分析器
+type sqlTokenizer struct {
+	src string
+	pos int
+}
+
+func (t *sqlTokenizer) errorf(format string, args ...any) error {
+	return fmt.Errorf("sql syntax error at offset %d: %s", t.pos, fmt.Sprintf(format, args...))
+}
+
+func (t *sqlTokenizer) skipSpace() {
+	for t.pos < len(t.src) && strings.IndexByte(" \t\r\n", t.src[t.pos]) >= 0 {
+		t.pos++
+	}
+}
+
+func (t *sqlTokenizer) word() string {
+	t.skipSpace()
+	start := t.pos
+	for t.pos < len(t.src) {
+		ch := t.src[t.pos]
+		if strings.IndexByte("_$-+.", ch) >= 0 || '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' {
+			t.pos++
+			continue
+		}
+		break
+	}
+	return t.src[start:t.pos]
+}
+
+// keyword 读取一个关键字,不匹配时不消耗输入
+func (t *sqlTokenizer) keyword(kw string) bool {
+	pos := t.pos
+	if strings.EqualFold(t.word(), kw) {
+		return true
+	}
+	t.pos = pos
+	return false
+}
+
+func (t *sqlTokenizer) punct(ch byte) bool {
+	t.skipSpace()
+	if t.pos < len(t.src) && t.src[t.pos] == ch {
+		t.pos++
+		return true
+	}
+	return false
+}
+
+// ident 读取一个标识符,支持反引号
+func (t *sqlTokenizer) ident() (string, bool) {
+	t.skipSpace()
+	if t.pos < len(t.src) && t.src[t.pos] == '`' {
+		end := strings.IndexByte(t.src[t.pos+1:], '`')
+		if end < 0 {
+			return "", false
+		}
+		name := t.src[t.pos+1 : t.pos+1+end]
+		t.pos += end + 2
+		return name, true
+	}
+	pos := t.pos
+	for t.pos < len(t.src) {
+		ch := t.src[t.pos]
+		if ch == '_' || '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' {
+			t.pos++
+			continue
+		}
+		break
+	}
+	return t.src[pos:t.pos], t.pos > pos
+}
+
+// value 读取一个值: 字符串、数字或 NULL
+func (t *sqlTokenizer) value() (string, error) {
+	t.skipSpace()
+	if t.pos >= len(t.src) {
+		return "", t.errorf("value expected")
+	}
+	quote := t.src[t.pos]
+	if quote != '\'' && quote != '"' {
+		w := t.word()
+		if w == "" {
+			return "", t.errorf("value expected")
+		}
+		if strings.EqualFold(w, "NULL") {
+			return "", nil
+		}
+		return w, nil
+	}
+	t.pos++
+	var sb strings.Builder
+	for t.pos < len(t.src) {
+		ch := t.src[t.pos]
+		t.pos++
+		switch {
+		case ch == '\\' && t.pos < len(t.src):
+			next := t.src[t.pos]
+			t.pos++
+			switch next {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '0':
+				sb.WriteByte(0)
+			case 'Z':
+				sb.WriteByte(26)
+			default:
+				sb.WriteByte(next)
+			}
+		case ch == quote:
+			// 两个连续的引号表示引号本身
+			if t.pos < len(t.src) && t.src[t.pos] == quote {
+				sb.WriteByte(quote)
+				t.pos++
+				continue
+			}
+			return sb.String(), nil
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return "", t.errorf("unterminated string")
+}